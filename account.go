@@ -0,0 +1,260 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/accounts"
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/engine"
+)
+
+const AccountCookieName = "account"
+
+var accountStore *accounts.Store
+
+// dataPath returns the path of a file in the data directory, which is
+// CADERE_DATA_DIR or ./data
+func dataPath(name string) string {
+	dir := os.Getenv("CADERE_DATA_DIR")
+	if dir == "" {
+		dir = "data"
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// AccountFormView is the data the register and login forms are rendered with
+type AccountFormView struct {
+	Username string
+	Error    string
+}
+
+// BotsView is the data the bot management page is rendered with. Token is
+// the token of TokenBot, shown only right after it was created.
+type BotsView struct {
+	Username string
+	Bots     []accounts.Account
+	TokenBot string
+	Token    string
+	Error    string
+}
+
+// MoveTimeLimit is how many seconds the bots have for each move
+func (v BotsView) MoveTimeLimit() int {
+	return int(BOT_MOVE_TIME_LIMIT.Seconds())
+}
+
+// currentAccount returns the username of the logged in player, or "" for
+// anonymous players
+func currentAccount(c echo.Context) string {
+	cookie, err := c.Cookie(AccountCookieName)
+	if err != nil {
+		return ""
+	}
+
+	username, err := accountStore.LoggedIn(cookie.Value)
+	if err != nil {
+		return ""
+	}
+
+	return username
+}
+
+// tokenStoreKey returns the key of the player's games in the token store.
+// Logged in players keep their games in their account, so they follow them
+// across devices.
+func tokenStoreKey(c echo.Context) (string, error) {
+	if username := currentAccount(c); username != "" {
+		return accountTokenStoreKey(username), nil
+	}
+
+	return browserId(c)
+}
+
+func accountTokenStoreKey(username string) string {
+	return "account:" + strings.ToLower(username)
+}
+
+// parseAccountProfile reads the profile fields like parseProfile, using the
+// username as the default name of logged in players. Returns the username too.
+func parseAccountProfile(c echo.Context, player engine.Player) (auth.PlayerProfile, string, error) {
+	profile, err := parseProfileFields(c.FormValue)
+	if err != nil {
+		return profile, "", err
+	}
+
+	username := currentAccount(c)
+	if profile.Name == "" {
+		profile.Name = username
+	}
+
+	return withDefaultProfile(profile, player), username, nil
+}
+
+// logIn starts a login for the account and moves the games played from this
+// browser into it
+func logIn(c echo.Context, username string) error {
+	id, err := accountStore.Login(username)
+	if err != nil {
+		return err
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     AccountCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(accounts.LoginLifetime.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if browser, err := browserId(c); err == nil {
+		tokenStore.Merge(browser, accountTokenStoreKey(username))
+	}
+
+	return nil
+}
+
+func registerAccountRoutes(e *echo.Echo) {
+	e.GET("/register", func(c echo.Context) error {
+		return c.Render(http.StatusOK, "register", AccountFormView{})
+	})
+
+	e.POST("/register", func(c echo.Context) error {
+		username := strings.TrimSpace(c.FormValue("username"))
+		password := c.FormValue("password")
+
+		if password != c.FormValue("confirmPassword") {
+			return c.Render(http.StatusUnprocessableEntity, "registerForm", AccountFormView{
+				Username: username,
+				Error:    "The passwords do not match",
+			})
+		}
+
+		account, err := accountStore.Register(username, password)
+		if err != nil {
+			message := "Something went wrong"
+			switch err {
+			case accounts.ErrorInvalidUsername, accounts.ErrorInvalidPassword, accounts.ErrorUsernameTaken:
+				message = err.Error()
+			}
+
+			return c.Render(http.StatusUnprocessableEntity, "registerForm", AccountFormView{
+				Username: username,
+				Error:    message,
+			})
+		}
+
+		if err := logIn(c, account.Username); err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		c.Response().Header().Set("HX-Redirect", "/new")
+		return c.NoContent(http.StatusOK)
+	})
+
+	e.GET("/login", func(c echo.Context) error {
+		return c.Render(http.StatusOK, "login", AccountFormView{})
+	})
+
+	e.POST("/login", func(c echo.Context) error {
+		username := strings.TrimSpace(c.FormValue("username"))
+
+		account, err := accountStore.Authenticate(username, c.FormValue("password"))
+		if err != nil {
+			return c.Render(http.StatusUnprocessableEntity, "loginForm", AccountFormView{
+				Username: username,
+				Error:    accounts.ErrorWrongCredentials.Error(),
+			})
+		}
+
+		if err := logIn(c, account.Username); err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		c.Response().Header().Set("HX-Redirect", "/new")
+		return c.NoContent(http.StatusOK)
+	})
+
+	e.POST("/logout", func(c echo.Context) error {
+		if cookie, err := c.Cookie(AccountCookieName); err == nil {
+			if err := accountStore.Logout(cookie.Value); err != nil {
+				return err
+			}
+		}
+
+		c.SetCookie(&http.Cookie{
+			Name:   AccountCookieName,
+			Path:   "/",
+			MaxAge: -1,
+		})
+
+		c.Response().Header().Set("HX-Redirect", "/new")
+		return c.NoContent(http.StatusOK)
+	})
+
+	e.GET("/account", func(c echo.Context) error {
+		return c.Render(http.StatusOK, "account", currentAccount(c))
+	})
+
+	e.GET("/bots", func(c echo.Context) error {
+		username := currentAccount(c)
+		if username == "" {
+			return c.Redirect(http.StatusFound, "/login")
+		}
+
+		return c.Render(http.StatusOK, "bots", BotsView{
+			Username: username,
+			Bots:     accountStore.Bots(username),
+		})
+	})
+
+	e.POST("/bots", func(c echo.Context) error {
+		username := currentAccount(c)
+		if username == "" {
+			return c.NoContent(http.StatusUnauthorized)
+		}
+
+		view := BotsView{Username: username}
+		bot, token, err := accountStore.RegisterBot(username, strings.TrimSpace(c.FormValue("username")))
+		switch err {
+		case nil:
+			view.TokenBot = bot.Username
+			view.Token = token
+		case accounts.ErrorInvalidUsername, accounts.ErrorUsernameTaken, accounts.ErrorBotOwner:
+			view.Error = err.Error()
+		default:
+			view.Error = "Something went wrong"
+		}
+
+		view.Bots = accountStore.Bots(username)
+		return c.Render(http.StatusOK, "botList", view)
+	})
+
+	e.POST("/bots/:username/token", func(c echo.Context) error {
+		username := currentAccount(c)
+		if username == "" {
+			return c.NoContent(http.StatusUnauthorized)
+		}
+
+		view := BotsView{Username: username}
+		token, err := accountStore.ResetBotToken(username, c.Param("username"))
+		switch err {
+		case nil:
+			view.TokenBot = c.Param("username")
+			view.Token = token
+		case accounts.ErrorAccountNotFound, accounts.ErrorNotBotOwner:
+			view.Error = err.Error()
+		default:
+			view.Error = "Something went wrong"
+		}
+
+		view.Bots = accountStore.Bots(username)
+		return c.Render(http.StatusOK, "botList", view)
+	})
+}
@@ -0,0 +1,430 @@
+package accounts
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Denloob/cadere/util"
+)
+
+const (
+	PasswordMinLength = 8
+	// PasswordMaxLength is the most bcrypt can hash
+	PasswordMaxLength = 72
+
+	LoginLifetime = 30 * 24 * time.Hour
+
+	loginIdByteLength = 16
+
+	botTokenByteLength = 32
+	// BotTokenPrefix starts every bot token, so they are easy to tell apart
+	// from game tokens and to find when they leak
+	BotTokenPrefix = "cbt_"
+)
+
+var (
+	ErrorInvalidUsername  = errors.New("Usernames are 3 to 20 letters, digits or underscores")
+	ErrorInvalidPassword  = errors.New("Passwords are 8 to 72 characters long")
+	ErrorUsernameTaken    = errors.New("This username is already taken")
+	ErrorWrongCredentials = errors.New("Wrong username or password")
+	ErrorNotLoggedIn      = errors.New("not logged in")
+	ErrorAccountNotFound  = errors.New("account not found")
+	ErrorNotBotOwner      = errors.New("You do not own this bot")
+	ErrorBotOwner         = errors.New("Bots cannot own other bots")
+	ErrorInvalidBotToken  = errors.New("invalid bot token")
+)
+
+var usernameRegex = regexp.MustCompile("^[a-zA-Z0-9_]{3,20}$")
+
+var bcryptCost = bcrypt.DefaultCost
+
+// dummyHash is compared against when the username doesn't exist, so the
+// response time doesn't tell which usernames are registered
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcryptCost)
+
+// Account is a registered player. Bot accounts have no password; they are
+// owned by a player and programs play with them using their bot token.
+type Account struct {
+	Username     string
+	PasswordHash []byte `json:",omitempty"`
+	Created      time.Time
+
+	Bot   bool   `json:",omitempty"`
+	Owner string `json:",omitempty"`
+	// BotTokenHash is the SHA-256 of the bot token. The token is random, so
+	// it doesn't need a slow hash like the passwords.
+	BotTokenHash []byte `json:",omitempty"`
+}
+
+// login is a logged in browser. Logins are stored by the SHA-256 of their
+// ID, like the bot tokens, so the file can't be used to log in.
+type login struct {
+	Username  string
+	ExpiresAt time.Time
+}
+
+// Store keeps the accounts and the logins in JSON files, so players stay
+// logged in across restarts. Empty paths keep them in memory only.
+type Store struct {
+	mutex      sync.Mutex
+	path       string
+	loginsPath string
+	accounts   map[string]Account
+	logins     map[string]login
+	// botTokens maps the hashes of the bot tokens to the keys of the accounts
+	botTokens map[string]string
+}
+
+// NewStore loads the accounts from the file at path and the logins from the
+// file at loginsPath, if they exist
+func NewStore(path, loginsPath string) (*Store, error) {
+	store := &Store{
+		path:       path,
+		loginsPath: loginsPath,
+		accounts:   make(map[string]Account),
+		logins:     make(map[string]login),
+		botTokens:  make(map[string]string),
+	}
+
+	if err := load(path, &store.accounts); err != nil {
+		return nil, fmt.Errorf("corrupted accounts file %s: %w", path, err)
+	}
+	if err := load(loginsPath, &store.logins); err != nil {
+		return nil, fmt.Errorf("corrupted logins file %s: %w", loginsPath, err)
+	}
+
+	for key, account := range store.accounts {
+		if account.Bot {
+			store.botTokens[string(account.BotTokenHash)] = key
+		}
+	}
+
+	return store, nil
+}
+
+// load reads the JSON file at path into value. Files which don't exist, and
+// the empty path, leave value as it is.
+func load(path string, value any) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+// normalize makes usernames case insensitive
+func normalize(username string) string {
+	return strings.ToLower(username)
+}
+
+// Register creates an account with the password hashed with bcrypt
+func (s *Store) Register(username, password string) (Account, error) {
+	if !usernameRegex.MatchString(username) {
+		return Account{}, ErrorInvalidUsername
+	}
+	if len(password) < PasswordMinLength || len(password) > PasswordMaxLength {
+		return Account{}, ErrorInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return Account{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := normalize(username)
+	if _, ok := s.accounts[key]; ok {
+		return Account{}, ErrorUsernameTaken
+	}
+
+	account := Account{
+		Username:     username,
+		PasswordHash: hash,
+		Created:      time.Now(),
+	}
+	s.accounts[key] = account
+
+	if err := s.save(); err != nil {
+		delete(s.accounts, key)
+		return Account{}, err
+	}
+
+	return account, nil
+}
+
+// Authenticate returns the account if the password matches. Bots have no
+// password, so they can't log in.
+func (s *Store) Authenticate(username, password string) (Account, error) {
+	s.mutex.Lock()
+	account, ok := s.accounts[normalize(username)]
+	s.mutex.Unlock()
+
+	if !ok || account.Bot {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return Account{}, ErrorWrongCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword(account.PasswordHash, []byte(password)); err != nil {
+		return Account{}, ErrorWrongCredentials
+	}
+
+	return account, nil
+}
+
+func newBotToken() (token string, hash []byte, err error) {
+	tokenBytes := make([]byte, botTokenByteLength)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", nil, err
+	}
+
+	token = BotTokenPrefix + base64.RawURLEncoding.EncodeToString(tokenBytes)
+	return token, hashBotToken(token), nil
+}
+
+func hashBotToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// RegisterBot creates a bot account owned by the player. Returns the bot
+// token, which is only stored hashed, so it can't be shown again.
+func (s *Store) RegisterBot(owner, username string) (Account, string, error) {
+	if !usernameRegex.MatchString(username) {
+		return Account{}, "", ErrorInvalidUsername
+	}
+
+	token, hash, err := newBotToken()
+	if err != nil {
+		return Account{}, "", err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ownerAccount, ok := s.accounts[normalize(owner)]
+	if !ok {
+		return Account{}, "", ErrorAccountNotFound
+	}
+	if ownerAccount.Bot {
+		return Account{}, "", ErrorBotOwner
+	}
+
+	key := normalize(username)
+	if _, ok := s.accounts[key]; ok {
+		return Account{}, "", ErrorUsernameTaken
+	}
+
+	account := Account{
+		Username:     username,
+		Created:      time.Now(),
+		Bot:          true,
+		Owner:        ownerAccount.Username,
+		BotTokenHash: hash,
+	}
+	s.accounts[key] = account
+	s.botTokens[string(hash)] = key
+
+	if err := s.save(); err != nil {
+		delete(s.accounts, key)
+		delete(s.botTokens, string(hash))
+		return Account{}, "", err
+	}
+
+	return account, token, nil
+}
+
+// ResetBotToken replaces the token of the bot, so the old one stops working
+func (s *Store) ResetBotToken(owner, username string) (string, error) {
+	token, hash, err := newBotToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := normalize(username)
+	account, ok := s.accounts[key]
+	if !ok || !account.Bot {
+		return "", ErrorAccountNotFound
+	}
+	if normalize(account.Owner) != normalize(owner) {
+		return "", ErrorNotBotOwner
+	}
+
+	oldHash := account.BotTokenHash
+	account.BotTokenHash = hash
+	s.accounts[key] = account
+	delete(s.botTokens, string(oldHash))
+	s.botTokens[string(hash)] = key
+
+	if err := s.save(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// AuthenticateBot returns the bot account the token belongs to
+func (s *Store) AuthenticateBot(token string) (Account, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.botTokens[string(hashBotToken(token))]
+	if !ok {
+		return Account{}, ErrorInvalidBotToken
+	}
+
+	return s.accounts[key], nil
+}
+
+// Bots returns the bots owned by the player, sorted by username
+func (s *Store) Bots(owner string) []Account {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bots := []Account{}
+	for _, account := range s.accounts {
+		if account.Bot && normalize(account.Owner) == normalize(owner) {
+			bots = append(bots, account)
+		}
+	}
+
+	slices.SortFunc(bots, func(a, b Account) int {
+		return strings.Compare(normalize(a.Username), normalize(b.Username))
+	})
+
+	return bots
+}
+
+func (s *Store) Get(username string) (Account, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	account, ok := s.accounts[normalize(username)]
+	if !ok {
+		return Account{}, ErrorAccountNotFound
+	}
+
+	return account, nil
+}
+
+// Login starts a login for the account and returns its ID
+func (s *Store) Login(username string) (string, error) {
+	idBytes := make([]byte, loginIdByteLength)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(idBytes)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	account, ok := s.accounts[normalize(username)]
+	if !ok {
+		return "", ErrorAccountNotFound
+	}
+
+	s.pruneLogins(time.Now())
+	key := hashLoginId(id)
+	s.logins[key] = login{
+		Username:  account.Username,
+		ExpiresAt: time.Now().Add(LoginLifetime),
+	}
+
+	if err := s.saveLogins(); err != nil {
+		delete(s.logins, key)
+		return "", err
+	}
+
+	return id, nil
+}
+
+func hashLoginId(id string) string {
+	hash := sha256.Sum256([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// LoggedIn returns the username of the login
+func (s *Store) LoggedIn(id string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	login, ok := s.logins[hashLoginId(id)]
+	if !ok || time.Now().After(login.ExpiresAt) {
+		return "", ErrorNotLoggedIn
+	}
+
+	return login.Username, nil
+}
+
+func (s *Store) Logout(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := hashLoginId(id)
+	if _, ok := s.logins[key]; !ok {
+		return nil
+	}
+
+	delete(s.logins, key)
+	return s.saveLogins()
+}
+
+func (s *Store) pruneLogins(now time.Time) {
+	for key, login := range s.logins {
+		if now.After(login.ExpiresAt) {
+			delete(s.logins, key)
+		}
+	}
+}
+
+// save writes the accounts to the file. The caller must hold the mutex.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(s.path, data)
+}
+
+// saveLogins writes the logins to their file. The caller must hold the mutex.
+func (s *Store) saveLogins() error {
+	if s.loginsPath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.logins)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(s.loginsPath, data)
+}
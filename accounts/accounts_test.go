@@ -0,0 +1,172 @@
+package accounts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	bcryptCost = bcrypt.MinCost
+}
+
+func TestRegister(t *testing.T) {
+	store, err := NewStore("", "")
+	assert.NoError(t, err)
+
+	account, err := store.Register("Alice", "correct horse")
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", account.Username)
+	assert.NotContains(t, string(account.PasswordHash), "correct horse")
+
+	_, err = store.Register("alice", "another password")
+	assert.ErrorIs(t, err, ErrorUsernameTaken)
+
+	_, err = store.Register("a", "correct horse")
+	assert.ErrorIs(t, err, ErrorInvalidUsername)
+	_, err = store.Register("bob smith", "correct horse")
+	assert.ErrorIs(t, err, ErrorInvalidUsername)
+	_, err = store.Register("bob", "short")
+	assert.ErrorIs(t, err, ErrorInvalidPassword)
+}
+
+func TestAuthenticate(t *testing.T) {
+	store, err := NewStore("", "")
+	assert.NoError(t, err)
+
+	_, err = store.Register("Alice", "correct horse")
+	assert.NoError(t, err)
+
+	account, err := store.Authenticate("alice", "correct horse")
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", account.Username)
+
+	_, err = store.Authenticate("alice", "wrong horse")
+	assert.ErrorIs(t, err, ErrorWrongCredentials)
+
+	_, err = store.Authenticate("bob", "correct horse")
+	assert.ErrorIs(t, err, ErrorWrongCredentials)
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "accounts.json")
+
+	store, err := NewStore(path, "")
+	assert.NoError(t, err)
+	_, err = store.Register("Alice", "correct horse")
+	assert.NoError(t, err)
+
+	store, err = NewStore(path, "")
+	assert.NoError(t, err)
+	_, err = store.Authenticate("Alice", "correct horse")
+	assert.NoError(t, err)
+}
+
+func TestLogin(t *testing.T) {
+	store, err := NewStore("", "")
+	assert.NoError(t, err)
+
+	_, err = store.Login("alice")
+	assert.ErrorIs(t, err, ErrorAccountNotFound)
+
+	_, err = store.Register("Alice", "correct horse")
+	assert.NoError(t, err)
+
+	id, err := store.Login("alice")
+	assert.NoError(t, err)
+
+	username, err := store.LoggedIn(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", username)
+
+	assert.NoError(t, store.Logout(id))
+	_, err = store.LoggedIn(id)
+	assert.ErrorIs(t, err, ErrorNotLoggedIn)
+}
+
+func TestLoginPersistence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "accounts.json")
+	loginsPath := filepath.Join(dir, "logins.json")
+
+	store, err := NewStore(path, loginsPath)
+	assert.NoError(t, err)
+	_, err = store.Register("Alice", "correct horse")
+	assert.NoError(t, err)
+	id, err := store.Login("alice")
+	assert.NoError(t, err)
+	other, err := store.Login("alice")
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(loginsPath)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), id)
+
+	store, err = NewStore(path, loginsPath)
+	assert.NoError(t, err)
+	username, err := store.LoggedIn(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", username)
+
+	assert.NoError(t, store.Logout(id))
+	store, err = NewStore(path, loginsPath)
+	assert.NoError(t, err)
+	_, err = store.LoggedIn(id)
+	assert.ErrorIs(t, err, ErrorNotLoggedIn)
+	_, err = store.LoggedIn(other)
+	assert.NoError(t, err)
+}
+
+func TestBots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	store, err := NewStore(path, "")
+	assert.NoError(t, err)
+
+	_, _, err = store.RegisterBot("alice", "AliceBot")
+	assert.ErrorIs(t, err, ErrorAccountNotFound)
+
+	_, err = store.Register("Alice", "correct horse")
+	assert.NoError(t, err)
+
+	bot, token, err := store.RegisterBot("alice", "AliceBot")
+	assert.NoError(t, err)
+	assert.True(t, bot.Bot)
+	assert.Equal(t, "Alice", bot.Owner)
+	assert.True(t, strings.HasPrefix(token, BotTokenPrefix))
+
+	_, _, err = store.RegisterBot("alicebot", "BotBot")
+	assert.ErrorIs(t, err, ErrorBotOwner)
+	_, _, err = store.RegisterBot("alice", "alicebot")
+	assert.ErrorIs(t, err, ErrorUsernameTaken)
+
+	_, err = store.Authenticate("AliceBot", "")
+	assert.ErrorIs(t, err, ErrorWrongCredentials)
+
+	account, err := store.AuthenticateBot(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "AliceBot", account.Username)
+	_, err = store.AuthenticateBot("cbt_wrong")
+	assert.ErrorIs(t, err, ErrorInvalidBotToken)
+
+	assert.Len(t, store.Bots("ALICE"), 1)
+
+	_, err = store.Register("Bob", "correct horse")
+	assert.NoError(t, err)
+	_, err = store.ResetBotToken("bob", "AliceBot")
+	assert.ErrorIs(t, err, ErrorNotBotOwner)
+
+	newToken, err := store.ResetBotToken("alice", "AliceBot")
+	assert.NoError(t, err)
+	_, err = store.AuthenticateBot(token)
+	assert.ErrorIs(t, err, ErrorInvalidBotToken)
+
+	store, err = NewStore(path, "")
+	assert.NoError(t, err)
+	account, err = store.AuthenticateBot(newToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "AliceBot", account.Username)
+}
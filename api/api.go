@@ -0,0 +1,171 @@
+// Package api defines the JSON the server exchanges with clients other than
+// the browser, like the terminal client.
+package api
+
+import (
+	"github.com/Denloob/cadere/engine"
+)
+
+// Stage names, as used in GameState
+const (
+	StageLobby   = "lobby"
+	StageInit    = "init"
+	StagePlaying = "playing"
+	StageOver    = "over"
+)
+
+// StageName returns the name of the stage in the API
+func StageName(stage engine.Stage) string {
+	switch stage {
+	case engine.StageLobby:
+		return StageLobby
+	case engine.StageInit:
+		return StageInit
+	case engine.StatePlaying:
+		return StagePlaying
+	}
+
+	return StageOver
+}
+
+// Player is a player of a game as seen by the other players
+type Player struct {
+	Player engine.Player `json:"player"`
+	Name   string        `json:"name"`
+	Color  string        `json:"color"`
+	Bot    bool          `json:"bot,omitempty"`
+}
+
+// GameState is a game as seen by one of its players
+type GameState struct {
+	// Id identifies the game in URLs, like the replay and the board images
+	Id string `json:"id"`
+	// GameId is the ID other players join the game with
+	GameId string `json:"gameId"`
+
+	Stage   string          `json:"stage"`
+	Board   [][]engine.Tile `json:"board"`
+	Players []Player        `json:"players"`
+	// Moves is the number of moves played so far
+	Moves int `json:"moves"`
+
+	// CurrentPlayer is the player whose turn it is, 0 when the game is not
+	// in progress
+	CurrentPlayer engine.Player `json:"currentPlayer"`
+	// You is the player the token belongs to
+	You  engine.Player `json:"you"`
+	Host bool          `json:"host"`
+
+	Winner      engine.Player `json:"winner,omitempty"`
+	Draw        bool          `json:"draw,omitempty"`
+	DrawOffered bool          `json:"drawOffered,omitempty"`
+}
+
+// Player returns the player with the given ID
+func (s GameState) Player(player engine.Player) (Player, bool) {
+	for _, p := range s.Players {
+		if p.Player == player {
+			return p, true
+		}
+	}
+
+	return Player{}, false
+}
+
+// YourTurn returns whether the game waits for a move of the player the
+// token belongs to
+func (s GameState) YourTurn() bool {
+	return s.CurrentPlayer != 0 && s.CurrentPlayer == s.You
+}
+
+// GameToken is how /new and /join answer clients which accept JSON. Failed
+// requests are answered with an object with an error field instead.
+type GameToken struct {
+	Token string `json:"token"`
+}
+
+// Game websocket message types, sent to clients which accept JSON instead of
+// the HTML of the browsers
+const (
+	// MessageUpdate is sent when the game changed. The state itself is
+	// fetched from /api/game.
+	MessageUpdate = "update"
+	// MessageError is sent when an action sent over the websocket failed
+	MessageError = "error"
+)
+
+// GameMessage is a message of the game websocket to a client which accepts
+// JSON
+type GameMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+}
+
+// Action is a message sent over the game websocket
+type Action struct {
+	Action string `json:"action"`
+
+	Index     int    `json:"index"`
+	Direction string `json:"direction,omitempty"`
+
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+func StartAction() Action {
+	return Action{Action: "start"}
+}
+
+func PutAction(row, col int) Action {
+	return Action{Action: "put", Row: row, Col: col}
+}
+
+func ShiftAction(direction engine.Direction, index int) Action {
+	return Action{Action: "shift", Direction: direction.String(), Index: index}
+}
+
+func ResignAction() Action {
+	return Action{Action: "resign"}
+}
+
+// Bot event types
+const (
+	// EventGame is sent when a game of the bot changed, and for every game
+	// of the bot when it connects
+	EventGame = "game"
+	// EventYourTurn is sent when a game waits for a move of the bot
+	EventYourTurn = "yourTurn"
+	// EventGameOver is the last event of a game
+	EventGameOver = "gameOver"
+	// EventError is sent when an action sent over the bot websocket failed
+	EventError = "error"
+)
+
+// BotEvent is a line of the bot event stream
+type BotEvent struct {
+	Type   string `json:"type"`
+	GameId string `json:"gameId,omitempty"`
+
+	State *GameState `json:"state,omitempty"`
+	// MoveTimeLimitMs is how long the bot has to move on yourTurn events,
+	// before the server plays a random move for it
+	MoveTimeLimitMs int64 `json:"moveTimeLimitMs,omitempty"`
+
+	Message string `json:"message,omitempty"`
+}
+
+// BotAction is an action a bot sends over its websocket, for one of its games
+type BotAction struct {
+	GameId string `json:"gameId"`
+	Action
+}
+
+// LobbyGame is a public game which is waiting for players
+type LobbyGame struct {
+	GameId         string `json:"gameId"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	PlayerCount    int    `json:"playerCount"`
+	MaxPlayerCount int    `json:"maxPlayerCount"`
+	Rated          bool   `json:"rated"`
+}
@@ -0,0 +1,201 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+var ErrorAlreadyArchived = errors.New("game already archived")
+
+// PlayerRecord is a player of an archived game, as they were presented
+// during the game
+type PlayerRecord struct {
+	Player engine.Player
+	// Username is set only for players who were logged in
+	Username string `json:",omitempty"`
+	Name     string
+	Color    string
+	Bot      bool `json:",omitempty"`
+}
+
+// Record is a finished game with everything needed to replay it
+type Record struct {
+	Id     string
+	Width  int
+	Height int
+	// Players are in the turn order the game started with
+	Players []PlayerRecord
+	History []engine.Event
+	// Winner is 0 if the game ended in a draw
+	Winner   engine.Player
+	Draw     bool
+	Rated    bool
+	Started  time.Time
+	Finished time.Time
+}
+
+// Player returns the record of the player
+func (r Record) Player(player engine.Player) (PlayerRecord, bool) {
+	for _, playerRecord := range r.Players {
+		if playerRecord.Player == player {
+			return playerRecord, true
+		}
+	}
+
+	return PlayerRecord{}, false
+}
+
+// StartingPlayers returns the players in the turn order the game started with
+func (r Record) StartingPlayers() []engine.Player {
+	players := make([]engine.Player, len(r.Players))
+	for i, playerRecord := range r.Players {
+		players[i] = playerRecord.Player
+	}
+
+	return players
+}
+
+// Replay returns the position after every event of the game, starting with
+// the empty board
+func (r Record) Replay() ([]engine.Game, error) {
+	return engine.Replay(r.Width, r.Height, r.StartingPlayers(), r.History)
+}
+
+// Store keeps the archived games in memory, and appends them to a JSON lines
+// file. An empty path keeps them in memory only.
+type Store struct {
+	mutex      sync.RWMutex
+	path       string
+	records    []Record
+	byId       map[string]int
+	byUsername map[string][]int
+}
+
+// NewStore loads the games archived in the file at path, if it exists
+func NewStore(path string) (*Store, error) {
+	store := &Store{
+		path:       path,
+		byId:       make(map[string]int),
+		byUsername: make(map[string][]int),
+	}
+
+	if path == "" {
+		return store, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("corrupted archive %s at line %d: %w", path, line, err)
+		}
+		store.index(record)
+	}
+
+	return store, scanner.Err()
+}
+
+func key(username string) string {
+	return strings.ToLower(username)
+}
+
+// index adds the record to the in memory indexes. The caller must hold the
+// mutex for writing.
+func (s *Store) index(record Record) {
+	i := len(s.records)
+	s.records = append(s.records, record)
+	s.byId[record.Id] = i
+
+	for _, player := range record.Players {
+		if player.Username != "" {
+			s.byUsername[key(player.Username)] = append(s.byUsername[key(player.Username)], i)
+		}
+	}
+}
+
+// Add archives the game
+func (s *Store) Add(record Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.byId[record.Id]; ok {
+		return ErrorAlreadyArchived
+	}
+
+	if s.path != "" {
+		if err := s.appendToFile(record); err != nil {
+			return err
+		}
+	}
+
+	s.index(record)
+	return nil
+}
+
+func (s *Store) appendToFile(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (s *Store) Get(id string) (Record, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i, ok := s.byId[id]
+	if !ok {
+		return Record{}, false
+	}
+
+	return s.records[i], true
+}
+
+// ByUsername returns the games the user played in, newest first
+func (s *Store) ByUsername(username string) []Record {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	indexes := s.byUsername[key(username)]
+	records := make([]Record, 0, len(indexes))
+	for i := len(indexes) - 1; i >= 0; i-- {
+		records = append(records, s.records[indexes[i]])
+	}
+
+	return records
+}
@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+func finishedGame(t *testing.T) engine.Game {
+	game := engine.NewGame(engine.NewBoard(4, 1))
+	game.AddPlayers(1, 2)
+	game.ProgressStage()
+
+	assert.NoError(t, game.Play(1, engine.PutMove(0, 2)))
+	assert.NoError(t, game.Play(2, engine.PutMove(0, 0)))
+	assert.NoError(t, game.Play(1, engine.PutMove(0, 3)))
+	assert.NoError(t, game.Play(2, engine.PutMove(0, 1)))
+	assert.NoError(t, game.Play(1, engine.ShiftMove(engine.DirectionRight, 0)))
+	assert.NoError(t, game.Play(2, engine.ShiftMove(engine.DirectionRight, 0)))
+	assert.Equal(t, engine.StageOver, game.Stage())
+
+	return game
+}
+
+func testRecord(t *testing.T, id string) Record {
+	game := finishedGame(t)
+
+	return Record{
+		Id:     id,
+		Width:  4,
+		Height: 1,
+		Players: []PlayerRecord{
+			{Player: 1, Username: "Alice", Name: "Alice", Color: "#f26419"},
+			{Player: 2, Name: "Player 2", Color: "#33658a"},
+		},
+		History:  game.History(),
+		Winner:   2,
+		Started:  time.Now().Add(-time.Minute),
+		Finished: time.Now(),
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Add(testRecord(t, "first")))
+	assert.NoError(t, store.Add(testRecord(t, "second")))
+	assert.ErrorIs(t, store.Add(testRecord(t, "first")), ErrorAlreadyArchived)
+
+	store, err = NewStore(path)
+	assert.NoError(t, err)
+
+	record, ok := store.Get("first")
+	assert.True(t, ok)
+	assert.Len(t, record.History, 6)
+
+	_, ok = store.Get("third")
+	assert.False(t, ok)
+
+	records := store.ByUsername("alice")
+	assert.Len(t, records, 2)
+	assert.Equal(t, "second", records[0].Id)
+	assert.Empty(t, store.ByUsername("bob"))
+}
+
+func TestRecordReplay(t *testing.T) {
+	record := testRecord(t, "game")
+
+	positions, err := record.Replay()
+	assert.NoError(t, err)
+	assert.Len(t, positions, len(record.History)+1)
+
+	last := positions[len(positions)-1]
+	assert.Equal(t, finishedGame(t).Board, last.Board)
+	winner, err := last.Winner()
+	assert.NoError(t, err)
+	assert.Equal(t, record.Winner, winner)
+}
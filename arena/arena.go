@@ -0,0 +1,206 @@
+// Package arena plays bots against each other on the engine, to compare them
+// and to try out rule variants.
+package arena
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Denloob/cadere/bot"
+	"github.com/Denloob/cadere/engine"
+)
+
+var ErrorTooManyEntrants = errors.New("too many entrants for the board")
+
+// Entrant is a bot taking part in the arena
+type Entrant struct {
+	Name string
+	// New creates the bot for a game. Every game gets its own bot, so bots
+	// may keep state between their moves.
+	New func() bot.Bot
+}
+
+// Config describes the games to play. Every game seats all the entrants,
+// and every seat order is played GamesPerSeating times on every board size.
+type Config struct {
+	Entrants        []Entrant
+	Sizes           []int
+	GamesPerSeating int
+	// MaxMoves ends the games which run longer as draws
+	MaxMoves int
+	// Parallel is how many games are played at once
+	Parallel int
+}
+
+// GameResult is the outcome of a single game
+type GameResult struct {
+	Size int
+	// Seating is the index of the entrant in every seat, in turn order
+	Seating []int
+	// Winner is the index of the winning entrant, -1 for draws
+	Winner int
+	Moves  int
+	// Unfinished games reached the move limit
+	Unfinished bool
+}
+
+// WinnerSeat returns the seat of the winner, -1 for draws
+func (r GameResult) WinnerSeat() int {
+	for seat, entrant := range r.Seating {
+		if entrant == r.Winner {
+			return seat
+		}
+	}
+
+	return -1
+}
+
+// Play plays a single game on a square board. The entrant in seat i plays
+// as player i+1.
+func Play(entrants []Entrant, seating []int, size, maxMoves int) (GameResult, error) {
+	game := engine.NewGame(engine.NewBoard(size, size))
+	if len(seating) > game.Board.MaxPlayerCount(engine.MinTilesPerPlayer) {
+		return GameResult{}, ErrorTooManyEntrants
+	}
+
+	bots := make(map[engine.Player]bot.Bot, len(seating))
+	for seat, entrant := range seating {
+		player := engine.Player(seat + 1)
+		if err := game.AddPlayers(player); err != nil {
+			return GameResult{}, err
+		}
+		bots[player] = entrants[entrant].New()
+	}
+	game.ProgressStage()
+
+	result := GameResult{
+		Size:    size,
+		Seating: append([]int{}, seating...),
+		Winner:  -1,
+	}
+	for game.Stage() != engine.StageOver {
+		if result.Moves >= maxMoves {
+			result.Unfinished = true
+			return result, nil
+		}
+
+		player := game.CurrentPlayer()
+		entrant := entrants[seating[player-1]]
+		move, err := bots[player].Move(game.Clone())
+		if err != nil {
+			return GameResult{}, fmt.Errorf("%s: %w", entrant.Name, err)
+		}
+		if err := game.Play(player, move); err != nil {
+			return GameResult{}, fmt.Errorf("%s played an illegal move: %w", entrant.Name, err)
+		}
+
+		result.Moves++
+	}
+
+	if winner, err := game.Winner(); err == nil {
+		result.Winner = seating[winner-1]
+	}
+
+	return result, nil
+}
+
+// Seatings returns every order of the entrants
+func Seatings(entrantCount int) [][]int {
+	if entrantCount == 0 {
+		return [][]int{{}}
+	}
+
+	seatings := [][]int{}
+	for _, shorter := range Seatings(entrantCount - 1) {
+		for position := 0; position <= len(shorter); position++ {
+			seating := append([]int{}, shorter[:position]...)
+			seating = append(seating, entrantCount-1)
+			seating = append(seating, shorter[position:]...)
+			seatings = append(seatings, seating)
+		}
+	}
+
+	return seatings
+}
+
+type job struct {
+	size    int
+	seating []int
+}
+
+// Run plays all the games of the config and returns their results. It stops
+// at the first error.
+func Run(config Config) ([]GameResult, error) {
+	if len(config.Entrants) < 2 {
+		return nil, errors.New("at least two entrants are needed")
+	}
+
+	jobs := make(chan job)
+	results := make(chan GameResult)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func(err error) {
+		stopOnce.Do(func() {
+			errs <- err
+			close(done)
+		})
+	}
+
+	go func() {
+		defer close(jobs)
+
+		for _, size := range config.Sizes {
+			for _, seating := range Seatings(len(config.Entrants)) {
+				for i := 0; i < config.GamesPerSeating; i++ {
+					select {
+					case jobs <- job{size: size, seating: seating}:
+					case <-done:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < max(1, config.Parallel); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+
+			for job := range jobs {
+				result, err := Play(config.Entrants, job.seating, job.size, config.MaxMoves)
+				if err != nil {
+					stop(err)
+					return
+				}
+
+				select {
+				case results <- result:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	collected := []GameResult{}
+	for result := range results {
+		collected = append(collected, result)
+	}
+
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+
+	return collected, nil
+}
@@ -0,0 +1,104 @@
+package arena
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Denloob/cadere/bot"
+)
+
+func randomEntrants(count int) []Entrant {
+	entrants := []Entrant{}
+	for i := 0; i < count; i++ {
+		entrants = append(entrants, Entrant{
+			Name: "random",
+			New:  func() bot.Bot { return bot.Random{} },
+		})
+	}
+
+	return entrants
+}
+
+func TestSeatings(t *testing.T) {
+	assert.Len(t, Seatings(1), 1)
+	assert.Len(t, Seatings(3), 6)
+	assert.Contains(t, Seatings(2), []int{1, 0})
+}
+
+func TestPlay(t *testing.T) {
+	result, err := Play(randomEntrants(2), []int{1, 0}, 4, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Size)
+	assert.Positive(t, result.Moves)
+	if !result.Unfinished {
+		assert.Contains(t, []int{-1, 0, 1}, result.Winner)
+	}
+
+	result, err = Play(randomEntrants(2), []int{0, 1}, 4, 3)
+	assert.NoError(t, err)
+	assert.True(t, result.Unfinished)
+	assert.Equal(t, -1, result.Winner)
+	assert.Equal(t, 3, result.Moves)
+
+	_, err = Play(randomEntrants(3), []int{0, 1, 2}, 2, 1000)
+	assert.ErrorIs(t, err, ErrorTooManyEntrants)
+}
+
+func TestRun(t *testing.T) {
+	entrants := randomEntrants(3)
+	results, err := Run(Config{
+		Entrants:        entrants,
+		Sizes:           []int{3, 4},
+		GamesPerSeating: 2,
+		MaxMoves:        500,
+		Parallel:        4,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2*6*2)
+
+	report := NewReport(entrants, results)
+	assert.Equal(t, 24, report.Games)
+	assert.Len(t, report.Sizes, 2)
+	for _, entrant := range report.Entrants {
+		assert.Equal(t, 24, entrant.Games)
+		assert.Equal(t, entrant.Games, entrant.Wins+entrant.Draws+entrant.Losses)
+	}
+
+	_, err = Run(Config{Entrants: entrants, Sizes: []int{2}, GamesPerSeating: 1, MaxMoves: 10})
+	assert.ErrorIs(t, err, ErrorTooManyEntrants)
+}
+
+func TestWilson(t *testing.T) {
+	low, high := Wilson(50, 100, Z95)
+	assert.InDelta(t, 0.4038, low, 0.0001)
+	assert.InDelta(t, 0.5962, high, 0.0001)
+
+	low, high = Wilson(0, 10, Z95)
+	assert.Equal(t, 0.0, low)
+	assert.InDelta(t, 0.2775, high, 0.0001)
+
+	low, high = Wilson(0, 0, Z95)
+	assert.Equal(t, 0.0, low)
+	assert.Equal(t, 1.0, high)
+}
+
+func TestReportSeats(t *testing.T) {
+	entrants := randomEntrants(2)
+	report := NewReport(entrants, []GameResult{
+		{Size: 4, Seating: []int{0, 1}, Winner: 0, Moves: 10},
+		{Size: 4, Seating: []int{1, 0}, Winner: 1, Moves: 20},
+		{Size: 5, Seating: []int{0, 1}, Winner: -1, Moves: 30, Unfinished: true},
+	})
+
+	assert.Equal(t, 20.0, report.AverageMoves)
+	assert.Equal(t, 1, report.Draws)
+	assert.Equal(t, 1, report.Unfinished)
+	assert.Equal(t, 2, report.Seats[0].Wins)
+	assert.Equal(t, 0, report.Seats[1].Wins)
+	assert.Equal(t, 1, report.Entrants[0].Wins)
+	assert.Equal(t, 1, report.Entrants[0].Losses)
+	assert.Equal(t, 1, report.Entrants[0].Draws)
+	assert.Len(t, report.Sizes, 2)
+	assert.Equal(t, 15.0, report.Sizes[0].AverageMoves)
+}
@@ -0,0 +1,164 @@
+package arena
+
+import (
+	"math"
+	"sort"
+)
+
+// Z95 is the z score of a 95% confidence level
+const Z95 = 1.959963984540054
+
+// Wilson returns the Wilson score interval of the proportion of successes,
+// for the z score of the confidence level. Unlike the normal approximation,
+// it stays within [0, 1] and works for small samples.
+func Wilson(successes, trials int, z float64) (low, high float64) {
+	if trials == 0 {
+		return 0, 1
+	}
+
+	n := float64(trials)
+	p := float64(successes) / n
+	z2 := z * z
+
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := z / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// Record is the results of an entrant, or of a seat. Draws count as games
+// which were not won.
+type Record struct {
+	Games   int     `json:"games"`
+	Wins    int     `json:"wins"`
+	Draws   int     `json:"draws"`
+	Losses  int     `json:"losses"`
+	WinRate float64 `json:"winRate"`
+	// WinRateLow and WinRateHigh are the 95% confidence interval of the
+	// win rate
+	WinRateLow  float64 `json:"winRateLow"`
+	WinRateHigh float64 `json:"winRateHigh"`
+}
+
+func (r *Record) add(won, drawn bool) {
+	r.Games++
+	switch {
+	case won:
+		r.Wins++
+	case drawn:
+		r.Draws++
+	default:
+		r.Losses++
+	}
+}
+
+func (r *Record) finish() {
+	if r.Games > 0 {
+		r.WinRate = float64(r.Wins) / float64(r.Games)
+	}
+	r.WinRateLow, r.WinRateHigh = Wilson(r.Wins, r.Games, Z95)
+}
+
+// EntrantReport is the record of an entrant
+type EntrantReport struct {
+	Name string `json:"name"`
+	Record
+}
+
+// SeatReport is the record of whoever sat in a seat, which shows the
+// advantage of moving first
+type SeatReport struct {
+	Seat int `json:"seat"`
+	Record
+}
+
+// Summary is the results of a group of games
+type Summary struct {
+	Games        int             `json:"games"`
+	Draws        int             `json:"draws"`
+	Unfinished   int             `json:"unfinished"`
+	AverageMoves float64         `json:"averageMoves"`
+	Entrants     []EntrantReport `json:"entrants"`
+	Seats        []SeatReport    `json:"seats"`
+}
+
+// SizeSummary is the results of the games on one board size
+type SizeSummary struct {
+	Size int `json:"size"`
+	Summary
+}
+
+// Report is the results of an arena run, overall and by board size
+type Report struct {
+	Summary
+	Sizes []SizeSummary `json:"sizes"`
+}
+
+// NewReport summarizes the results of the games of the entrants
+func NewReport(entrants []Entrant, results []GameResult) Report {
+	bySize := map[int][]GameResult{}
+	for _, result := range results {
+		bySize[result.Size] = append(bySize[result.Size], result)
+	}
+
+	sizes := make([]int, 0, len(bySize))
+	for size := range bySize {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+
+	report := Report{
+		Summary: summarize(entrants, results),
+		Sizes:   []SizeSummary{},
+	}
+	for _, size := range sizes {
+		report.Sizes = append(report.Sizes, SizeSummary{
+			Size:    size,
+			Summary: summarize(entrants, bySize[size]),
+		})
+	}
+
+	return report
+}
+
+func summarize(entrants []Entrant, results []GameResult) Summary {
+	summary := Summary{
+		Games:    len(results),
+		Entrants: make([]EntrantReport, len(entrants)),
+		Seats:    make([]SeatReport, len(entrants)),
+	}
+	for i, entrant := range entrants {
+		summary.Entrants[i].Name = entrant.Name
+		summary.Seats[i].Seat = i + 1
+	}
+
+	totalMoves := 0
+	for _, result := range results {
+		totalMoves += result.Moves
+		drawn := result.Winner == -1
+		if drawn {
+			summary.Draws++
+		}
+		if result.Unfinished {
+			summary.Unfinished++
+		}
+
+		winnerSeat := result.WinnerSeat()
+		for seat, entrant := range result.Seating {
+			summary.Entrants[entrant].add(entrant == result.Winner, drawn)
+			summary.Seats[seat].add(seat == winnerSeat, drawn)
+		}
+	}
+
+	if len(results) > 0 {
+		summary.AverageMoves = float64(totalMoves) / float64(len(results))
+	}
+	for i := range summary.Entrants {
+		summary.Entrants[i].finish()
+	}
+	for i := range summary.Seats {
+		summary.Seats[i].finish()
+	}
+
+	return summary
+}
@@ -3,7 +3,10 @@ package auth
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt"
 
@@ -12,12 +15,166 @@ import (
 )
 
 const hmacSize = 256
+const tokenIdBitLength = 128
+
+// TokenLifetime is how long a token is valid after it was issued
+const TokenLifetime = 7 * 24 * time.Hour
+
+// TokenRefreshWindow is how long before expiring a token should be refreshed
+const TokenRefreshWindow = TokenLifetime / 2
+
+var ErrorTokenRevoked = errors.New("token was revoked")
 
 var hmacSecret = []byte(util.Must(GenerateNonce(hmacSize)))
 
+// PlayerProfile is how a player is presented to the others
+type PlayerProfile struct {
+	Name string
+	// Color is a CSS hex color, like #f26419
+	Color string
+}
+
 type GameSession struct {
-	Game  *engine.Game
-	nonce string
+	Game     *engine.Game
+	nonce    string
+	profiles map[engine.Player]PlayerProfile
+	// accounts maps the players who are logged in to their usernames
+	accounts map[engine.Player]string
+	tokens   *tokenRegistry
+}
+
+// tokenRegistry keeps track of the tokens issued for a session, so they can
+// be revoked
+type tokenRegistry struct {
+	mutex sync.Mutex
+	// issued maps each player to the IDs and expiration times of their tokens
+	issued  map[engine.Player]map[string]int64
+	revoked map[string]int64
+}
+
+func newTokenRegistry() *tokenRegistry {
+	return &tokenRegistry{
+		issued:  make(map[engine.Player]map[string]int64),
+		revoked: make(map[string]int64),
+	}
+}
+
+func (r *tokenRegistry) add(player engine.Player, id string, expiresAt int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.issued[player] == nil {
+		r.issued[player] = make(map[string]int64)
+	}
+	r.issued[player][id] = expiresAt
+}
+
+func (r *tokenRegistry) revoke(player engine.Player, id string, expiresAt int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.revoked[id] = expiresAt
+	delete(r.issued[player], id)
+	r.pruneLocked(time.Now().Unix())
+}
+
+func (r *tokenRegistry) revokePlayer(player engine.Player) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, expiresAt := range r.issued[player] {
+		r.revoked[id] = expiresAt
+	}
+	delete(r.issued, player)
+	r.pruneLocked(time.Now().Unix())
+}
+
+func (r *tokenRegistry) isRevoked(id string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.revoked[id]
+	return ok
+}
+
+// pruneLocked forgets the tokens which have expired anyway
+func (r *tokenRegistry) pruneLocked(now int64) {
+	for id, expiresAt := range r.revoked {
+		if expiresAt < now {
+			delete(r.revoked, id)
+		}
+	}
+
+	for _, ids := range r.issued {
+		for id, expiresAt := range ids {
+			if expiresAt < now {
+				delete(ids, id)
+			}
+		}
+	}
+}
+
+type Role string
+
+const (
+	RolePlayer    Role = "player"
+	RoleHost      Role = "host"
+	RoleSpectator Role = "spectator"
+)
+
+// CadereClaims are the claims of every token issued by a GameSession
+type CadereClaims struct {
+	Nonce  string        `json:"nonce"`
+	Player engine.Player `json:"player"`
+	// Role is the role of the player at the time the token was issued
+	Role  Role   `json:"role"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	// Account is the username of the player, if they are logged in
+	Account string `json:"account,omitempty"`
+	jwt.StandardClaims
+}
+
+// tokenClockSkew is how far in the future a token may have been issued,
+// to account for clock differences between servers
+const tokenClockSkew = time.Minute
+
+// Valid makes sure all the claims are present and make sense
+func (c CadereClaims) Valid() error {
+	if err := c.StandardClaims.Valid(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch {
+	case c.Nonce == "":
+		return errors.New("missing nonce")
+	case c.Id == "":
+		return errors.New("missing token ID")
+	case c.IssuedAt == 0 || c.ExpiresAt == 0:
+		return errors.New("missing issue or expiration time")
+	case time.Unix(c.IssuedAt, 0).After(now.Add(tokenClockSkew)):
+		return errors.New("token issued in the future")
+	case c.ExpiresAt <= c.IssuedAt:
+		return errors.New("token expires before it was issued")
+	case time.Duration(c.ExpiresAt-c.IssuedAt)*time.Second > TokenLifetime:
+		return errors.New("token lifetime too long")
+	}
+
+	switch c.Role {
+	case RolePlayer, RoleHost:
+		if c.Player <= 0 {
+			return errors.New("invalid player")
+		}
+	case RoleSpectator:
+		if c.Player != 0 {
+			return errors.New("spectators cannot have a player")
+		}
+	default:
+		return errors.New("invalid role")
+	}
+
+	return nil
 }
 
 func (s GameSession) Nonce() string {
@@ -26,34 +183,126 @@ func (s GameSession) Nonce() string {
 
 func NewGameSession(game *engine.Game, nonce string) GameSession {
 	return GameSession{
-		Game:  game,
-		nonce: nonce,
+		Game:     game,
+		nonce:    nonce,
+		profiles: make(map[engine.Player]PlayerProfile),
+		accounts: make(map[engine.Player]string),
+		tokens:   newTokenRegistry(),
+	}
+}
+
+func (s GameSession) SetProfile(player engine.Player, profile PlayerProfile) {
+	s.profiles[player] = profile
+}
+
+// Profile returns the profile of the player, or a default one if they did not set it
+func (s GameSession) Profile(player engine.Player) PlayerProfile {
+	if profile, ok := s.profiles[player]; ok {
+		return profile
+	}
+
+	return DefaultProfile(player)
+}
+
+// SetAccount ties the player to a user account. Tokens issued for the player
+// from now on carry the username.
+func (s GameSession) SetAccount(player engine.Player, username string) {
+	s.accounts[player] = username
+}
+
+// Account returns the username of the player, or "" if they play anonymously
+func (s GameSession) Account(player engine.Player) string {
+	return s.accounts[player]
+}
+
+var DefaultColors = []string{
+	"#f26419",
+	"#33658a",
+	"#f6ae2d",
+	"#86bbd8",
+	"#55a630",
+	"#9d4edd",
+	"#e63946",
+	"#2a9d8f",
+}
+
+func DefaultProfile(player engine.Player) PlayerProfile {
+	colorIndex := (int(player) - 1) % len(DefaultColors)
+	if colorIndex < 0 {
+		colorIndex += len(DefaultColors)
+	}
+
+	return PlayerProfile{
+		Name:  fmt.Sprintf("Player %d", player),
+		Color: DefaultColors[colorIndex],
 	}
 }
 
 func (s GameSession) NewTokenForPlayer(player engine.Player) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"nonce":  s.nonce,
-		"player": player,
+	return s.NewTokenWithRole(player, RolePlayer)
+}
+
+// NewTokenWithRole issues a token for the player with the given role. For
+// spectators, player must be 0.
+func (s GameSession) NewTokenWithRole(player engine.Player, role Role) (string, error) {
+	return s.newToken(player, role, time.Now())
+}
+
+func (s GameSession) newToken(player engine.Player, role Role, issuedAt time.Time) (string, error) {
+	id, err := GenerateNonce(tokenIdBitLength)
+	if err != nil {
+		return "", err
+	}
+
+	var profile PlayerProfile
+	var account string
+	if role != RoleSpectator {
+		profile = s.Profile(player)
+		account = s.Account(player)
+	}
+	expiresAt := issuedAt.Add(TokenLifetime).Unix()
+
+	token := jwt.NewWithClaims(currentSigner.method, CadereClaims{
+		Nonce:   s.nonce,
+		Player:  player,
+		Role:    role,
+		Name:    profile.Name,
+		Color:   profile.Color,
+		Account: account,
+		StandardClaims: jwt.StandardClaims{
+			Id:        id,
+			IssuedAt:  issuedAt.Unix(),
+			ExpiresAt: expiresAt,
+		},
 	})
 
-	return token.SignedString(hmacSecret)
+	if currentSigner.keyId != "" {
+		token.Header["kid"] = currentSigner.keyId
+	}
+
+	tokenString, err := token.SignedString(currentSigner.signKey)
+	if err != nil {
+		return "", err
+	}
+
+	s.tokens.add(player, id, expiresAt)
+	return tokenString, nil
 }
 
-func parseToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+func parseToken(tokenString string) (*CadereClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &CadereClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != currentSigner.method {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 
-		return hmacSecret, nil
+		return currentSigner.verifyKey, nil
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+	if claims, ok := token.Claims.(*CadereClaims); ok && token.Valid {
 		return claims, nil
 	}
 
@@ -66,31 +315,98 @@ func ExtractNonceFromToken(tokenString string) (string, error) {
 		return "", err
 	}
 
-	nonce, ok := claims["nonce"].(string)
-	if !ok {
-		return "", fmt.Errorf("invalid token")
+	return claims.Nonce, nil
+}
+
+// parseSessionToken parses a token, making sure it belongs to this session
+// and was not revoked
+func (s GameSession) parseSessionToken(tokenString string) (*CadereClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
 	}
 
-	return nonce, nil
+	if claims.Nonce != s.nonce {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if s.tokens.isRevoked(claims.Id) {
+		return nil, ErrorTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// ExtractClaimsFromToken returns the claims of a valid token of this session
+func (s GameSession) ExtractClaimsFromToken(tokenString string) (*CadereClaims, error) {
+	return s.parseSessionToken(tokenString)
 }
 
 func (s GameSession) ExtractPlayerFromToken(tokenString string) (engine.Player, error) {
-	claims, err := parseToken(tokenString)
+	claims, err := s.parseSessionToken(tokenString)
 	if err != nil {
 		return 0, err
 	}
 
-	playerId, ok := claims["player"].(float64)
-	if !ok {
-		return 0, fmt.Errorf("invalid token")
+	if claims.Role == RoleSpectator {
+		return 0, fmt.Errorf("spectator token")
+	}
+
+	return claims.Player, nil
+}
+
+// RevokeToken makes the token invalid for this session
+func (s GameSession) RevokeToken(tokenString string) error {
+	claims, err := s.parseSessionToken(tokenString)
+	if err != nil {
+		return err
+	}
+
+	s.tokens.revoke(claims.Player, claims.Id, claims.ExpiresAt)
+	return nil
+}
+
+// RevokePlayer makes all the tokens issued for the player invalid
+func (s GameSession) RevokePlayer(player engine.Player) {
+	s.tokens.revokePlayer(player)
+}
+
+// ShouldRefreshToken returns whether the token is close enough to expiring
+// to be refreshed
+func ShouldRefreshToken(tokenString string) bool {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return false
+	}
+
+	return time.Until(time.Unix(claims.ExpiresAt, 0)) < TokenRefreshWindow
+}
+
+// RefreshToken issues a new token for the player of a valid token, and
+// revokes the old one. Used to keep long games going past the token lifetime.
+func (s GameSession) RefreshToken(tokenString string) (string, error) {
+	claims, err := s.parseSessionToken(tokenString)
+	if err != nil {
+		return "", err
 	}
 
-	nonce, ok := claims["nonce"].(string)
-	if !ok || nonce != s.nonce {
-		return 0, fmt.Errorf("invalid token")
+	newToken, err := s.NewTokenWithRole(claims.Player, claims.Role)
+	if err != nil {
+		return "", err
+	}
+
+	s.tokens.revoke(claims.Player, claims.Id, claims.ExpiresAt)
+	return newToken, nil
+}
+
+// ExtractProfileFromToken returns the profile the player had when the token was issued
+func ExtractProfileFromToken(tokenString string) (PlayerProfile, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return PlayerProfile{}, err
 	}
 
-	return engine.Player(playerId), nil
+	return PlayerProfile{Name: claims.Name, Color: claims.Color}, nil
 }
 
 func GenerateNonce(bitLength int) (string, error) {
@@ -1,8 +1,14 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/Denloob/cadere/engine"
@@ -31,3 +37,223 @@ func TestInvalidToken(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestProfileInToken(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+	session.SetProfile(2, PlayerProfile{Name: "Alice", Color: "#123456"})
+
+	token, err := session.NewTokenForPlayer(2)
+	assert.NoError(t, err)
+
+	profile, err := ExtractProfileFromToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, PlayerProfile{Name: "Alice", Color: "#123456"}, profile)
+
+	token, err = session.NewTokenForPlayer(3)
+	assert.NoError(t, err)
+
+	profile, err = ExtractProfileFromToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultProfile(3), profile)
+}
+
+func TestAccountInToken(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+	session.SetAccount(1, "alice")
+
+	token, err := session.NewTokenForPlayer(1)
+	assert.NoError(t, err)
+
+	claims, err := session.ExtractClaimsFromToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", claims.Account)
+
+	token, err = session.NewTokenForPlayer(2)
+	assert.NoError(t, err)
+
+	claims, err = session.ExtractClaimsFromToken(token)
+	assert.NoError(t, err)
+	assert.Empty(t, claims.Account)
+}
+
+func TestExpiredToken(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+
+	token, err := session.newToken(1, RolePlayer, time.Now().Add(-TokenLifetime-time.Minute))
+	assert.NoError(t, err)
+
+	_, err = session.ExtractPlayerFromToken(token)
+	assert.Error(t, err)
+}
+
+func TestRevokedToken(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+
+	token1, err := session.NewTokenForPlayer(1)
+	assert.NoError(t, err)
+	token2, err := session.NewTokenForPlayer(1)
+	assert.NoError(t, err)
+	otherToken, err := session.NewTokenForPlayer(2)
+	assert.NoError(t, err)
+
+	assert.NoError(t, session.RevokeToken(token1))
+	_, err = session.ExtractPlayerFromToken(token1)
+	assert.ErrorIs(t, err, ErrorTokenRevoked)
+	_, err = session.ExtractPlayerFromToken(token2)
+	assert.NoError(t, err)
+
+	session.RevokePlayer(1)
+	_, err = session.ExtractPlayerFromToken(token2)
+	assert.ErrorIs(t, err, ErrorTokenRevoked)
+	_, err = session.ExtractPlayerFromToken(otherToken)
+	assert.NoError(t, err)
+}
+
+func TestRefreshToken(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+
+	oldToken, err := session.newToken(3, RolePlayer, time.Now().Add(-TokenRefreshWindow-time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, ShouldRefreshToken(oldToken))
+
+	newToken, err := session.RefreshToken(oldToken)
+	assert.NoError(t, err)
+	assert.False(t, ShouldRefreshToken(newToken))
+
+	player, err := session.ExtractPlayerFromToken(newToken)
+	assert.NoError(t, err)
+	assert.Equal(t, engine.Player(3), player)
+
+	_, err = session.ExtractPlayerFromToken(oldToken)
+	assert.ErrorIs(t, err, ErrorTokenRevoked)
+}
+
+func validMapClaims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"nonce":  nonce,
+		"player": 1,
+		"role":   string(RolePlayer),
+		"jti":    "id",
+		"iat":    now.Unix(),
+		"exp":    now.Add(time.Hour).Unix(),
+	}
+}
+
+func signMapClaims(t *testing.T, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(hmacSecret)
+	assert.NoError(t, err)
+	return token
+}
+
+func TestClaims(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+
+	token, err := session.NewTokenWithRole(2, RoleHost)
+	assert.NoError(t, err)
+
+	claims, err := session.ExtractClaimsFromToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", claims.Nonce)
+	assert.Equal(t, engine.Player(2), claims.Player)
+	assert.Equal(t, RoleHost, claims.Role)
+	assert.NotEmpty(t, claims.Id)
+	assert.Equal(t, int64(TokenLifetime.Seconds()), claims.ExpiresAt-claims.IssuedAt)
+
+	assert.NoError(t, claims.Valid())
+	_, err = session.ExtractPlayerFromToken(signMapClaims(t, validMapClaims("test")))
+	assert.NoError(t, err)
+}
+
+func TestSpectatorToken(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+
+	token, err := session.NewTokenWithRole(0, RoleSpectator)
+	assert.NoError(t, err)
+
+	claims, err := session.ExtractClaimsFromToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, RoleSpectator, claims.Role)
+
+	_, err = session.ExtractPlayerFromToken(token)
+	assert.Error(t, err)
+}
+
+func TestInvalidClaims(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+
+	tests := map[string]func(jwt.MapClaims){
+		"negative player":   func(c jwt.MapClaims) { c["player"] = -1 },
+		"zero player":       func(c jwt.MapClaims) { c["player"] = 0 },
+		"fractional player": func(c jwt.MapClaims) { c["player"] = 1.5 },
+		"string player":     func(c jwt.MapClaims) { c["player"] = "1" },
+		"spectator player":  func(c jwt.MapClaims) { c["role"] = string(RoleSpectator) },
+		"unknown role":      func(c jwt.MapClaims) { c["role"] = "admin" },
+		"missing role":      func(c jwt.MapClaims) { delete(c, "role") },
+		"missing nonce":     func(c jwt.MapClaims) { delete(c, "nonce") },
+		"missing id":        func(c jwt.MapClaims) { delete(c, "jti") },
+		"missing exp":       func(c jwt.MapClaims) { delete(c, "exp") },
+		"missing iat":       func(c jwt.MapClaims) { delete(c, "iat") },
+		"expired":           func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"issued in future":  func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() },
+		"lifetime too long": func(c jwt.MapClaims) { c["exp"] = time.Now().Add(2 * TokenLifetime).Unix() },
+	}
+
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims := validMapClaims("test")
+			modify(claims)
+
+			_, err := session.ExtractPlayerFromToken(signMapClaims(t, claims))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMalformedToken(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+
+	for _, token := range []string{"", "abc", "a.b.c", "a.b", "...."} {
+		_, err := session.ExtractPlayerFromToken(token)
+		assert.Error(t, err, token)
+	}
+}
+
+func TestTamperedToken(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+
+	token, err := session.NewTokenForPlayer(1)
+	assert.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+
+	tamperedPayload := strings.Replace(string(payload), `"player":1`, `"player":2`, 1)
+	assert.NotEqual(t, string(payload), tamperedPayload)
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(tamperedPayload))
+
+	_, err = session.ExtractPlayerFromToken(strings.Join(parts, "."))
+	assert.Error(t, err)
+}
+
+func TestWrongAlgorithm(t *testing.T) {
+	session := NewGameSession(&engine.Game{}, "test")
+
+	noneToken, err := jwt.NewWithClaims(jwt.SigningMethodNone, validMapClaims("test")).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+	_, err = session.ExtractPlayerFromToken(noneToken)
+	assert.Error(t, err)
+
+	hs512Token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, validMapClaims("test")).SignedString(hmacSecret)
+	assert.NoError(t, err)
+	_, err = session.ExtractPlayerFromToken(hs512Token)
+	assert.Error(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	rs256Token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, validMapClaims("test")).SignedString(rsaKey)
+	assert.NoError(t, err)
+	_, err = session.ExtractPlayerFromToken(rs256Token)
+	assert.Error(t, err)
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const rsaMinKeyBits = 2048
+
+// signer holds the signing method and keys used for all the tokens
+type signer struct {
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	// keyId is sent in the kid header, so verifiers can pick the right key
+	// from the JWKS. Empty for HMAC.
+	keyId string
+}
+
+var currentSigner = signer{
+	method:    jwt.SigningMethodHS256,
+	signKey:   hmacSecret,
+	verifyKey: hmacSecret,
+}
+
+// UseHMAC signs the tokens with the process-local secret. This is the default.
+func UseHMAC() {
+	currentSigner = signer{
+		method:    jwt.SigningMethodHS256,
+		signKey:   hmacSecret,
+		verifyKey: hmacSecret,
+	}
+}
+
+// UseSigningKey signs the tokens with an Ed25519 (EdDSA) or RSA (RS256)
+// private key. Must be called before any token is issued, as tokens signed
+// with the previous key stop being valid.
+func UseSigningKey(key crypto.Signer) error {
+	var method jwt.SigningMethod
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < rsaMinKeyBits {
+			return fmt.Errorf("RSA key must be at least %d bits", rsaMinKeyBits)
+		}
+		method = jwt.SigningMethodRS256
+	default:
+		return errors.New("unsupported key type")
+	}
+
+	keyId, err := publicKeyId(key.Public())
+	if err != nil {
+		return err
+	}
+
+	currentSigner = signer{
+		method:    method,
+		signKey:   key,
+		verifyKey: key.Public(),
+		keyId:     keyId,
+	}
+	return nil
+}
+
+// GenerateSigningKey generates a new key for the algorithm (EdDSA or RS256)
+// and signs the tokens with it
+func GenerateSigningKey(algorithm string) error {
+	switch algorithm {
+	case jwt.SigningMethodEdDSA.Alg():
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		return UseSigningKey(key)
+	case jwt.SigningMethodRS256.Alg():
+		key, err := rsa.GenerateKey(rand.Reader, rsaMinKeyBits)
+		if err != nil {
+			return err
+		}
+		return UseSigningKey(key)
+	}
+
+	return fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+}
+
+// UseSigningKeyFromPEM signs the tokens with a PEM encoded PKCS #8 or
+// PKCS #1 private key
+func UseSigningKeyFromPEM(pemBytes []byte) error {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return errors.New("no PEM data found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if rsaErr != nil {
+			return err
+		}
+		key = rsaKey
+	}
+
+	signerKey, ok := key.(crypto.Signer)
+	if !ok {
+		return errors.New("unsupported key type")
+	}
+
+	return UseSigningKey(signerKey)
+}
+
+func publicKeyId(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(hash[:16]), nil
+}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyId     string `json:"kid"`
+
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicKeys returns the keys which verify the tokens. When signing with
+// HMAC there are no public keys, so the set is empty.
+func PublicKeys() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	jwk := JWK{
+		Use:       "sig",
+		Algorithm: currentSigner.method.Alg(),
+		KeyId:     currentSigner.keyId,
+	}
+
+	switch key := currentSigner.verifyKey.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	default:
+		return set
+	}
+
+	set.Keys = append(set.Keys, jwk)
+	return set
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+// verifyWithJWKS verifies the token the way an external service would, using
+// only the published keys
+func verifyWithJWKS(t *testing.T, tokenString string) error {
+	set := PublicKeys()
+
+	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		for _, jwk := range set.Keys {
+			if jwk.KeyId != token.Header["kid"] || jwk.Algorithm != token.Method.Alg() {
+				continue
+			}
+
+			switch jwk.KeyType {
+			case "OKP":
+				x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+				assert.NoError(t, err)
+				return ed25519.PublicKey(x), nil
+			case "RSA":
+				n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+				assert.NoError(t, err)
+				e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+				assert.NoError(t, err)
+				return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+			}
+		}
+
+		return nil, jwt.ErrInvalidKey
+	})
+
+	return err
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	defer UseHMAC()
+
+	for _, algorithm := range []string{"EdDSA", "RS256"} {
+		t.Run(algorithm, func(t *testing.T) {
+			assert.NoError(t, GenerateSigningKey(algorithm))
+
+			session := NewGameSession(&engine.Game{}, "test")
+			token, err := session.NewTokenForPlayer(1)
+			assert.NoError(t, err)
+
+			player, err := session.ExtractPlayerFromToken(token)
+			assert.NoError(t, err)
+			assert.Equal(t, engine.Player(1), player)
+
+			assert.Len(t, PublicKeys().Keys, 1)
+			assert.NoError(t, verifyWithJWKS(t, token))
+		})
+	}
+}
+
+func TestHMACHasNoPublicKeys(t *testing.T) {
+	UseHMAC()
+	assert.Empty(t, PublicKeys().Keys)
+}
+
+func TestSigningKeyFromPEM(t *testing.T) {
+	defer UseHMAC()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, UseSigningKeyFromPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+	assert.Equal(t, "EdDSA", PublicKeys().Keys[0].Algorithm)
+
+	assert.Error(t, UseSigningKeyFromPEM([]byte("not a key")))
+}
+
+func TestHMACTokenRejectedWithAsymmetricKey(t *testing.T) {
+	defer UseHMAC()
+
+	UseHMAC()
+	session := NewGameSession(&engine.Game{}, "test")
+	hmacToken, err := session.NewTokenForPlayer(1)
+	assert.NoError(t, err)
+
+	assert.NoError(t, GenerateSigningKey("EdDSA"))
+	_, err = session.ExtractPlayerFromToken(hmacToken)
+	assert.Error(t, err)
+}
+
+func TestWeakRSAKey(t *testing.T) {
+	defer UseHMAC()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	assert.Error(t, UseSigningKey(key))
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/archive"
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/render"
+)
+
+// GIF_FRAME_DELAY is the time each position of an animated game is shown
+// for, in hundredths of a second
+const GIF_FRAME_DELAY = 60
+
+// OpenGraph is the preview of a page when its link is shared
+type OpenGraph struct {
+	Title       string
+	Description string
+	Image       string
+}
+
+// openGraphProvider is implemented by the views of pages with a link preview
+type openGraphProvider interface {
+	OpenGraph() OpenGraph
+}
+
+// openGraphFor returns the link preview of the page rendered with data, if it
+// has one
+func openGraphFor(data any) *OpenGraph {
+	provider, ok := data.(openGraphProvider)
+	if !ok {
+		return nil
+	}
+
+	openGraph := provider.OpenGraph()
+	return &openGraph
+}
+
+// absoluteUrl returns the URL of the path on the server that handles the request
+func absoluteUrl(c echo.Context, path string) string {
+	return fmt.Sprintf("%s://%s%s", c.Scheme(), c.Request().Host, path)
+}
+
+// gameNonce converts an ID from gameUrlId back to the nonce of the game
+func gameNonce(urlId string) string {
+	raw, err := base64.RawURLEncoding.DecodeString(urlId)
+	if err != nil {
+		return urlId
+	}
+
+	return base64.URLEncoding.EncodeToString(raw)
+}
+
+// gamePositions is a game, live or archived, which can be drawn
+type gamePositions struct {
+	Width, Height int
+	Players       []engine.Player
+	History       []engine.Event
+	Colors        render.Colors
+
+	// Current is the latest position. It is drawn on its own when the game
+	// has not left the lobby, so it has no history to replay.
+	Current engine.Board
+}
+
+func colorsOf(players []engine.Player, color func(engine.Player) string) render.Colors {
+	colors := render.Colors{}
+	for _, player := range players {
+		if c, err := render.ParseHexColor(color(player)); err == nil {
+			colors[player] = c
+		}
+	}
+
+	return colors
+}
+
+func archivedPositions(record archive.Record) gamePositions {
+	players := record.StartingPlayers()
+
+	board := engine.NewBoard(record.Width, record.Height)
+	if positions, err := record.Replay(); err == nil {
+		board = positions[len(positions)-1].Board
+	}
+
+	return gamePositions{
+		Width:   record.Width,
+		Height:  record.Height,
+		Players: players,
+		History: record.History,
+		Colors: colorsOf(players, func(player engine.Player) string {
+			playerRecord, _ := record.Player(player)
+			return playerRecord.Color
+		}),
+		Current: board,
+	}
+}
+
+// livePositions copies what is needed to draw the game, so it can be drawn
+// without holding its lock
+func livePositions(webSession *WebGameSession) gamePositions {
+	webSession.SessionMutex.RLock()
+	defer webSession.SessionMutex.RUnlock()
+
+	session := webSession.Session
+	game := session.Game.Clone()
+
+	players := game.StartingPlayers()
+	if game.Stage() == engine.StageLobby {
+		players = game.Players()
+	}
+
+	return gamePositions{
+		Width:   len(game.Board[0]),
+		Height:  len(game.Board),
+		Players: players,
+		History: game.History(),
+		Colors: colorsOf(players, func(player engine.Player) string {
+			return session.Profile(player).Color
+		}),
+		Current: game.Board,
+	}
+}
+
+// findGamePositions looks the game up by its URL ID, preferring the archive
+// so finished games can be drawn after they are cleaned up
+func findGamePositions(urlId string) (gamePositions, bool) {
+	if record, ok := archiveStore.Get(urlId); ok {
+		return archivedPositions(record), true
+	}
+
+	webSession, ok := games.Get(gameNonce(urlId))
+	if !ok {
+		return gamePositions{}, false
+	}
+
+	return livePositions(webSession), true
+}
+
+// Boards returns the board after every event of the game
+func (p gamePositions) Boards() ([]engine.Board, error) {
+	if len(p.History) == 0 {
+		return []engine.Board{p.Current}, nil
+	}
+
+	positions, err := engine.Replay(p.Width, p.Height, p.Players, p.History)
+	if err != nil {
+		return nil, err
+	}
+
+	boards := make([]engine.Board, 0, len(positions))
+	for _, position := range positions {
+		boards = append(boards, position.Board)
+	}
+
+	return boards, nil
+}
+
+// Board returns the board after the given number of events. A missing move
+// means the current position.
+func (p gamePositions) Board(move string) (engine.Board, error) {
+	if move == "" {
+		return p.Current, nil
+	}
+
+	n, err := strconv.Atoi(move)
+	if err != nil {
+		return nil, err
+	}
+
+	boards, err := p.Boards()
+	if err != nil {
+		return nil, err
+	}
+
+	return boards[max(0, min(n, len(boards)-1))], nil
+}
+
+func registerBoardImageRoutes(e *echo.Echo) {
+	renderBoard := func(c echo.Context, contentType string, encode func(*bytes.Buffer, engine.Board, render.Colors) error) error {
+		positions, ok := findGamePositions(c.Param("id"))
+		if !ok {
+			return c.NoContent(http.StatusNotFound)
+		}
+
+		board, err := positions.Board(c.QueryParam("move"))
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		var buf bytes.Buffer
+		if err := encode(&buf, board, positions.Colors); err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		c.Response().Header().Set("Cache-Control", "no-cache")
+		return c.Blob(http.StatusOK, contentType, buf.Bytes())
+	}
+
+	e.GET("/game/:id/board.png", func(c echo.Context) error {
+		return renderBoard(c, "image/png", func(buf *bytes.Buffer, board engine.Board, colors render.Colors) error {
+			return render.PNG(buf, board, colors)
+		})
+	})
+
+	e.GET("/game/:id/board.svg", func(c echo.Context) error {
+		return renderBoard(c, "image/svg+xml", func(buf *bytes.Buffer, board engine.Board, colors render.Colors) error {
+			return render.SVG(buf, board, colors)
+		})
+	})
+
+	e.GET("/game/:id/game.gif", func(c echo.Context) error {
+		positions, ok := findGamePositions(c.Param("id"))
+		if !ok {
+			return c.NoContent(http.StatusNotFound)
+		}
+
+		boards, err := positions.Boards()
+		if err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		var buf bytes.Buffer
+		if err := render.GIF(&buf, boards, positions.Colors, GIF_FRAME_DELAY); err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.Blob(http.StatusOK, "image/gif", buf.Bytes())
+	})
+}
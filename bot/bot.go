@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+var ErrorNoMoves = errors.New("no legal moves")
+
+// Bot chooses a move for the current player of a game.
+type Bot interface {
+	Move(game engine.Game) (engine.Move, error)
+}
+
+// constructors are the bots which can be created by name
+var constructors = map[string]func() Bot{
+	"random": func() Bot { return Random{} },
+	"greedy": func() Bot { return Greedy{} },
+	"perfect": func() Bot {
+		return Perfect{MaxPositions: PerfectMaxPositions}
+	},
+}
+
+// New creates the bot with the given name
+func New(name string) (Bot, error) {
+	constructor, ok := constructors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown bot: %s", name)
+	}
+
+	return constructor(), nil
+}
+
+// Names returns the names of the bots New can create, sorted
+func Names() []string {
+	names := make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Random plays a uniformly random legal move.
+type Random struct{}
+
+func (Random) Move(game engine.Game) (engine.Move, error) {
+	moves := game.LegalMoves()
+	if len(moves) == 0 {
+		return engine.Move{}, ErrorNoMoves
+	}
+
+	return moves[rand.Intn(len(moves))], nil
+}
+
+// Greedy looks one move ahead. It wins when it can, and otherwise plays the
+// move which leaves it with the most tiles compared to its strongest
+// opponent. Ties are broken randomly.
+type Greedy struct{}
+
+func (Greedy) Move(game engine.Game) (engine.Move, error) {
+	moves := game.LegalMoves()
+	if len(moves) == 0 {
+		return engine.Move{}, ErrorNoMoves
+	}
+
+	player := game.CurrentPlayer()
+	bestScore := math.Inf(-1)
+	var best []engine.Move
+	for _, move := range moves {
+		next := game.Clone()
+		if err := next.Play(player, move); err != nil {
+			continue
+		}
+
+		score := greedyScore(next, player)
+		switch {
+		case score > bestScore:
+			bestScore = score
+			best = []engine.Move{move}
+		case score == bestScore:
+			best = append(best, move)
+		}
+	}
+
+	if len(best) == 0 {
+		return engine.Move{}, ErrorNoMoves
+	}
+
+	return best[rand.Intn(len(best))], nil
+}
+
+// greedyScore rates the position for the player
+func greedyScore(game engine.Game, player engine.Player) float64 {
+	if game.Stage() == engine.StageOver {
+		if winner, err := game.Winner(); err == nil {
+			if winner == player {
+				return math.Inf(1)
+			}
+			return math.Inf(-1)
+		}
+		return 0
+	}
+
+	tiles := map[engine.Player]int{}
+	for _, row := range game.Board {
+		for _, tile := range row {
+			if owner, err := tile.ToPlayer(); err == nil {
+				tiles[owner]++
+			}
+		}
+	}
+
+	strongestOpponent := 0
+	for owner, count := range tiles {
+		if owner != player {
+			strongestOpponent = max(strongestOpponent, count)
+		}
+	}
+
+	return float64(tiles[player] - strongestOpponent)
+}
+
+// PerfectMaxPositions keeps the moves of the perfect bot under a tenth of a
+// second
+const PerfectMaxPositions = 20000
+
+// Perfect plays the best move with perfect play when the solver can solve the
+// position in MaxPositions, and like Greedy otherwise. Of equally good moves,
+// it picks one randomly.
+type Perfect struct {
+	MaxPositions int
+}
+
+func (p Perfect) Move(game engine.Game) (engine.Move, error) {
+	solution, err := engine.Solver{MaxPositions: p.MaxPositions}.Solve(game)
+	if err != nil {
+		return Greedy{}.Move(game)
+	}
+
+	best := solution.BestMoves()
+	if len(best) == 0 {
+		return engine.Move{}, ErrorNoMoves
+	}
+
+	return best[rand.Intn(len(best))], nil
+}
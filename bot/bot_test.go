@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+func TestRandomPlaysLegalMoves(t *testing.T) {
+	game := engine.NewGame(engine.NewBoard(3, 3))
+	game.AddPlayers(1, 2)
+	game.ProgressStage()
+
+	for i := 0; i < 100 && game.Stage() != engine.StageOver; i++ {
+		move, err := Random{}.Move(game)
+		assert.NoError(t, err)
+		assert.NoError(t, game.Play(game.CurrentPlayer(), move))
+	}
+}
+
+func TestRandomNoMoves(t *testing.T) {
+	game := engine.NewGame(engine.NewBoard(3, 3))
+	game.AddPlayers(1)
+
+	_, err := Random{}.Move(game)
+	assert.ErrorIs(t, err, ErrorNoMoves)
+}
+
+func TestGreedyWins(t *testing.T) {
+	game := engine.NewGame(engine.NewBoard(3, 3))
+	game.AddPlayers(1, 2)
+	game.ProgressStage()
+	game.ProgressStage()
+	game.Board[0][0] = engine.Player(2).ToTile()
+	game.Board[2][2] = engine.Player(1).ToTile()
+
+	move, err := Greedy{}.Move(game)
+	assert.NoError(t, err)
+	assert.NoError(t, game.Play(1, move))
+
+	winner, err := game.Winner()
+	assert.NoError(t, err)
+	assert.Equal(t, engine.Player(1), winner)
+}
+
+func TestGreedyPlaysLegalMoves(t *testing.T) {
+	game := engine.NewGame(engine.NewBoard(4, 4))
+	game.AddPlayers(1, 2, 3)
+	game.ProgressStage()
+
+	for i := 0; i < 100 && game.Stage() != engine.StageOver; i++ {
+		move, err := Greedy{}.Move(game)
+		assert.NoError(t, err)
+		assert.NoError(t, game.Play(game.CurrentPlayer(), move))
+	}
+}
+
+func TestPerfectWinsSolvedPositions(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	played := 0
+	for played < 5 {
+		game := engine.NewGame(engine.NewBoard(3, 3))
+		game.AddPlayers(1, 2)
+		game.ProgressStage()
+		for game.Stage() == engine.StageInit && game.Board.CountNonEmptyTiles() < 4 {
+			game.Play(game.CurrentPlayer(), randomMove(random, game))
+		}
+		if game.Stage() == engine.StageInit {
+			game.ProgressStage()
+		}
+
+		solution, err := engine.Solve(game)
+		assert.NoError(t, err)
+		if solution.Winner != game.CurrentPlayer() {
+			continue
+		}
+		played++
+
+		for plies := 0; plies < solution.Plies; plies++ {
+			var move engine.Move
+			if game.CurrentPlayer() == solution.Winner {
+				move, err = Perfect{MaxPositions: PerfectMaxPositions}.Move(game)
+			} else {
+				move, err = Greedy{}.Move(game)
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, game.Play(game.CurrentPlayer(), move))
+			if game.Stage() == engine.StageOver {
+				break
+			}
+		}
+
+		winner, err := game.Winner()
+		assert.NoError(t, err)
+		assert.Equal(t, solution.Winner, winner)
+	}
+}
+
+// randomMove returns a random legal move
+func randomMove(random *rand.Rand, game engine.Game) engine.Move {
+	moves := game.LegalMoves()
+	return moves[random.Intn(len(moves))]
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range Names() {
+		_, err := New(name)
+		assert.NoError(t, err)
+	}
+
+	_, err := New("unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,586 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/accounts"
+	"github.com/Denloob/cadere/api"
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/clock"
+	"github.com/Denloob/cadere/engine"
+)
+
+const (
+	// BOT_MOVE_TIME_LIMIT is how long a bot has to move before the server
+	// plays a random move for it, so that a stuck or disconnected bot can't
+	// stall the game
+	BOT_MOVE_TIME_LIMIT = 10 * time.Second
+
+	BOT_KEEPALIVE_INTERVAL = 30 * time.Second
+	// BOT_EVENT_BUFFER is how many events a bot client can fall behind before
+	// it is disconnected. It gets a fresh snapshot when it reconnects.
+	BOT_EVENT_BUFFER = 64
+
+	botAccountContextKey = "botAccount"
+)
+
+var ErrorBotNotInGame = errors.New("The bot is not playing in this game")
+
+// botClient is a connected bot program. It receives the events of all the
+// games of its bot.
+type botClient struct {
+	events   chan api.BotEvent
+	dropped  chan struct{}
+	dropOnce sync.Once
+}
+
+func (c *botClient) drop() {
+	c.dropOnce.Do(func() { close(c.dropped) })
+}
+
+// send queues the event, dropping the client if it fell too far behind
+func (c *botClient) send(event api.BotEvent) {
+	select {
+	case c.events <- event:
+	default:
+		c.drop()
+	}
+}
+
+// botSeat is a bot playing in a game. It is one of the sockets of the game,
+// and turns the screens pushed to it into events for the bot clients.
+type botSeat struct {
+	username   string
+	webSession *WebGameSession
+	player     engine.Player
+
+	conn   *notifyConn
+	socket *GameSocket
+
+	// lastEvent is the last event of the game, sent to the clients which
+	// connect later. Guarded by the mutex of the BotHub
+	lastEvent     api.BotEvent
+	lastEventJSON []byte
+}
+
+// BotHub keeps the connected bot clients and the games the bots play in,
+// both by the normalized username of the bot
+type BotHub struct {
+	mutex   sync.Mutex
+	clients map[string][]*botClient
+	seats   map[string][]*botSeat
+}
+
+var botHub = NewBotHub()
+
+func NewBotHub() *BotHub {
+	return &BotHub{
+		clients: make(map[string][]*botClient),
+		seats:   make(map[string][]*botSeat),
+	}
+}
+
+func botKey(username string) string {
+	return strings.ToLower(username)
+}
+
+// subscribe connects a client of the bot, and sends it the current state of
+// all the games of the bot
+func (h *BotHub) subscribe(username string) *botClient {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	client := &botClient{
+		events:  make(chan api.BotEvent, BOT_EVENT_BUFFER),
+		dropped: make(chan struct{}),
+	}
+
+	key := botKey(username)
+	h.clients[key] = append(h.clients[key], client)
+	for _, seat := range h.seats[key] {
+		if seat.lastEvent.Type != "" {
+			client.send(seat.lastEvent)
+		}
+	}
+
+	return client
+}
+
+func (h *BotHub) unsubscribe(username string, client *botClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := botKey(username)
+	var clients []*botClient
+	for _, currClient := range h.clients[key] {
+		if currClient != client {
+			clients = append(clients, currClient)
+		}
+	}
+	h.clients[key] = clients
+}
+
+// publish sends the event of the seat to all the clients of its bot, unless
+// nothing has changed since the last one
+func (h *BotHub) publish(seat *botSeat, event api.BotEvent) {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if bytes.Equal(eventJSON, seat.lastEventJSON) {
+		return
+	}
+	seat.lastEvent = event
+	seat.lastEventJSON = eventJSON
+
+	for _, client := range h.clients[botKey(seat.username)] {
+		client.send(event)
+	}
+}
+
+func (h *BotHub) addSeat(seat *botSeat) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := botKey(seat.username)
+	h.seats[key] = append(h.seats[key], seat)
+}
+
+func (h *BotHub) removeSeat(seat *botSeat) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := botKey(seat.username)
+	var seats []*botSeat
+	for _, currSeat := range h.seats[key] {
+		if currSeat != seat {
+			seats = append(seats, currSeat)
+		}
+	}
+	h.seats[key] = seats
+}
+
+// seat returns the seat of the bot in the game with the nonce
+func (h *BotHub) seat(username string, nonce string) (*botSeat, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, seat := range h.seats[botKey(username)] {
+		if seat.webSession.Session.Nonce() == nonce {
+			return seat, true
+		}
+	}
+
+	return nil, false
+}
+
+// states returns the last known state of every game of the bot
+func (h *BotHub) states(username string) []api.GameState {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	states := []api.GameState{}
+	for _, seat := range h.seats[botKey(username)] {
+		if seat.lastEvent.State != nil {
+			states = append(states, *seat.lastEvent.State)
+		}
+	}
+
+	return states
+}
+
+// seatBot makes the bot play for the player, which already joined the game.
+// The caller must hold the SessionMutex.
+func seatBot(username string, webSession *WebGameSession, player engine.Player) {
+	conn := newNotifyConn()
+	seat := &botSeat{
+		username:   username,
+		webSession: webSession,
+		player:     player,
+		conn:       conn,
+		socket:     &GameSocket{GameConn: conn, Player: player},
+	}
+
+	webSession.AddSocket(seat.socket)
+	botHub.addSeat(seat)
+	go seat.watch()
+}
+
+// watch publishes the game whenever it changes, until it is over, and arms
+// the move timer on the turns of the bot
+func (s *botSeat) watch() {
+	defer botHub.removeSeat(s)
+	defer s.webSession.RemoveSocket(s.socket)
+	defer s.conn.Close()
+
+	armedTurn := -1
+	for {
+		s.webSession.SessionMutex.RLock()
+		isMember := s.webSession.IsMember(s.player)
+		state := s.webSession.gameState(s.player)
+		s.webSession.SessionMutex.RUnlock()
+
+		event := api.BotEvent{Type: api.EventGame, GameId: state.GameId, State: &state}
+		switch {
+		case !isMember:
+			event.Type = api.EventGameOver
+			event.Message = "The bot was removed from the game"
+		case state.Stage == api.StageOver:
+			event.Type = api.EventGameOver
+		case state.YourTurn():
+			event.Type = api.EventYourTurn
+			event.MoveTimeLimitMs = BOT_MOVE_TIME_LIMIT.Milliseconds()
+
+			if armedTurn != state.Moves {
+				armedTurn = state.Moves
+				time.AfterFunc(BOT_MOVE_TIME_LIMIT, func() { s.timeOut(state.Moves) })
+			}
+		}
+
+		botHub.publish(s, event)
+		if event.Type == api.EventGameOver {
+			return
+		}
+
+		select {
+		case <-s.conn.updates:
+		case <-s.conn.closed:
+			botHub.publish(s, api.BotEvent{
+				Type:    api.EventGameOver,
+				GameId:  state.GameId,
+				State:   &state,
+				Message: "The game was closed",
+			})
+			return
+		}
+	}
+}
+
+// timeOut plays a random move for the bot if it still hasn't moved since the
+// given number of moves was played
+func (s *botSeat) timeOut(moves int) {
+	webSession := s.webSession
+	webSession.SessionMutex.Lock()
+
+	game := webSession.Session.Game
+	inProgress := game.Stage() == engine.StageInit || game.Stage() == engine.StatePlaying
+	if !inProgress || game.CurrentPlayer() != s.player || len(game.History()) != moves {
+		webSession.SessionMutex.Unlock()
+		return
+	}
+
+	now := time.Now()
+	response, err := webSession.playTimedOutTurn(s.player, clock.TimeoutAutoPlay, now)
+	webSession.SessionMutex.Unlock()
+	if err != nil {
+		return
+	}
+
+	webSession.SetLastActionTimestamp(now.Unix())
+	webSession.Broadcast(response)
+}
+
+// botAccountProfile is the profile of a bot account in a game: its username,
+// and the first default color which is still free
+func botAccountProfile(webSession *WebGameSession, username string, player engine.Player) auth.PlayerProfile {
+	profile := webSession.freeDefaultProfile(player)
+	profile.Name = username
+
+	return profile
+}
+
+// botGameAction converts an action of the API to the action of the game.
+// Bots can only play; the chat and the host actions are for the browsers.
+func botGameAction(action api.Action) (GameAction, error) {
+	switch action.Action {
+	case "put", "shift", "start", "resign":
+	default:
+		return GameAction{}, GameErrorf("Unknown action: %s", action.Action)
+	}
+
+	return GameAction{
+		Action:    action.Action,
+		Index:     action.Index,
+		Direction: action.Direction,
+		Row:       action.Row,
+		Col:       action.Col,
+	}, nil
+}
+
+// botAct executes the action of the bot in one of its games. The game is
+// identified by its ID or its URL ID.
+func botAct(username, gameId string, action api.Action) error {
+	seat, ok := botHub.seat(username, gameNonce(gameId))
+	if !ok {
+		return ErrorBotNotInGame
+	}
+
+	gameAction, err := botGameAction(action)
+	if err != nil {
+		return err
+	}
+
+	return seat.webSession.HandleAction(gameAction, seat.player)
+}
+
+// botActionError returns the message to send to the bot for an error of an
+// action
+func botActionError(err error) string {
+	switch {
+	case errors.Is(err, ErrorBadRequest):
+		return "Invalid action"
+	case errors.As(err, &GameError{}), errors.Is(err, ErrorBotNotInGame):
+		return err.Error()
+	}
+
+	return "Something went wrong"
+}
+
+// requireBotAccount authenticates the request with the bot token sent as a
+// bearer token
+func requireBotAccount(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "missing bot token"})
+		}
+
+		account, err := accountStore.AuthenticateBot(token)
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid bot token"})
+		}
+
+		c.Set(botAccountContextKey, account)
+		return next(c)
+	}
+}
+
+func botAccount(c echo.Context) accounts.Account {
+	return c.Get(botAccountContextKey).(accounts.Account)
+}
+
+// botNewGameRequest is the body of the request which creates a game hosted by
+// the bot
+type botNewGameRequest struct {
+	Size   int  `json:"size" form:"size"`
+	Public bool `json:"public" form:"public"`
+	Rated  bool `json:"rated" form:"rated"`
+}
+
+func registerBotApiRoutes(e *echo.Echo) {
+	g := e.Group("/api/bot", requireBotAccount)
+
+	g.GET("/account", func(c echo.Context) error {
+		account := botAccount(c)
+		return c.JSON(http.StatusOK, map[string]string{
+			"username": account.Username,
+			"owner":    account.Owner,
+		})
+	})
+
+	g.GET("/games", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, botHub.states(botAccount(c).Username))
+	})
+
+	g.GET("/lobby", func(c echo.Context) error {
+		lobbyGames := []api.LobbyGame{}
+		for _, publicGame := range games.PublicGames() {
+			lobbyGames = append(lobbyGames, api.LobbyGame{
+				GameId:         publicGame.Nonce,
+				Width:          publicGame.Width,
+				Height:         publicGame.Height,
+				PlayerCount:    publicGame.PlayerCount,
+				MaxPlayerCount: publicGame.MaxPlayerCount,
+				Rated:          publicGame.Rated,
+			})
+		}
+
+		return c.JSON(http.StatusOK, lobbyGames)
+	})
+
+	g.POST("/games", func(c echo.Context) error {
+		account := botAccount(c)
+
+		var request botNewGameRequest
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if request.Size < GAME_SIZE_MIN || request.Size > GAME_SIZE_MAX {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+				"error": fmt.Sprintf("Board size must be between %d and %d", GAME_SIZE_MIN, GAME_SIZE_MAX),
+			})
+		}
+
+		profile := withDefaultProfile(auth.PlayerProfile{Name: account.Username}, CreatorPlayerID)
+		session, err := newGameSession(request.Size, profile, account.Username)
+		if err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		webSession := games.AddSession(session, clock.TimeControl{})
+		webSession.SessionMutex.Lock()
+		webSession.Public = request.Public
+		webSession.Rated = request.Rated
+		seatBot(account.Username, webSession, CreatorPlayerID)
+		state := webSession.gameState(CreatorPlayerID)
+		webSession.SessionMutex.Unlock()
+
+		if request.Public {
+			lobby.Notify()
+		}
+
+		return c.JSON(http.StatusCreated, state)
+	})
+
+	g.POST("/games/:id/join", func(c echo.Context) error {
+		account := botAccount(c)
+
+		webSession, ok := games.Get(gameNonce(c.Param("id")))
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "no such game"})
+		}
+
+		webSession.SessionMutex.Lock()
+		defer webSession.SessionMutex.Unlock()
+
+		if _, ok := botHub.seat(account.Username, webSession.Session.Nonce()); ok {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "The bot is already playing in this game"})
+		}
+
+		player := webSession.nextPlayerID()
+		profile := botAccountProfile(webSession, account.Username, player)
+		if err := webSession.joinPlayer(player, profile, account.Username); err != nil {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		}
+		seatBot(account.Username, webSession, player)
+
+		return c.JSON(http.StatusOK, webSession.gameState(player))
+	})
+
+	g.POST("/games/:id/action", func(c echo.Context) error {
+		var action api.Action
+		if err := c.Bind(&action); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+
+		if err := botAct(botAccount(c).Username, c.Param("id"), action); err != nil {
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, ErrorBotNotInGame) {
+				status = http.StatusNotFound
+			}
+			return c.JSON(status, map[string]string{"error": botActionError(err)})
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	// The event stream as newline delimited JSON, for bots which send their
+	// moves with the action endpoint. Empty lines keep the connection alive.
+	g.GET("/stream", func(c echo.Context) error {
+		username := botAccount(c).Username
+		client := botHub.subscribe(username)
+		defer botHub.unsubscribe(username, client)
+
+		response := c.Response()
+		response.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		response.WriteHeader(http.StatusOK)
+		response.Flush()
+
+		keepalive := time.NewTicker(BOT_KEEPALIVE_INTERVAL)
+		defer keepalive.Stop()
+
+		encoder := json.NewEncoder(response)
+		for {
+			var err error
+			select {
+			case <-c.Request().Context().Done():
+				return nil
+			case <-client.dropped:
+				return nil
+			case <-keepalive.C:
+				_, err = response.Write([]byte("\n"))
+			case event := <-client.events:
+				err = encoder.Encode(event)
+			}
+			if err != nil {
+				return nil
+			}
+
+			response.Flush()
+		}
+	})
+
+	// The event stream as a websocket, which takes the actions too
+	g.GET("/ws", func(c echo.Context) error {
+		username := botAccount(c).Username
+
+		ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+
+		client := botHub.subscribe(username)
+		defer botHub.unsubscribe(username, client)
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+
+			for {
+				_, message, err := ws.ReadMessage()
+				if err != nil {
+					return
+				}
+
+				var action api.BotAction
+				if err := json.Unmarshal(message, &action); err != nil {
+					client.send(api.BotEvent{Type: api.EventError, Message: "Invalid JSON"})
+					continue
+				}
+
+				if err := botAct(username, action.GameId, action.Action); err != nil {
+					client.send(api.BotEvent{
+						Type:    api.EventError,
+						GameId:  action.GameId,
+						Message: botActionError(err),
+					})
+				}
+			}
+		}()
+
+		keepalive := time.NewTicker(BOT_KEEPALIVE_INTERVAL)
+		defer keepalive.Stop()
+
+		for {
+			var err error
+			select {
+			case <-closed:
+				return nil
+			case <-client.dropped:
+				return nil
+			case <-keepalive.C:
+				err = ws.WriteMessage(websocket.PingMessage, nil)
+			case event := <-client.events:
+				err = ws.WriteJSON(event)
+			}
+			if err != nil {
+				return nil
+			}
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/engine"
+)
+
+const (
+	CHAT_MESSAGE_MAX_LENGTH = 200
+	CHAT_HISTORY_SIZE       = 100
+
+	CHAT_RATE_LIMIT_MESSAGES = 5
+	CHAT_RATE_LIMIT_WINDOW   = 10 * time.Second
+)
+
+type ChatMessage struct {
+	Player  engine.Player
+	Profile auth.PlayerProfile
+	Text    string
+	Time    time.Time
+}
+
+// Chat is the message history of a game, along with who muted whom
+type Chat struct {
+	mutex   sync.Mutex
+	history []ChatMessage
+	// recent holds the send times of each player's messages inside the rate
+	// limit window
+	recent map[engine.Player][]time.Time
+	// muted[a][b] is set if a muted b
+	muted map[engine.Player]map[engine.Player]bool
+}
+
+func NewChat() *Chat {
+	return &Chat{
+		recent: make(map[engine.Player][]time.Time),
+		muted:  make(map[engine.Player]map[engine.Player]bool),
+	}
+}
+
+func (chat *Chat) post(player engine.Player, profile auth.PlayerProfile, text string, now time.Time) (ChatMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ChatMessage{}, ErrorBadRequest
+	}
+	if utf8.RuneCountInString(text) > CHAT_MESSAGE_MAX_LENGTH {
+		return ChatMessage{}, GameErrorf("Messages cannot be longer than %d characters", CHAT_MESSAGE_MAX_LENGTH)
+	}
+
+	chat.mutex.Lock()
+	defer chat.mutex.Unlock()
+
+	var recent []time.Time
+	for _, sent := range chat.recent[player] {
+		if now.Sub(sent) < CHAT_RATE_LIMIT_WINDOW {
+			recent = append(recent, sent)
+		}
+	}
+	if len(recent) >= CHAT_RATE_LIMIT_MESSAGES {
+		chat.recent[player] = recent
+		return ChatMessage{}, GameErrorf("You are sending messages too fast")
+	}
+	chat.recent[player] = append(recent, now)
+
+	message := ChatMessage{Player: player, Profile: profile, Text: text, Time: now}
+	chat.history = append(chat.history, message)
+	if len(chat.history) > CHAT_HISTORY_SIZE {
+		chat.history = chat.history[len(chat.history)-CHAT_HISTORY_SIZE:]
+	}
+
+	return message, nil
+}
+
+func (chat *Chat) setMuted(player, target engine.Player, muted bool) {
+	chat.mutex.Lock()
+	defer chat.mutex.Unlock()
+
+	if chat.muted[player] == nil {
+		chat.muted[player] = make(map[engine.Player]bool)
+	}
+	chat.muted[player][target] = muted
+}
+
+func (chat *Chat) IsMuted(player, target engine.Player) bool {
+	chat.mutex.Lock()
+	defer chat.mutex.Unlock()
+
+	return chat.muted[player][target]
+}
+
+// ChatLog is the chat as seen by one player
+type ChatLog struct {
+	Messages []ChatMessage
+	Muted    []engine.Player
+}
+
+// LogFor returns the history without the messages of players muted by player
+func (chat *Chat) LogFor(player engine.Player) ChatLog {
+	chat.mutex.Lock()
+	defer chat.mutex.Unlock()
+
+	log := ChatLog{Messages: []ChatMessage{}}
+	for _, message := range chat.history {
+		if !chat.muted[player][message.Player] {
+			log.Messages = append(log.Messages, message)
+		}
+	}
+
+	for target, muted := range chat.muted[player] {
+		if muted {
+			log.Muted = append(log.Muted, target)
+		}
+	}
+	slices.Sort(log.Muted)
+
+	return log
+}
+
+func isChatAction(action string) bool {
+	return action == "chat" || action == "mute" || action == "unmute"
+}
+
+// ExecuteChatAction posts a message or (un)mutes a player, sending the
+// result to the relevant sockets by itself.
+func (webSession *WebGameSession) ExecuteChatAction(action GameAction, player engine.Player) error {
+	chat := webSession.Chat
+
+	switch action.Action {
+	case "chat":
+		webSession.SessionMutex.RLock()
+		profile := webSession.Session.Profile(player)
+		webSession.SessionMutex.RUnlock()
+
+		message, err := chat.post(player, profile, action.Message, time.Now())
+		if err != nil {
+			return err
+		}
+
+		response, err := templates.RenderToBytes("chatMessage", message)
+		if err != nil {
+			return err
+		}
+
+		webSession.FilterForEach(func(conn *GameSocket) bool {
+			if chat.IsMuted(conn.Player, player) {
+				return true
+			}
+			return conn.WriteMessage(websocket.TextMessage, response) != websocket.ErrCloseSent
+		})
+		return nil
+	case "mute", "unmute":
+		if action.Player == player {
+			return ErrorBadRequest
+		}
+
+		chat.setMuted(player, action.Player, action.Action == "mute")
+
+		response, err := templates.RenderToBytes("chatLog", chat.LogFor(player))
+		if err != nil {
+			return err
+		}
+
+		webSession.FilterForEach(func(conn *GameSocket) bool {
+			if conn.Player != player {
+				return true
+			}
+			return conn.WriteMessage(websocket.TextMessage, response) != websocket.ErrCloseSent
+		})
+		return nil
+	}
+
+	return ErrorBadRequest
+}
@@ -0,0 +1,126 @@
+package clock
+
+import (
+	"time"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+type Mode int
+
+const (
+	ModeNone Mode = iota
+	// ModePerMove gives each player a fixed amount of time for every move.
+	ModePerMove
+	// ModeBank gives each player a total amount of time for the whole game,
+	// with an increment added after every move.
+	ModeBank
+)
+
+type TimeoutPolicy int
+
+const (
+	TimeoutPass TimeoutPolicy = iota
+	TimeoutAutoPlay
+	TimeoutEliminate
+)
+
+type TimeControl struct {
+	Mode      Mode
+	Limit     time.Duration
+	Increment time.Duration
+	OnTimeout TimeoutPolicy
+}
+
+func (tc TimeControl) Enabled() bool {
+	return tc.Mode != ModeNone
+}
+
+// Clock keeps track of the time left for each player. It is not safe for
+// concurrent use.
+type Clock struct {
+	control   TimeControl
+	remaining map[engine.Player]time.Duration
+
+	running   bool
+	player    engine.Player
+	turnStart time.Time
+}
+
+func New(control TimeControl) *Clock {
+	return &Clock{
+		control:   control,
+		remaining: make(map[engine.Player]time.Duration),
+	}
+}
+
+func (c *Clock) Control() TimeControl {
+	return c.control
+}
+
+func (c *Clock) bankOf(player engine.Player) time.Duration {
+	remaining, ok := c.remaining[player]
+	if !ok {
+		return c.control.Limit
+	}
+	return remaining
+}
+
+// Start stops the clock of the previous player (if any) and starts the clock
+// of the given player.
+func (c *Clock) Start(player engine.Player, now time.Time) {
+	if !c.control.Enabled() {
+		return
+	}
+
+	c.Stop(now)
+
+	if c.control.Mode == ModePerMove {
+		c.remaining[player] = c.control.Limit
+	}
+
+	c.running = true
+	c.player = player
+	c.turnStart = now
+}
+
+// Stop stops the running clock, charging its player for the time spent.
+func (c *Clock) Stop(now time.Time) {
+	if !c.running {
+		return
+	}
+
+	remaining := c.Remaining(c.player, now)
+	if c.control.Mode == ModeBank && remaining > 0 {
+		remaining += c.control.Increment
+	}
+
+	c.remaining[c.player] = remaining
+	c.running = false
+}
+
+func (c *Clock) Remaining(player engine.Player, now time.Time) time.Duration {
+	remaining := c.bankOf(player)
+	if c.running && c.player == player {
+		remaining -= now.Sub(c.turnStart)
+	}
+
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Running returns the player whose clock is running.
+func (c *Clock) Running() (engine.Player, bool) {
+	return c.player, c.running
+}
+
+// Expired returns the player whose clock is running and has run out of time.
+func (c *Clock) Expired(now time.Time) (engine.Player, bool) {
+	if !c.running || c.Remaining(c.player, now) > 0 {
+		return 0, false
+	}
+
+	return c.player, true
+}
@@ -0,0 +1,59 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerMove(t *testing.T) {
+	c := New(TimeControl{Mode: ModePerMove, Limit: 10 * time.Second})
+	now := time.Now()
+
+	c.Start(1, now)
+	assert.Equal(t, 10*time.Second, c.Remaining(1, now))
+
+	now = now.Add(8 * time.Second)
+	c.Start(2, now)
+	_, expired := c.Expired(now)
+	assert.False(t, expired)
+
+	now = now.Add(8 * time.Second)
+	c.Start(1, now)
+	assert.Equal(t, 10*time.Second, c.Remaining(1, now))
+
+	now = now.Add(11 * time.Second)
+	player, expired := c.Expired(now)
+	assert.True(t, expired)
+	assert.EqualValues(t, 1, player)
+}
+
+func TestBankWithIncrement(t *testing.T) {
+	c := New(TimeControl{Mode: ModeBank, Limit: time.Minute, Increment: 5 * time.Second})
+	now := time.Now()
+
+	c.Start(1, now)
+	now = now.Add(20 * time.Second)
+	c.Start(2, now)
+
+	assert.Equal(t, 45*time.Second, c.Remaining(1, now))
+	assert.Equal(t, time.Minute, c.Remaining(2, now))
+
+	now = now.Add(time.Minute)
+	player, expired := c.Expired(now)
+	assert.True(t, expired)
+	assert.EqualValues(t, 2, player)
+}
+
+func TestDisabled(t *testing.T) {
+	c := New(TimeControl{})
+	now := time.Now()
+
+	c.Start(1, now)
+	_, running := c.Running()
+	assert.False(t, running)
+
+	_, expired := c.Expired(now.Add(time.Hour))
+	assert.False(t, expired)
+}
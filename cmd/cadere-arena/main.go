@@ -0,0 +1,163 @@
+// cadere-arena plays bots against each other for many games, and reports
+// their win rates with confidence intervals.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Denloob/cadere/arena"
+	"github.com/Denloob/cadere/bot"
+)
+
+func main() {
+	bots := flag.String("bots", "greedy,random", "comma separated bots to seat in every game, one of: "+strings.Join(bot.Names(), ", "))
+	sizes := flag.String("sizes", "4,5,6", "comma separated board sizes")
+	games := flag.Int("games", 50, "games for every board size and seat order")
+	maxMoves := flag.Int("max-moves", 1000, "moves after which a game ends as a draw")
+	parallel := flag.Int("parallel", runtime.NumCPU(), "games played at once")
+	jsonPath := flag.String("json", "", "also write the report as JSON to this file, or to stdout instead of the table with -")
+	flag.Parse()
+
+	config, err := newConfig(*bots, *sizes, *games, *maxMoves, *parallel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cadere-arena:", err)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	results, err := arena.Run(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cadere-arena:", err)
+		os.Exit(1)
+	}
+	report := arena.NewReport(config.Entrants, results)
+
+	if *jsonPath != "-" {
+		writeTable(os.Stdout, report)
+		fmt.Printf("\n%d games in %s\n", report.Games, time.Since(start).Round(time.Millisecond))
+	}
+	if *jsonPath != "" {
+		if err := writeJSON(*jsonPath, report); err != nil {
+			fmt.Fprintln(os.Stderr, "cadere-arena:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func newConfig(bots, sizes string, games, maxMoves, parallel int) (arena.Config, error) {
+	config := arena.Config{
+		GamesPerSeating: games,
+		MaxMoves:        maxMoves,
+		Parallel:        parallel,
+	}
+
+	names := strings.Split(bots, ",")
+	for _, field := range names {
+		name := strings.TrimSpace(field)
+		if _, err := bot.New(name); err != nil {
+			return config, err
+		}
+
+		config.Entrants = append(config.Entrants, arena.Entrant{
+			Name: entrantName(name, names, len(config.Entrants)),
+			New: func() bot.Bot {
+				b, _ := bot.New(name)
+				return b
+			},
+		})
+	}
+	if len(config.Entrants) < 2 {
+		return config, fmt.Errorf("at least two bots are needed, got %q", bots)
+	}
+
+	for _, field := range strings.Split(sizes, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || size < 2 {
+			return config, fmt.Errorf("invalid board size: %q", field)
+		}
+		config.Sizes = append(config.Sizes, size)
+	}
+
+	if games < 1 || maxMoves < 1 {
+		return config, fmt.Errorf("-games and -max-moves must be positive")
+	}
+
+	return config, nil
+}
+
+// entrantName numbers the bots which are seated more than once, so that they
+// can be told apart in the report
+func entrantName(name string, names []string, index int) string {
+	count := 0
+	for _, other := range names {
+		if strings.TrimSpace(other) == name {
+			count++
+		}
+	}
+	if count == 1 {
+		return name
+	}
+
+	return fmt.Sprintf("%s#%d", name, index+1)
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+func writeSummary(w io.Writer, summary arena.Summary) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "Bot\tGames\tWins\tDraws\tLosses\tWin rate\t95% CI\t")
+	for _, entrant := range summary.Entrants {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t%s\t%s - %s\t\n",
+			entrant.Name, entrant.Games, entrant.Wins, entrant.Draws, entrant.Losses,
+			percent(entrant.WinRate), percent(entrant.WinRateLow), percent(entrant.WinRateHigh))
+	}
+	table.Flush()
+
+	fmt.Fprintln(w)
+	table = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "Seat\tWins\tWin rate\t95% CI\t")
+	for _, seat := range summary.Seats {
+		fmt.Fprintf(table, "%d\t%d\t%s\t%s - %s\t\n",
+			seat.Seat, seat.Wins, percent(seat.WinRate), percent(seat.WinRateLow), percent(seat.WinRateHigh))
+	}
+	table.Flush()
+
+	fmt.Fprintf(w, "\nAverage game: %.1f moves, %d draws (%d reached the move limit)\n",
+		summary.AverageMoves, summary.Draws, summary.Unfinished)
+}
+
+func writeTable(w io.Writer, report arena.Report) {
+	fmt.Fprintf(w, "All sizes\n\n")
+	writeSummary(w, report.Summary)
+
+	for _, size := range report.Sizes {
+		fmt.Fprintf(w, "\n%dx%d\n\n", size.Size, size.Size)
+		writeSummary(w, size.Summary)
+	}
+}
+
+func writeJSON(path string, report arena.Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
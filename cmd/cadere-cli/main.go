@@ -0,0 +1,46 @@
+// cadere-cli plays Shifter in a terminal, either on a server or offline
+// against bots.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "URL of the server")
+	newSize := flag.Int("new", 0, "create a game on the server with a board of this size")
+	join := flag.String("join", "", "join a game on the server, by its invite link or game ID")
+	name := flag.String("name", "", "display name on the server")
+	color := flag.String("color", "", "tile color on the server, like #f26419")
+
+	offline := flag.Bool("offline", false, "play offline against bots")
+	size := flag.Int("size", 6, "board size of an offline game")
+	bots := flag.Int("bots", 1, "number of bots in an offline game")
+	flag.Parse()
+
+	var game frontend
+	var err error
+	switch {
+	case *offline:
+		game, err = newOfflineGame(*size, *bots)
+	case *newSize > 0:
+		game, err = createOnlineGame(*server, *newSize, *name, *color)
+	case *join != "":
+		game, err = joinOnlineGame(*server, *join, *name, *color)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cadere-cli:", err)
+		os.Exit(1)
+	}
+	defer game.Close()
+
+	if err := run(game); err != nil {
+		fmt.Fprintln(os.Stderr, "cadere-cli:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Denloob/cadere/api"
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/bot"
+	"github.com/Denloob/cadere/engine"
+)
+
+// offlinePlayer is the player of the person at the terminal in offline games
+const offlinePlayer engine.Player = 1
+
+// offlineUpdatesBuffer is how many updates can wait to be drawn. The updates
+// are sent by the same goroutine that receives them, so they must not block.
+const offlineUpdatesBuffer = 16
+
+// offlineGame is a game against bots, played directly through the engine
+type offlineGame struct {
+	game    engine.Game
+	bots    map[engine.Player]bot.Bot
+	updates chan string
+}
+
+func newOfflineGame(size, bots int) (*offlineGame, error) {
+	if size < 2 {
+		return nil, errors.New("the board must be at least 2x2")
+	}
+	if bots < 1 {
+		return nil, errors.New("an offline game needs at least one bot")
+	}
+
+	board := engine.NewBoard(size, size)
+	if maxPlayers := board.MaxPlayerCount(engine.MinTilesPerPlayer); bots+1 > maxPlayers {
+		return nil, fmt.Errorf("a %dx%d board has room for %d players at most", size, size, maxPlayers)
+	}
+
+	g := &offlineGame{
+		game:    engine.NewGame(board),
+		bots:    map[engine.Player]bot.Bot{},
+		updates: make(chan string, offlineUpdatesBuffer),
+	}
+
+	g.game.AddPlayers(offlinePlayer)
+	for i := 1; i <= bots; i++ {
+		player := offlinePlayer + engine.Player(i)
+		g.game.AddPlayers(player)
+		g.bots[player] = bot.Random{}
+	}
+	g.game.ProgressStage()
+
+	return g, nil
+}
+
+func (g *offlineGame) notify(message string) {
+	select {
+	case g.updates <- message:
+	default:
+	}
+}
+
+// playBotTurns plays for the bots until it's the turn of the player or the
+// game is over. Once the player is eliminated the bots stop, like they do on
+// the server when no human is left.
+func (g *offlineGame) playBotTurns() {
+	for g.game.Stage() == engine.StageInit || g.game.Stage() == engine.StatePlaying {
+		player := g.game.CurrentPlayer()
+		playerBot, ok := g.bots[player]
+		if !ok || !g.game.PlayerExists(offlinePlayer) {
+			return
+		}
+
+		move, err := playerBot.Move(g.game)
+		if err == nil {
+			err = g.game.Play(player, move)
+		}
+		if err != nil && g.game.Pass(player) != nil {
+			return
+		}
+	}
+}
+
+func (g *offlineGame) Updates() <-chan string {
+	return g.updates
+}
+
+func (g *offlineGame) State() (api.GameState, error) {
+	players := []api.Player{}
+	for _, player := range g.game.Players() {
+		profile := auth.DefaultProfile(player)
+		_, isBot := g.bots[player]
+		if !isBot {
+			profile.Name = "You"
+		}
+
+		players = append(players, api.Player{
+			Player: player,
+			Name:   profile.Name,
+			Color:  profile.Color,
+			Bot:    isBot,
+		})
+	}
+
+	state := api.GameState{
+		Stage:   api.StageName(g.game.Stage()),
+		Board:   g.game.Board,
+		Players: players,
+		You:     offlinePlayer,
+		Draw:    g.game.IsDraw(),
+	}
+
+	switch g.game.Stage() {
+	case engine.StageInit, engine.StatePlaying:
+		state.CurrentPlayer = g.game.CurrentPlayer()
+	case engine.StageOver:
+		if winner, err := g.game.Winner(); err == nil {
+			state.Winner = winner
+		}
+	}
+
+	return state, nil
+}
+
+func (g *offlineGame) Send(action api.Action) error {
+	var err error
+	switch action.Action {
+	case "put":
+		err = g.game.Play(offlinePlayer, engine.PutMove(action.Row, action.Col))
+	case "shift":
+		var direction engine.Direction
+		direction, err = engine.ParseDirection(action.Direction)
+		if err == nil {
+			err = g.game.Play(offlinePlayer, engine.ShiftMove(direction, action.Index))
+		}
+	case "resign":
+		err = g.game.Resign(offlinePlayer)
+	case "start":
+		err = errors.New("offline games start right away")
+	default:
+		err = fmt.Errorf("unknown action: %s", action.Action)
+	}
+
+	if err != nil {
+		g.notify(err.Error())
+		return nil
+	}
+
+	g.playBotTurns()
+	g.notify("")
+	return nil
+}
+
+func (g *offlineGame) Invite(state api.GameState) string {
+	return ""
+}
+
+func (g *offlineGame) Close() error {
+	return nil
+}
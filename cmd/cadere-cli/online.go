@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Denloob/cadere/api"
+)
+
+// onlineGame is a game played on a server. The websocket only tells the
+// client when the game changed; the state is fetched from the JSON API.
+type onlineGame struct {
+	server *url.URL
+	token  string
+
+	conn    *websocket.Conn
+	updates chan string
+}
+
+func createOnlineGame(server string, size int, name, color string) (*onlineGame, error) {
+	return startOnlineGame(server, "/new", url.Values{
+		"size":  {fmt.Sprint(size)},
+		"name":  {name},
+		"color": {color},
+	})
+}
+
+// joinOnlineGame joins a game by its ID, or by an invite link which has the
+// ID in its gameId parameter
+func joinOnlineGame(server, join, name, color string) (*onlineGame, error) {
+	gameId := join
+	if link, err := url.Parse(join); err == nil && link.Query().Has("gameId") {
+		gameId = link.Query().Get("gameId")
+	}
+
+	return startOnlineGame(server, "/join", url.Values{
+		"gameId": {gameId},
+		"name":   {name},
+		"color":  {color},
+	})
+}
+
+// startOnlineGame submits the form which creates or joins a game, asking for
+// JSON, and connects to the game with the token it answers with
+func startOnlineGame(server, path string, form url.Values) (*onlineGame, error) {
+	serverUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, serverUrl.JoinPath(path).String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, responseError(response)
+	}
+
+	var gameToken api.GameToken
+	if err := json.NewDecoder(response.Body).Decode(&gameToken); err != nil {
+		return nil, err
+	}
+	if gameToken.Token == "" {
+		return nil, errors.New("the server did not send a game token")
+	}
+
+	return connectOnlineGame(serverUrl, gameToken.Token)
+}
+
+// responseError returns the error the server answered with, or its status
+// if the response has none
+func responseError(response *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil || body.Error == "" {
+		return fmt.Errorf("the server responded with %s", response.Status)
+	}
+
+	return errors.New(body.Error)
+}
+
+func connectOnlineGame(server *url.URL, token string) (*onlineGame, error) {
+	wsUrl := *server.JoinPath("/play")
+	if wsUrl.Scheme == "https" {
+		wsUrl.Scheme = "wss"
+	} else {
+		wsUrl.Scheme = "ws"
+	}
+
+	header := http.Header{"Accept": {"application/json"}}
+	conn, _, err := websocket.DefaultDialer.Dial(wsUrl.String(), header)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(token)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	game := &onlineGame{
+		server:  server,
+		token:   token,
+		conn:    conn,
+		updates: make(chan string),
+	}
+	go game.readUpdates()
+
+	return game, nil
+}
+
+// readUpdates turns the messages of the server into updates, with the
+// message of the errors
+func (g *onlineGame) readUpdates() {
+	defer close(g.updates)
+
+	for {
+		var message api.GameMessage
+		if err := g.conn.ReadJSON(&message); err != nil {
+			return
+		}
+
+		if message.Type == api.MessageError {
+			g.updates <- message.Message
+		} else {
+			g.updates <- ""
+		}
+	}
+}
+
+func (g *onlineGame) Updates() <-chan string {
+	return g.updates
+}
+
+func (g *onlineGame) State() (api.GameState, error) {
+	request, err := http.NewRequest(http.MethodGet, g.server.JoinPath("/api/game").String(), nil)
+	if err != nil {
+		return api.GameState{}, err
+	}
+	request.Header.Set("Authorization", "Bearer "+g.token)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return api.GameState{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return api.GameState{}, responseError(response)
+	}
+
+	var state api.GameState
+	err = json.NewDecoder(response.Body).Decode(&state)
+	return state, err
+}
+
+func (g *onlineGame) Send(action api.Action) error {
+	return g.conn.WriteJSON(action)
+}
+
+func (g *onlineGame) Invite(state api.GameState) string {
+	invite := g.server.JoinPath("/join")
+	invite.RawQuery = url.Values{"gameId": {state.GameId}}.Encode()
+
+	return invite.String()
+}
+
+func (g *onlineGame) Close() error {
+	return g.conn.Close()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+
+	"github.com/Denloob/cadere/api"
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/tui"
+)
+
+// frontend is a game the terminal client plays, either on a server or offline
+type frontend interface {
+	// Updates receives a message whenever the game may have changed. The
+	// message is empty, or an error to show to the player.
+	Updates() <-chan string
+	State() (api.GameState, error)
+	// Send performs the action for the player. Errors which the player can
+	// fix, like playing out of turn, are delivered through Updates instead.
+	Send(action api.Action) error
+	// Invite returns the link other players join the game with, if it has one
+	Invite(state api.GameState) string
+	Close() error
+}
+
+const helpLine = "Arrows/hjkl: move  Enter/Space: play  s: start  R: resign  q: quit"
+
+// run plays the game until the player quits
+func run(game frontend) error {
+	fd := int(os.Stdin.Fd())
+	oldState, err := tui.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer tui.Restore(fd, oldState)
+
+	os.Stdout.WriteString(tui.HideCursor)
+	defer os.Stdout.WriteString(tui.ShowCursor + tui.Newline)
+
+	keys := make(chan tui.Key)
+	go func() {
+		defer close(keys)
+
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			key, err := tui.ReadKey(reader)
+			if err != nil {
+				return
+			}
+			keys <- key
+		}
+	}()
+
+	state, err := game.State()
+	if err != nil {
+		return err
+	}
+
+	cursor := tui.Cursor{}
+	status := ""
+	for {
+		draw(state, cursor, status, game.Invite(state))
+
+		select {
+		case message, ok := <-game.Updates():
+			if !ok {
+				return nil
+			}
+			if message != "" {
+				status = message
+			}
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+
+			status = ""
+			height := len(state.Board)
+			width := 0
+			if height > 0 {
+				width = len(state.Board[0])
+			}
+
+			if direction, ok := key.Direction(); ok {
+				cursor = cursor.Move(direction, width, height)
+				continue
+			}
+
+			var action api.Action
+			switch {
+			case key.Code == tui.KeyInterrupt, key == tui.Key{Code: tui.KeyRune, Rune: 'q'}:
+				return nil
+			case key.Code == tui.KeyEnter, key == tui.Key{Code: tui.KeyRune, Rune: ' '}:
+				move, ok := cursor.Action(width, height)
+				if !ok {
+					continue
+				}
+				if move.Kind == engine.MovePut {
+					action = api.PutAction(move.Row, move.Col)
+				} else {
+					action = api.ShiftAction(move.Direction, move.Index)
+				}
+			case key == tui.Key{Code: tui.KeyRune, Rune: 's'}:
+				action = api.StartAction()
+			case key == tui.Key{Code: tui.KeyRune, Rune: 'R'}:
+				action = api.ResignAction()
+			default:
+				continue
+			}
+
+			if err := game.Send(action); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if state, err = game.State(); err != nil {
+			return err
+		}
+	}
+}
+
+func draw(state api.GameState, cursor tui.Cursor, status, invite string) {
+	var screen bytes.Buffer
+	tui.DrawGame(&screen, state, cursor, status)
+	if invite != "" && state.Stage == api.StageLobby {
+		screen.WriteString("Invite link: " + invite + tui.Newline)
+	}
+	screen.WriteString(tui.Newline + helpLine + tui.Newline)
+
+	os.Stdout.Write(screen.Bytes())
+}
@@ -0,0 +1,62 @@
+// cadere-puzzles generates "eliminate in N shifts" puzzles and prints them in
+// the position notation, one on each line. The server loads such a list from
+// puzzles.txt in its data directory.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/Denloob/cadere/puzzle"
+)
+
+// maxDuplicates is how many already generated puzzles are found before
+// giving up on finding more
+const maxDuplicates = 100
+
+func main() {
+	size := flag.Int("size", 3, "width and height of the board")
+	tiles := flag.Int("tiles", 2, "tiles of each player")
+	moves := flag.Int("moves", 2, "moves the player has to eliminate their opponent")
+	count := flag.Int("count", 10, "puzzles to generate")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed of the random positions")
+	flag.Parse()
+
+	if *size < 1 || *tiles < 1 || *moves < 1 || *count < 1 {
+		fmt.Fprintln(os.Stderr, "cadere-puzzles: -size, -tiles, -moves and -count must be positive")
+		os.Exit(2)
+	}
+
+	random := rand.New(rand.NewSource(*seed))
+	seen := make(map[string]bool)
+	duplicates := 0
+	fmt.Printf("# %dx%d, %d tiles each, seed %d\n", *size, *size, *tiles, *seed)
+	for generated := 0; generated < *count; {
+		p, err := puzzle.Generate(random, *size, *size, *tiles, *moves)
+		if errors.Is(err, puzzle.ErrorNotFound) {
+			fmt.Fprintf(os.Stderr, "cadere-puzzles: no puzzle in %d moves found with %d tiles each, try another -tiles\n", *moves, *tiles)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cadere-puzzles:", err)
+			os.Exit(1)
+		}
+		if seen[p.Id] {
+			// Small boards run out of distinct puzzles
+			duplicates++
+			if duplicates > maxDuplicates {
+				fmt.Fprintf(os.Stderr, "cadere-puzzles: only %d distinct puzzles found\n", generated)
+				os.Exit(1)
+			}
+			continue
+		}
+		seen[p.Id] = true
+		generated++
+
+		fmt.Printf("# eliminate in %d\n%s\n", p.Moves, p.Position)
+	}
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"errors"
+	"slices"
 )
 
 type Tile int
@@ -128,6 +129,59 @@ func (b Board) ShiftDown(col int) error {
 	return nil
 }
 
+type Direction int
+
+const (
+	DirectionUp Direction = iota
+	DirectionDown
+	DirectionLeft
+	DirectionRight
+)
+
+var Directions = []Direction{DirectionUp, DirectionDown, DirectionLeft, DirectionRight}
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionUp:
+		return "up"
+	case DirectionDown:
+		return "down"
+	case DirectionLeft:
+		return "left"
+	case DirectionRight:
+		return "right"
+	}
+
+	return "unknown"
+}
+
+func ParseDirection(s string) (Direction, error) {
+	for _, d := range Directions {
+		if d.String() == s {
+			return d, nil
+		}
+	}
+
+	return 0, errors.New("unknown direction")
+}
+
+// Shift shifts the row (for left and right) or the column (for up and down)
+// at index in the given direction.
+func (b Board) Shift(direction Direction, index int) error {
+	switch direction {
+	case DirectionUp:
+		return b.ShiftUp(index)
+	case DirectionDown:
+		return b.ShiftDown(index)
+	case DirectionLeft:
+		return b.ShiftLeft(index)
+	case DirectionRight:
+		return b.ShiftRight(index)
+	}
+
+	return errors.New("unknown direction")
+}
+
 const MinTilesPerPlayer = 2
 const MinPlayerCount = 1
 
@@ -158,11 +212,70 @@ const (
 	StageOver
 )
 
+type MoveKind int
+
+const (
+	MovePut MoveKind = iota
+	MoveShift
+)
+
+// Move is a single turn of a player. Put moves use Row and Col, shift moves
+// use Direction and Index.
+type Move struct {
+	Kind MoveKind
+
+	Row int
+	Col int
+
+	Direction Direction
+	Index     int
+}
+
+func PutMove(row, col int) Move {
+	return Move{Kind: MovePut, Row: row, Col: col}
+}
+
+func ShiftMove(direction Direction, index int) Move {
+	return Move{Kind: MoveShift, Direction: direction, Index: index}
+}
+
+var (
+	ErrorNotYourTurn     = errors.New("not your turn")
+	ErrorNotInitStage    = errors.New("game is not in the init stage")
+	ErrorNotPlayingStage = errors.New("game is not in the playing stage")
+	ErrorUnknownMove     = errors.New("unknown move")
+)
+
+type EventKind int
+
+const (
+	EventMove EventKind = iota
+	EventPass
+	EventEliminate
+	EventDraw
+)
+
+// Event is an entry in the history of a game. Move is set only for EventMove.
+type Event struct {
+	Kind   EventKind
+	Player Player
+	Move   Move
+}
+
 type Game struct {
 	Board              Board
 	stage              Stage
 	players            []Player
 	currentPlayerIndex int
+
+	drawAgreements map[Player]bool
+	drawn          bool
+
+	startingPlayers []Player
+	history         []Event
+	// outAt is the length of the history when each player went out of the
+	// game, by elimination or by losing their last tile to a shift
+	outAt map[Player]int
 }
 
 func (g Game) Stage() Stage {
@@ -174,9 +287,54 @@ func (g *Game) ProgressStage() {
 		panic("tried to progress an over game")
 	}
 
+	if g.stage == StageLobby {
+		g.startingPlayers = g.Players()
+	}
 	g.stage++
 }
 
+// StartingPlayers returns the turn order the game started with, including
+// the players who were eliminated since.
+func (g Game) StartingPlayers() []Player {
+	return append([]Player{}, g.startingPlayers...)
+}
+
+// History returns everything that happened since the game started.
+func (g Game) History() []Event {
+	return append([]Event{}, g.history...)
+}
+
+// Clone returns a deep copy of the game, which can be played on without
+// affecting the original.
+func (g Game) Clone() Game {
+	clone := g
+
+	clone.Board = make(Board, len(g.Board))
+	for row := range g.Board {
+		clone.Board[row] = append([]Tile{}, g.Board[row]...)
+	}
+
+	clone.players = g.Players()
+	clone.startingPlayers = g.StartingPlayers()
+	clone.history = g.History()
+
+	if g.outAt != nil {
+		clone.outAt = make(map[Player]int, len(g.outAt))
+		for player, at := range g.outAt {
+			clone.outAt[player] = at
+		}
+	}
+
+	if g.drawAgreements != nil {
+		clone.drawAgreements = make(map[Player]bool, len(g.drawAgreements))
+		for player, agreed := range g.drawAgreements {
+			clone.drawAgreements[player] = agreed
+		}
+	}
+
+	return clone
+}
+
 func (g Game) anyTilesOwnedBy(player Player) bool {
 	for _, row := range g.Board {
 		for _, tile := range row {
@@ -190,6 +348,14 @@ func (g Game) anyTilesOwnedBy(player Player) bool {
 }
 
 func (g Game) Winner() (Player, error) {
+	if g.drawn {
+		return 0, errors.New("the game is a draw")
+	}
+
+	if len(g.players) == 1 && g.stage == StageOver {
+		return g.players[0], nil
+	}
+
 	possibleWinners := []Player{}
 	for _, player := range g.players {
 		if g.anyTilesOwnedBy(player) {
@@ -221,10 +387,30 @@ func (g *Game) AddPlayers(players ...Player) error {
 	return nil
 }
 
+// RemovePlayer removes a player who has not started playing yet
+func (g *Game) RemovePlayer(player Player) error {
+	if g.stage != StageLobby {
+		return errors.New("players can be removed only in the lobby")
+	}
+
+	index := slices.Index(g.players, player)
+	if index == -1 {
+		return errors.New("invalid player")
+	}
+
+	g.players = slices.Delete(g.players, index, index+1)
+	return nil
+}
+
 func (g Game) PlayerCount() int {
 	return len(g.players)
 }
 
+// Players returns the players in turn order.
+func (g Game) Players() []Player {
+	return append([]Player{}, g.players...)
+}
+
 func (g Game) CurrentPlayer() Player {
 	return g.players[g.currentPlayerIndex]
 }
@@ -244,3 +430,397 @@ func (g Game) PlayerExists(player Player) bool {
 
 	return false
 }
+
+// Play executes move for player, advancing the turn and the stage as needed.
+func (g *Game) Play(player Player, move Move) error {
+	var err error
+	switch move.Kind {
+	case MovePut:
+		err = g.put(player, move.Row, move.Col)
+	case MoveShift:
+		err = g.shift(player, move.Direction, move.Index)
+	default:
+		err = ErrorUnknownMove
+	}
+
+	if err == nil {
+		g.history = append(g.history, Event{Kind: EventMove, Player: player, Move: move})
+	}
+	return err
+}
+
+// Pass skips the turn of the player. Passing is possible only in the playing
+// stage, because during the init stage every player has to place their tiles.
+func (g *Game) Pass(player Player) error {
+	if g.stage != StatePlaying {
+		return ErrorNotPlayingStage
+	}
+
+	if g.CurrentPlayer() != player {
+		return ErrorNotYourTurn
+	}
+
+	g.NextPlayer()
+	g.history = append(g.history, Event{Kind: EventPass, Player: player})
+	return nil
+}
+
+// Apply replays an event from the history of another game.
+func (g *Game) Apply(event Event) error {
+	switch event.Kind {
+	case EventMove:
+		return g.Play(event.Player, event.Move)
+	case EventPass:
+		return g.Pass(event.Player)
+	case EventEliminate:
+		return g.EliminatePlayer(event.Player)
+	case EventDraw:
+		if !g.inProgress() {
+			return ErrorGameNotInProgress
+		}
+		g.declareDraw()
+		return nil
+	}
+
+	return errors.New("unknown event")
+}
+
+// Replay plays the history on a new game with the given board size and
+// starting players, and returns the game after every event. The first game
+// is the position before any event.
+func Replay(width, height int, players []Player, history []Event) ([]Game, error) {
+	game := NewGame(NewBoard(width, height))
+	if err := game.AddPlayers(players...); err != nil {
+		return nil, err
+	}
+	game.ProgressStage()
+
+	positions := []Game{game.Clone()}
+	for _, event := range history {
+		if err := game.Apply(event); err != nil {
+			return positions, err
+		}
+		positions = append(positions, game.Clone())
+	}
+
+	return positions, nil
+}
+
+func (g *Game) put(player Player, row, col int) error {
+	if g.stage != StageInit {
+		return ErrorNotInitStage
+	}
+
+	if g.CurrentPlayer() != player {
+		return ErrorNotYourTurn
+	}
+
+	if err := g.Board.Put(row, col, player.ToTile()); err != nil {
+		return err
+	}
+
+	g.drawAgreements = nil
+
+	playerCount := g.PlayerCount()
+
+	nonEmptyTileCount := g.Board.CountNonEmptyTiles()
+	fullBoardNonEmptyTiles := playerCount * g.Board.TilesPerPlayerWhen(playerCount)
+
+	if nonEmptyTileCount >= fullBoardNonEmptyTiles {
+		g.ProgressStage()
+	}
+
+	g.NextPlayer()
+	return nil
+}
+
+func (g *Game) shift(player Player, direction Direction, index int) error {
+	if g.stage != StatePlaying {
+		return ErrorNotPlayingStage
+	}
+
+	if g.CurrentPlayer() != player {
+		return ErrorNotYourTurn
+	}
+
+	if err := g.Board.Shift(direction, index); err != nil {
+		return err
+	}
+
+	for _, p := range g.players {
+		if !g.anyTilesOwnedBy(p) {
+			g.markOut(p)
+		}
+	}
+
+	g.NextPlayer()
+	g.drawAgreements = nil
+
+	if _, err := g.Winner(); err == nil {
+		g.ProgressStage()
+	} else if g.Board.CountNonEmptyTiles() == 0 {
+		g.drawn = true
+		g.ProgressStage()
+	}
+
+	return nil
+}
+
+// LegalMoves returns every move the current player may make.
+func (g Game) LegalMoves() []Move {
+	moves := []Move{}
+
+	switch g.stage {
+	case StageInit:
+		for row := range g.Board {
+			for col, tile := range g.Board[row] {
+				if tile.IsEmpty() {
+					moves = append(moves, PutMove(row, col))
+				}
+			}
+		}
+	case StatePlaying:
+		for _, direction := range Directions {
+			lineCount := len(g.Board)
+			if direction == DirectionUp || direction == DirectionDown {
+				lineCount = len(g.Board[0])
+			}
+
+			for index := 0; index < lineCount; index++ {
+				moves = append(moves, ShiftMove(direction, index))
+			}
+		}
+	}
+
+	return moves
+}
+
+// PushedOff returns the tile the shift would push off the board, which is
+// the tile at the edge it shifts towards.
+func (b Board) PushedOff(direction Direction, index int) (Tile, error) {
+	switch direction {
+	case DirectionUp, DirectionDown:
+		if err := b.validateColIndex(index); err != nil {
+			return tileEmpty, err
+		}
+	case DirectionLeft, DirectionRight:
+		if err := b.validateRowIndex(index); err != nil {
+			return tileEmpty, err
+		}
+	}
+
+	switch direction {
+	case DirectionUp:
+		return b[0][index], nil
+	case DirectionDown:
+		return b[len(b)-1][index], nil
+	case DirectionLeft:
+		return b[index][0], nil
+	case DirectionRight:
+		return b[index][len(b[index])-1], nil
+	}
+
+	return tileEmpty, errors.New("unknown direction")
+}
+
+// Threats returns, for every player, the shifts which would push one of
+// their tiles off the board.
+func (g Game) Threats() map[Player][]Move {
+	threats := make(map[Player][]Move)
+	if g.stage != StatePlaying {
+		return threats
+	}
+
+	for _, move := range g.LegalMoves() {
+		tile, err := g.Board.PushedOff(move.Direction, move.Index)
+		if err != nil || tile.IsEmpty() {
+			continue
+		}
+
+		player := Player(tile)
+		threats[player] = append(threats[player], move)
+	}
+
+	return threats
+}
+
+// markOut records that the player went out of the game with the next event,
+// unless they already were out
+func (g *Game) markOut(player Player) {
+	if g.outAt == nil {
+		g.outAt = make(map[Player]int)
+	}
+	if _, ok := g.outAt[player]; !ok {
+		g.outAt[player] = len(g.history)
+	}
+}
+
+// Standings groups the starting players by their place in the game, best
+// first. The winner comes first, then the other players who still have tiles,
+// then the players who went out, the last to go out first. The players of a
+// group tie.
+func (g Game) Standings() [][]Player {
+	standings := [][]Player{}
+	winner, err := g.Winner()
+	hasWinner := err == nil
+	if hasWinner {
+		standings = append(standings, []Player{winner})
+	}
+
+	remaining := []Player{}
+	var out []Player
+	for _, player := range g.startingPlayers {
+		if hasWinner && player == winner {
+			continue
+		}
+		if _, ok := g.outAt[player]; ok {
+			out = append(out, player)
+		} else {
+			remaining = append(remaining, player)
+		}
+	}
+	if len(remaining) > 0 {
+		standings = append(standings, remaining)
+	}
+
+	slices.SortStableFunc(out, func(a, b Player) int {
+		return g.outAt[b] - g.outAt[a]
+	})
+	for i, player := range out {
+		if i > 0 && g.outAt[player] == g.outAt[out[i-1]] {
+			standings[len(standings)-1] = append(standings[len(standings)-1], player)
+		} else {
+			standings = append(standings, []Player{player})
+		}
+	}
+
+	return standings
+}
+
+// EliminatePlayer removes all of the player's tiles from the board and takes
+// them out of the turn order. If a single player remains, the game is over.
+func (g *Game) EliminatePlayer(player Player) error {
+	if g.stage != StageInit && g.stage != StatePlaying {
+		return errors.New("players can be eliminated only during the game")
+	}
+
+	index := -1
+	for i, p := range g.players {
+		if p == player {
+			index = i
+		}
+	}
+	if index == -1 {
+		return errors.New("invalid player")
+	}
+	if len(g.players) == 1 {
+		return errors.New("cannot eliminate the last player")
+	}
+
+	for _, row := range g.Board {
+		for col, tile := range row {
+			if tile == player.ToTile() {
+				row[col] = tileEmpty
+			}
+		}
+	}
+
+	g.markOut(player)
+	g.players = append(g.players[:index], g.players[index+1:]...)
+	g.history = append(g.history, Event{Kind: EventEliminate, Player: player})
+
+	if index < g.currentPlayerIndex {
+		g.currentPlayerIndex--
+	}
+	g.currentPlayerIndex %= len(g.players)
+
+	if len(g.players) == 1 {
+		g.stage = StageOver
+	} else if _, err := g.Winner(); err == nil && g.stage == StatePlaying {
+		g.ProgressStage()
+	}
+
+	return nil
+}
+
+func (g Game) inProgress() bool {
+	return g.stage == StageInit || g.stage == StatePlaying
+}
+
+var ErrorGameNotInProgress = errors.New("game is not in progress")
+
+// Resign eliminates the player from the game at their own request.
+func (g *Game) Resign(player Player) error {
+	if !g.inProgress() {
+		return ErrorGameNotInProgress
+	}
+
+	delete(g.drawAgreements, player)
+	return g.EliminatePlayer(player)
+}
+
+// OfferDraw records that the player agrees to a draw. Once every player has
+// agreed, the game is over. Any move cancels all the agreements.
+func (g *Game) OfferDraw(player Player) error {
+	if !g.inProgress() {
+		return ErrorGameNotInProgress
+	}
+	if !g.PlayerExists(player) {
+		return errors.New("invalid player")
+	}
+
+	if g.drawAgreements == nil {
+		g.drawAgreements = make(map[Player]bool)
+	}
+	g.drawAgreements[player] = true
+
+	for _, p := range g.players {
+		if !g.drawAgreements[p] {
+			return nil
+		}
+	}
+
+	g.declareDraw()
+	return nil
+}
+
+func (g *Game) declareDraw() {
+	g.drawn = true
+	g.stage = StageOver
+	g.history = append(g.history, Event{Kind: EventDraw})
+}
+
+// AcceptDraw agrees to a draw offered by another player.
+func (g *Game) AcceptDraw(player Player) error {
+	if !g.DrawOffered() {
+		return errors.New("no draw was offered")
+	}
+
+	return g.OfferDraw(player)
+}
+
+// DeclineDraw cancels a pending draw offer.
+func (g *Game) DeclineDraw(player Player) error {
+	if !g.DrawOffered() {
+		return errors.New("no draw was offered")
+	}
+	if !g.PlayerExists(player) {
+		return errors.New("invalid player")
+	}
+
+	g.drawAgreements = nil
+	return nil
+}
+
+func (g Game) DrawOffered() bool {
+	return len(g.drawAgreements) > 0 && g.inProgress()
+}
+
+// DrawAgreed returns whether the player has agreed to the pending draw.
+func (g Game) DrawAgreed(player Player) bool {
+	return g.drawAgreements[player]
+}
+
+func (g Game) IsDraw() bool {
+	return g.drawn
+}
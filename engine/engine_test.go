@@ -69,3 +69,249 @@ func TestShiftDown(t *testing.T) {
 	assert.Equal(t, Tile(player1), game.Board[1][0])
 	assert.Equal(t, Tile(player2), game.Board[2][0])
 }
+
+func TestPlayPutProgressesToPlaying(t *testing.T) {
+	player1 := Player(1)
+	player2 := Player(2)
+	game := NewGame(NewBoard(2, 2))
+	game.AddPlayers(player1, player2)
+	game.ProgressStage()
+
+	assert.ErrorIs(t, game.Play(player2, PutMove(0, 0)), ErrorNotYourTurn)
+	assert.NoError(t, game.Play(player1, PutMove(0, 0)))
+	assert.ErrorIs(t, game.Play(player2, PutMove(0, 0)), ErrorTileOccupied)
+	assert.NoError(t, game.Play(player2, PutMove(0, 1)))
+	assert.NoError(t, game.Play(player1, PutMove(1, 0)))
+	assert.NoError(t, game.Play(player2, PutMove(1, 1)))
+
+	assert.Equal(t, StatePlaying, game.Stage())
+	assert.Equal(t, player1, game.CurrentPlayer())
+	assert.ErrorIs(t, game.Play(player1, PutMove(0, 0)), ErrorNotInitStage)
+}
+
+func TestPlayShiftFindsWinner(t *testing.T) {
+	player1 := Player(1)
+	player2 := Player(2)
+	game := NewGame(NewBoard(4, 1))
+	game.AddPlayers(player1, player2)
+	game.ProgressStage()
+
+	assert.NoError(t, game.Play(player1, PutMove(0, 2)))
+	assert.NoError(t, game.Play(player2, PutMove(0, 0)))
+	assert.NoError(t, game.Play(player1, PutMove(0, 3)))
+	assert.NoError(t, game.Play(player2, PutMove(0, 1)))
+
+	assert.NoError(t, game.Play(player1, ShiftMove(DirectionRight, 0)))
+	assert.Equal(t, StatePlaying, game.Stage())
+	assert.NoError(t, game.Play(player2, ShiftMove(DirectionRight, 0)))
+
+	assert.Equal(t, StageOver, game.Stage())
+	winner, err := game.Winner()
+	assert.NoError(t, err)
+	assert.Equal(t, player2, winner)
+}
+
+func TestLegalMoves(t *testing.T) {
+	game := NewGame(NewBoard(3, 2))
+	game.AddPlayers(1, 2)
+	game.ProgressStage()
+
+	assert.Len(t, game.LegalMoves(), 6)
+
+	game.Board.Put(0, 0, Tile(1))
+	assert.Len(t, game.LegalMoves(), 5)
+
+	game.ProgressStage()
+	assert.Len(t, game.LegalMoves(), 2*3+2*2)
+}
+
+func TestThreats(t *testing.T) {
+	game := NewGame(Board{{1, 0, 2}, {0, 2, 0}})
+	game.AddPlayers(1, 2)
+	assert.Empty(t, game.Threats())
+
+	game.ProgressStage()
+	game.ProgressStage()
+
+	tile, err := game.Board.PushedOff(DirectionRight, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Tile(2), tile)
+	_, err = game.Board.PushedOff(DirectionUp, 3)
+	assert.Error(t, err)
+
+	threats := game.Threats()
+	assert.ElementsMatch(t, []Move{ShiftMove(DirectionUp, 0), ShiftMove(DirectionLeft, 0)}, threats[1])
+	assert.ElementsMatch(t, []Move{
+		ShiftMove(DirectionUp, 2),
+		ShiftMove(DirectionDown, 1),
+		ShiftMove(DirectionRight, 0),
+	}, threats[2])
+}
+
+func TestEliminatePlayer(t *testing.T) {
+	game := NewGame(NewBoard(3, 1))
+	game.AddPlayers(1, 2, 3)
+	game.ProgressStage()
+	game.ProgressStage()
+
+	game.Board[0] = []Tile{1, 2, 3}
+	game.NextPlayer()
+
+	assert.NoError(t, game.EliminatePlayer(2))
+	assert.Equal(t, []Tile{1, tileEmpty, 3}, []Tile(game.Board[0]))
+	assert.Equal(t, Player(3), game.CurrentPlayer())
+	assert.Equal(t, StatePlaying, game.Stage())
+
+	assert.NoError(t, game.EliminatePlayer(3))
+	assert.Equal(t, StageOver, game.Stage())
+
+	winner, err := game.Winner()
+	assert.NoError(t, err)
+	assert.Equal(t, Player(1), winner)
+}
+
+func TestStandings(t *testing.T) {
+	game := NewGame(NewBoard(4, 1))
+	game.AddPlayers(1, 2, 3)
+	game.ProgressStage()
+	game.ProgressStage()
+
+	game.Board[0] = []Tile{3, 2, 1, 1}
+
+	assert.NoError(t, game.Play(1, ShiftMove(DirectionLeft, 0)))
+	assert.Equal(t, StatePlaying, game.Stage())
+	assert.Equal(t, [][]Player{{1, 2}, {3}}, game.Standings())
+
+	assert.NoError(t, game.Play(2, ShiftMove(DirectionLeft, 0)))
+	assert.Equal(t, StageOver, game.Stage())
+	assert.Equal(t, [][]Player{{1}, {2}, {3}}, game.Standings())
+}
+
+func TestStandingsTie(t *testing.T) {
+	game := NewGame(NewBoard(2, 1))
+	game.AddPlayers(1, 2, 3)
+	game.ProgressStage()
+	game.ProgressStage()
+
+	game.Board[0] = []Tile{1, 2}
+	assert.NoError(t, game.EliminatePlayer(3))
+
+	assert.NoError(t, game.OfferDraw(1))
+	assert.NoError(t, game.AcceptDraw(2))
+
+	assert.True(t, game.IsDraw())
+	assert.Equal(t, [][]Player{{1, 2}, {3}}, game.Standings())
+}
+
+func TestResign(t *testing.T) {
+	game := NewGame(NewBoard(2, 2))
+	game.AddPlayers(1, 2)
+
+	assert.ErrorIs(t, game.Resign(1), ErrorGameNotInProgress)
+
+	game.ProgressStage()
+	assert.NoError(t, game.Resign(1))
+	assert.Equal(t, StageOver, game.Stage())
+
+	winner, err := game.Winner()
+	assert.NoError(t, err)
+	assert.Equal(t, Player(2), winner)
+}
+
+func TestDrawAgreement(t *testing.T) {
+	game := NewGame(NewBoard(3, 3))
+	game.AddPlayers(1, 2, 3)
+	game.ProgressStage()
+
+	assert.Error(t, game.AcceptDraw(2))
+
+	assert.NoError(t, game.OfferDraw(1))
+	assert.True(t, game.DrawOffered())
+	assert.NoError(t, game.AcceptDraw(2))
+	assert.Equal(t, StageInit, game.Stage())
+
+	assert.NoError(t, game.Play(1, PutMove(0, 0)))
+	assert.False(t, game.DrawOffered())
+
+	assert.NoError(t, game.OfferDraw(1))
+	assert.NoError(t, game.DeclineDraw(3))
+	assert.False(t, game.DrawOffered())
+
+	assert.NoError(t, game.OfferDraw(2))
+	assert.NoError(t, game.AcceptDraw(1))
+	assert.NoError(t, game.AcceptDraw(3))
+
+	assert.Equal(t, StageOver, game.Stage())
+	assert.True(t, game.IsDraw())
+	_, err := game.Winner()
+	assert.Error(t, err)
+}
+
+func TestRemovePlayer(t *testing.T) {
+	game := NewGame(NewBoard(3, 3))
+	game.AddPlayers(1, 2, 3)
+
+	assert.NoError(t, game.RemovePlayer(2))
+	assert.Equal(t, []Player{1, 3}, game.Players())
+	assert.Error(t, game.RemovePlayer(2))
+
+	game.ProgressStage()
+	assert.Error(t, game.RemovePlayer(3))
+}
+
+func TestPass(t *testing.T) {
+	game := NewGame(NewBoard(4, 1))
+	game.AddPlayers(1, 2)
+	game.ProgressStage()
+
+	assert.ErrorIs(t, game.Pass(1), ErrorNotPlayingStage)
+
+	game.ProgressStage()
+	assert.ErrorIs(t, game.Pass(2), ErrorNotYourTurn)
+	assert.NoError(t, game.Pass(1))
+	assert.Equal(t, Player(2), game.CurrentPlayer())
+}
+
+func TestReplay(t *testing.T) {
+	game := NewGame(NewBoard(6, 1))
+	game.AddPlayers(1, 2, 3)
+	game.ProgressStage()
+
+	assert.NoError(t, game.Play(1, PutMove(0, 0)))
+	assert.NoError(t, game.Play(2, PutMove(0, 1)))
+	assert.NoError(t, game.Play(3, PutMove(0, 2)))
+	assert.NoError(t, game.Resign(3))
+	assert.NoError(t, game.Play(1, PutMove(0, 3)))
+
+	assert.Equal(t, []Player{1, 2, 3}, game.StartingPlayers())
+	history := game.History()
+	assert.Len(t, history, 5)
+	assert.Equal(t, Event{Kind: EventEliminate, Player: 3}, history[3])
+
+	positions, err := Replay(6, 1, game.StartingPlayers(), history)
+	assert.NoError(t, err)
+	assert.Len(t, positions, 6)
+
+	assert.Equal(t, 0, positions[0].Board.CountNonEmptyTiles())
+	assert.Equal(t, Board{{1, 2, 3, 0, 0, 0}}, positions[3].Board)
+	assert.Equal(t, Board{{1, 2, 0, 0, 0, 0}}, positions[4].Board)
+	assert.Equal(t, game.Board, positions[5].Board)
+	assert.Equal(t, game.CurrentPlayer(), positions[5].CurrentPlayer())
+
+	// The positions are independent of each other
+	positions[5].Board[0][5] = 2
+	assert.Equal(t, Tile(0), positions[4].Board[0][5])
+}
+
+func TestReplayDraw(t *testing.T) {
+	game := NewGame(NewBoard(4, 1))
+	game.AddPlayers(1, 2)
+	game.ProgressStage()
+
+	assert.NoError(t, game.OfferDraw(1))
+	assert.NoError(t, game.AcceptDraw(2))
+
+	positions, err := Replay(4, 1, game.StartingPlayers(), game.History())
+	assert.NoError(t, err)
+	assert.True(t, positions[len(positions)-1].IsDraw())
+}
@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The position notation writes the board row by row from the top, separating
+// the rows with slashes. Every tile is a single character: '.' for an empty
+// tile, '1' to '9' for the first players and 'a' to 'z' for the players after
+// them. After a space comes the turn order, starting with the player to move.
+// For example "12./.21 21" is a 3x2 board where player 2 is to move.
+//
+// The move notation writes shifts as the direction and the line, counted from
+// 1, like "U2" or "R1", and puts as "P" and the row and the column, like
+// "P2,3".
+
+const notationEmpty = '.'
+
+var (
+	ErrorInvalidPosition = errors.New("invalid position")
+	ErrorInvalidMove     = errors.New("invalid move")
+)
+
+func playerChar(player Player) (byte, bool) {
+	switch {
+	case player >= 1 && player <= 9:
+		return byte('0' + player), true
+	case player >= 10 && player <= 35:
+		return byte('a' + player - 10), true
+	}
+
+	return 0, false
+}
+
+func charPlayer(char byte) (Player, bool) {
+	switch {
+	case char >= '1' && char <= '9':
+		return Player(char - '0'), true
+	case char >= 'a' && char <= 'z':
+		return Player(char-'a') + 10, true
+	}
+
+	return 0, false
+}
+
+// FormatPosition writes the board and the turn order of the game in the
+// position notation
+func FormatPosition(game Game) (string, error) {
+	var builder strings.Builder
+
+	for row := range game.Board {
+		if row > 0 {
+			builder.WriteByte('/')
+		}
+		for _, tile := range game.Board[row] {
+			if tile.IsEmpty() {
+				builder.WriteByte(notationEmpty)
+				continue
+			}
+
+			char, ok := playerChar(Player(tile))
+			if !ok {
+				return "", fmt.Errorf("%w: player %d cannot be written", ErrorInvalidPosition, tile)
+			}
+			builder.WriteByte(char)
+		}
+	}
+
+	builder.WriteByte(' ')
+	for i := range game.players {
+		player := game.players[(game.currentPlayerIndex+i)%len(game.players)]
+		char, ok := playerChar(player)
+		if !ok {
+			return "", fmt.Errorf("%w: player %d cannot be written", ErrorInvalidPosition, player)
+		}
+		builder.WriteByte(char)
+	}
+
+	return builder.String(), nil
+}
+
+// ParsePosition creates a game in the playing stage at the position. The
+// game is over if the position has a winner already.
+func ParsePosition(position string) (Game, error) {
+	fields := strings.Fields(position)
+	if len(fields) != 2 {
+		return Game{}, fmt.Errorf("%w: expected the board and the turn order", ErrorInvalidPosition)
+	}
+
+	players := []Player{}
+	for i := 0; i < len(fields[1]); i++ {
+		player, ok := charPlayer(fields[1][i])
+		if !ok {
+			return Game{}, fmt.Errorf("%w: unknown player %q", ErrorInvalidPosition, fields[1][i])
+		}
+		players = append(players, player)
+	}
+
+	rows := strings.Split(fields[0], "/")
+	board := NewBoard(len(rows[0]), len(rows))
+	for row, line := range rows {
+		if len(line) == 0 || len(line) != len(rows[0]) {
+			return Game{}, fmt.Errorf("%w: the rows are not of the same length", ErrorInvalidPosition)
+		}
+
+		for col := 0; col < len(line); col++ {
+			if line[col] == notationEmpty {
+				continue
+			}
+
+			player, ok := charPlayer(line[col])
+			if !ok || !slices.Contains(players, player) {
+				return Game{}, fmt.Errorf("%w: unknown player %q", ErrorInvalidPosition, line[col])
+			}
+			board[row][col] = player.ToTile()
+		}
+	}
+
+	game := NewGame(board)
+	if err := game.AddPlayers(players...); err != nil || len(players) < MinPlayerCount {
+		return Game{}, fmt.Errorf("%w: invalid turn order", ErrorInvalidPosition)
+	}
+	game.ProgressStage()
+	game.ProgressStage()
+
+	if _, err := game.Winner(); err == nil {
+		game.ProgressStage()
+	} else if board.CountNonEmptyTiles() == 0 {
+		game.drawn = true
+		game.ProgressStage()
+	}
+
+	return game, nil
+}
+
+var directionNotation = map[Direction]byte{
+	DirectionUp:    'U',
+	DirectionDown:  'D',
+	DirectionLeft:  'L',
+	DirectionRight: 'R',
+}
+
+// FormatMove writes the move in the move notation
+func FormatMove(move Move) string {
+	if move.Kind == MovePut {
+		return fmt.Sprintf("P%d,%d", move.Row+1, move.Col+1)
+	}
+
+	return fmt.Sprintf("%c%d", directionNotation[move.Direction], move.Index+1)
+}
+
+// ParseMove reads a move written in the move notation. Whether the move fits
+// the board is checked only when it is played.
+func ParseMove(notation string) (Move, error) {
+	if len(notation) < 2 {
+		return Move{}, ErrorInvalidMove
+	}
+
+	if notation[0] == 'P' {
+		row, col, ok := strings.Cut(notation[1:], ",")
+		if !ok {
+			return Move{}, ErrorInvalidMove
+		}
+		rowNumber, rowErr := strconv.Atoi(row)
+		colNumber, colErr := strconv.Atoi(col)
+		if rowErr != nil || colErr != nil || rowNumber < 1 || colNumber < 1 {
+			return Move{}, ErrorInvalidMove
+		}
+
+		return PutMove(rowNumber-1, colNumber-1), nil
+	}
+
+	for direction, char := range directionNotation {
+		if notation[0] != char {
+			continue
+		}
+
+		index, err := strconv.Atoi(notation[1:])
+		if err != nil || index < 1 {
+			return Move{}, ErrorInvalidMove
+		}
+
+		return ShiftMove(direction, index-1), nil
+	}
+
+	return Move{}, ErrorInvalidMove
+}
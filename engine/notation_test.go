@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositionNotation(t *testing.T) {
+	game, err := ParsePosition("12./.21 21")
+	assert.NoError(t, err)
+	assert.Equal(t, Board{{1, 2, 0}, {0, 2, 1}}, game.Board)
+	assert.Equal(t, StatePlaying, game.Stage())
+	assert.Equal(t, Player(2), game.CurrentPlayer())
+	assert.Equal(t, []Player{2, 1}, game.Players())
+
+	position, err := FormatPosition(game)
+	assert.NoError(t, err)
+	assert.Equal(t, "12./.21 21", position)
+
+	game.Play(2, ShiftMove(DirectionUp, 0))
+	position, err = FormatPosition(game)
+	assert.NoError(t, err)
+	assert.Equal(t, ".2./.21 12", position)
+
+	game, err = ParsePosition("1a/.. a1")
+	assert.NoError(t, err)
+	assert.Equal(t, Player(10), game.CurrentPlayer())
+
+	game, err = ParsePosition("11./... 12")
+	assert.NoError(t, err)
+	assert.Equal(t, StageOver, game.Stage())
+
+	for _, invalid := range []string{"", "12", "12/1 12", "13 12", "12 11", "1-/2. 12"} {
+		_, err := ParsePosition(invalid)
+		assert.ErrorIs(t, err, ErrorInvalidPosition, invalid)
+	}
+}
+
+func TestMoveNotation(t *testing.T) {
+	for _, move := range []Move{ShiftMove(DirectionUp, 0), ShiftMove(DirectionRight, 11), PutMove(1, 2)} {
+		parsed, err := ParseMove(FormatMove(move))
+		assert.NoError(t, err)
+		assert.Equal(t, move, parsed)
+	}
+
+	assert.Equal(t, "L3", FormatMove(ShiftMove(DirectionLeft, 2)))
+	assert.Equal(t, "P1,2", FormatMove(PutMove(0, 1)))
+
+	for _, invalid := range []string{"", "U", "U0", "X1", "P1", "P0,1", "Ua"} {
+		_, err := ParseMove(invalid)
+		assert.ErrorIs(t, err, ErrorInvalidMove, invalid)
+	}
+}
@@ -0,0 +1,443 @@
+package engine
+
+import (
+	"errors"
+	"slices"
+)
+
+// The solver packs a board into a single integer with two bits for each tile:
+// 0 is empty, 1 is the player to move and 2 is their opponent. Positions are
+// stored from the point of view of the player to move, so the same board with
+// the colors swapped is the same position for the other player.
+const (
+	solverMaxTiles = 32
+	solverMover    = 0x5555555555555555
+	solverOpponent = 0xAAAAAAAAAAAAAAAA
+)
+
+// DefaultSolverMaxPositions is enough to solve a 4x4 board with four tiles of
+// each player, in a few seconds. Full 4x4 boards need about four million.
+const DefaultSolverMaxPositions = 1 << 20
+
+var (
+	ErrorSolverPlayers          = errors.New("only games of two players can be solved")
+	ErrorSolverBoardTooLarge    = errors.New("the board is too large to solve")
+	ErrorSolverTooManyPositions = errors.New("the position has too many continuations to solve")
+)
+
+// Solver finds the result of a playing stage position with perfect play,
+// using retrograde analysis of every position reachable from it. Only games
+// of two players can be solved, as with more players the result depends on
+// who the others choose to help. Passing is not considered a move.
+type Solver struct {
+	// MaxPositions is how many positions the solver may visit before it
+	// gives up
+	MaxPositions int
+}
+
+// SolvedMove is the result of a move with perfect play after it
+type SolvedMove struct {
+	Move Move
+	// Winner is 0 for a draw
+	Winner Player
+	// Plies is how many moves the game lasts, including this one
+	Plies int
+}
+
+// Solution is the result of a position with perfect play. The winner wins as
+// fast as possible and the loser loses as slowly as possible. Draws are
+// games which neither player can win, and which never end.
+type Solution struct {
+	// Winner is 0 for a draw
+	Winner Player
+	// Plies is how many moves are left until the game ends, 0 for a draw
+	Plies int
+	// Moves are the results of every legal move of the current player, in
+	// the order of LegalMoves
+	Moves []SolvedMove
+}
+
+// BestMoves returns the moves which achieve the result of the position. All
+// the moves which draw are equally good.
+func (s Solution) BestMoves() []Move {
+	moves := []Move{}
+	for _, move := range s.Moves {
+		if move.Winner == s.Winner && (s.Winner == 0 || move.Plies == s.Plies) {
+			moves = append(moves, move.Move)
+		}
+	}
+
+	return moves
+}
+
+// Solve solves the position with DefaultSolverMaxPositions
+func Solve(game Game) (Solution, error) {
+	return Solver{MaxPositions: DefaultSolverMaxPositions}.Solve(game)
+}
+
+func (s Solver) Solve(game Game) (Solution, error) {
+	if game.stage != StatePlaying {
+		return Solution{}, ErrorNotPlayingStage
+	}
+	if len(game.players) != 2 {
+		return Solution{}, ErrorSolverPlayers
+	}
+
+	geometry, err := newSolverGeometry(game.Board)
+	if err != nil {
+		return Solution{}, err
+	}
+
+	mover := game.CurrentPlayer()
+	opponent := game.players[(game.currentPlayerIndex+1)%2]
+	root := geometry.pack(game.Board, mover, opponent)
+
+	table, err := newSolverTable(geometry, geometry.canonical(root), s.MaxPositions)
+	if err != nil {
+		return Solution{}, err
+	}
+	table.solve()
+
+	// Whoever wins a position from the point of view of the player to move
+	winnerOf := func(result int8, toMove, other Player) Player {
+		switch result {
+		case solverWin:
+			return toMove
+		case solverLoss:
+			return other
+		}
+		return 0
+	}
+
+	solution := Solution{
+		Winner: winnerOf(table.result[0], mover, opponent),
+		Plies:  int(table.plies[0]),
+	}
+	for i, move := range geometry.moves {
+		solved := SolvedMove{Move: move}
+
+		next := geometry.shifts[i].apply(root)
+		switch outcome := solverOutcome(next); outcome {
+		case solverWin:
+			solved.Winner, solved.Plies = mover, 1
+		case solverLoss:
+			solved.Winner, solved.Plies = opponent, 1
+		case solverDraw:
+			solved.Plies = 1
+		default:
+			child := table.index[geometry.canonical(swapSolverPerspective(next))]
+			solved.Winner = winnerOf(table.result[child], opponent, mover)
+			if solved.Winner != 0 {
+				solved.Plies = int(table.plies[child]) + 1
+			}
+		}
+
+		solution.Moves = append(solution.Moves, solved)
+	}
+
+	return solution, nil
+}
+
+// solverPermutation moves the tiles of a packed board around. It has the
+// tiles every byte of the packed board ends up as, for every value of the
+// byte, so moving all the tiles takes a lookup for every four of them.
+type solverPermutation [][256]uint64
+
+// newSolverPermutation creates the permutation from the tile each tile comes
+// from, -1 for a tile which becomes empty
+func newSolverPermutation(sources []int8) solverPermutation {
+	permutation := make(solverPermutation, (len(sources)+3)/4)
+	for tile, source := range sources {
+		if source < 0 {
+			continue
+		}
+
+		for value := 0; value < 256; value++ {
+			moved := uint64(value) >> (2 * (source % 4)) & 3
+			permutation[source/4][value] |= moved << (2 * tile)
+		}
+	}
+
+	return permutation
+}
+
+func (p solverPermutation) apply(key uint64) uint64 {
+	var moved uint64
+	for i := range p {
+		moved |= p[i][byte(key>>(8*i))]
+	}
+
+	return moved
+}
+
+// solverGeometry is how the moves and the symmetries of a board size move
+// the tiles around
+type solverGeometry struct {
+	width, height int
+	moves         []Move
+	shifts        []solverPermutation
+	symmetries    []solverPermutation
+}
+
+func newSolverGeometry(board Board) (solverGeometry, error) {
+	height := len(board)
+	width := len(board[0])
+	if width*height > solverMaxTiles {
+		return solverGeometry{}, ErrorSolverBoardTooLarge
+	}
+
+	geometry := solverGeometry{width: width, height: height}
+	at := func(row, col int) int8 {
+		if row < 0 || row >= height || col < 0 || col >= width {
+			return -1
+		}
+		return int8(row*width + col)
+	}
+	transform := func(source func(row, col int) int8) solverPermutation {
+		tiles := make([]int8, width*height)
+		for row := 0; row < height; row++ {
+			for col := 0; col < width; col++ {
+				tiles[row*width+col] = source(row, col)
+			}
+		}
+		return newSolverPermutation(tiles)
+	}
+
+	game := NewGame(NewBoard(width, height))
+	game.stage = StatePlaying
+	for _, move := range game.LegalMoves() {
+		direction, index := move.Direction, move.Index
+		geometry.moves = append(geometry.moves, move)
+		geometry.shifts = append(geometry.shifts, transform(func(row, col int) int8 {
+			switch {
+			case direction == DirectionRight && row == index:
+				return at(row, col-1)
+			case direction == DirectionLeft && row == index:
+				return at(row, col+1)
+			case direction == DirectionDown && col == index:
+				return at(row-1, col)
+			case direction == DirectionUp && col == index:
+				return at(row+1, col)
+			}
+			return at(row, col)
+		}))
+	}
+
+	last := func(size, i int) int { return size - 1 - i }
+	geometry.symmetries = []solverPermutation{
+		transform(func(row, col int) int8 { return at(row, last(width, col)) }),
+		transform(func(row, col int) int8 { return at(last(height, row), col) }),
+		transform(func(row, col int) int8 { return at(last(height, row), last(width, col)) }),
+	}
+	if width == height {
+		geometry.symmetries = append(geometry.symmetries,
+			transform(func(row, col int) int8 { return at(col, row) }),
+			transform(func(row, col int) int8 { return at(last(width, col), last(height, row)) }),
+			transform(func(row, col int) int8 { return at(col, last(height, row)) }),
+			transform(func(row, col int) int8 { return at(last(width, col), row) }),
+		)
+	}
+
+	return geometry, nil
+}
+
+func (g solverGeometry) pack(board Board, mover, opponent Player) uint64 {
+	var key uint64
+	for row := range board {
+		for col, tile := range board[row] {
+			shift := 2 * (row*g.width + col)
+			switch tile {
+			case mover.ToTile():
+				key |= 1 << shift
+			case opponent.ToTile():
+				key |= 2 << shift
+			}
+		}
+	}
+
+	return key
+}
+
+// canonical returns the smallest of the symmetric positions, which stands
+// for all of them
+func (g solverGeometry) canonical(key uint64) uint64 {
+	smallest := key
+	for _, symmetry := range g.symmetries {
+		smallest = min(smallest, symmetry.apply(key))
+	}
+
+	return smallest
+}
+
+func swapSolverPerspective(key uint64) uint64 {
+	return (key&solverMover)<<1 | (key&solverOpponent)>>1
+}
+
+const (
+	solverUnknown int8 = iota
+	solverWin
+	solverLoss
+	solverDraw
+)
+
+// solverOutcome returns whether the position after a move ended the game,
+// from the point of view of the player who moved
+func solverOutcome(key uint64) int8 {
+	mover := key&solverMover != 0
+	opponent := key&solverOpponent != 0
+	switch {
+	case mover && opponent:
+		return solverUnknown
+	case mover:
+		return solverWin
+	case opponent:
+		return solverLoss
+	}
+
+	return solverDraw
+}
+
+// solverTable holds every position reachable from the root, which is the
+// first one, and the positions each of them leads to
+type solverTable struct {
+	index map[uint64]int32
+	// children of position i are children[offsets[i]:offsets[i+1]]
+	children []int32
+	offsets  []int32
+	// terminal is the best outcome of the moves which end the game
+	terminal []int8
+
+	result []int8
+	plies  []int16
+}
+
+func newSolverTable(geometry solverGeometry, root uint64, maxPositions int) (*solverTable, error) {
+	table := &solverTable{index: map[uint64]int32{root: 0}}
+	keys := []uint64{root}
+
+	for i := 0; i < len(keys); i++ {
+		table.offsets = append(table.offsets, int32(len(table.children)))
+		start := len(table.children)
+		terminal := solverUnknown
+
+		for _, shift := range geometry.shifts {
+			next := shift.apply(keys[i])
+
+			switch outcome := solverOutcome(next); outcome {
+			case solverWin:
+				terminal = solverWin
+				continue
+			case solverDraw:
+				if terminal != solverWin {
+					terminal = solverDraw
+				}
+				continue
+			case solverLoss:
+				if terminal == solverUnknown {
+					terminal = solverLoss
+				}
+				continue
+			}
+
+			child := geometry.canonical(swapSolverPerspective(next))
+			childIndex, ok := table.index[child]
+			if !ok {
+				if len(keys) >= maxPositions {
+					return nil, ErrorSolverTooManyPositions
+				}
+				childIndex = int32(len(keys))
+				table.index[child] = childIndex
+				keys = append(keys, child)
+			}
+
+			if !slices.Contains(table.children[start:], childIndex) {
+				table.children = append(table.children, childIndex)
+			}
+		}
+
+		table.terminal = append(table.terminal, terminal)
+	}
+	table.offsets = append(table.offsets, int32(len(table.children)))
+
+	return table, nil
+}
+
+// solve finds the result of every position, working back from the ends of
+// the game. Positions which are never resolved are draws.
+func (t *solverTable) solve() {
+	count := len(t.terminal)
+	t.result = make([]int8, count)
+	t.plies = make([]int16, count)
+
+	// Index the positions which lead to every position
+	parentOffsets := make([]int32, count+1)
+	for _, child := range t.children {
+		parentOffsets[child+1]++
+	}
+	for i := 0; i < count; i++ {
+		parentOffsets[i+1] += parentOffsets[i]
+	}
+	parents := make([]int32, len(t.children))
+	filled := append([]int32{}, parentOffsets[:count]...)
+	for parent := 0; parent < count; parent++ {
+		for _, child := range t.children[t.offsets[parent]:t.offsets[parent+1]] {
+			parents[filled[child]] = int32(parent)
+			filled[child]++
+		}
+	}
+
+	// A position is lost once all of its moves are, so it counts the moves
+	// which might still save it. A move which draws at once always does.
+	remaining := make([]int32, count)
+	queue := make([]int32, 0, count)
+	for i := 0; i < count; i++ {
+		remaining[i] = t.offsets[i+1] - t.offsets[i]
+
+		switch t.terminal[i] {
+		case solverWin:
+			t.result[i], t.plies[i] = solverWin, 1
+			queue = append(queue, int32(i))
+			continue
+		case solverDraw:
+			remaining[i]++
+		case solverLoss:
+			t.plies[i] = 1
+		}
+
+		if remaining[i] == 0 {
+			t.result[i], t.plies[i] = solverLoss, 1
+			queue = append(queue, int32(i))
+		}
+	}
+
+	// The queue is in the order of plies, so every position is resolved by
+	// its fastest win or its slowest loss
+	for head := 0; head < len(queue); head++ {
+		child := queue[head]
+
+		for _, parent := range parents[parentOffsets[child]:parentOffsets[child+1]] {
+			if t.result[parent] != solverUnknown {
+				continue
+			}
+
+			if t.result[child] == solverLoss {
+				t.result[parent], t.plies[parent] = solverWin, t.plies[child]+1
+				queue = append(queue, parent)
+				continue
+			}
+
+			remaining[parent]--
+			t.plies[parent] = max(t.plies[parent], t.plies[child]+1)
+			if remaining[parent] == 0 {
+				t.result[parent] = solverLoss
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	for i := range t.result {
+		if t.result[i] == solverUnknown {
+			t.result[i], t.plies[i] = solverDraw, 0
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPlayingGame(board Board, current Player) Game {
+	game := NewGame(board)
+	game.AddPlayers(1, 2)
+	game.ProgressStage()
+	game.ProgressStage()
+	if current == 2 {
+		game.NextPlayer()
+	}
+
+	return game
+}
+
+// forcedWin searches every line of play for a win of the current player
+// within the given number of moves
+func forcedWin(game Game, plies int) bool {
+	if plies <= 0 {
+		return false
+	}
+
+	player := game.CurrentPlayer()
+	for _, move := range game.LegalMoves() {
+		next := game.Clone()
+		next.Play(player, move)
+		if next.Stage() == StageOver {
+			if winner, err := next.Winner(); err == nil && winner == player {
+				return true
+			}
+			continue
+		}
+
+		refuted := false
+		opponent := next.CurrentPlayer()
+		for _, reply := range next.LegalMoves() {
+			after := next.Clone()
+			after.Play(opponent, reply)
+			if after.Stage() == StageOver {
+				if winner, err := after.Winner(); err != nil || winner != player {
+					refuted = true
+				}
+			} else if !forcedWin(after, plies-2) {
+				refuted = true
+			}
+			if refuted {
+				break
+			}
+		}
+		if !refuted {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestSolveWinInOne(t *testing.T) {
+	game := newPlayingGame(Board{{1, 2, 0}}, 1)
+
+	solution, err := Solve(game)
+	assert.NoError(t, err)
+	assert.Equal(t, Player(1), solution.Winner)
+	assert.Equal(t, 1, solution.Plies)
+	assert.Equal(t, []Move{ShiftMove(DirectionUp, 1), ShiftMove(DirectionDown, 1)}, solution.BestMoves())
+
+	for _, move := range solution.Moves {
+		if move.Move == ShiftMove(DirectionLeft, 0) {
+			assert.Equal(t, Player(2), move.Winner)
+			assert.Equal(t, 1, move.Plies)
+		}
+	}
+}
+
+func TestSolveLoss(t *testing.T) {
+	// Whatever the first player does, the second pushes a tile of theirs off
+	game := newPlayingGame(Board{{2, 1}, {1, 2}}, 1)
+
+	solution, err := Solve(game)
+	assert.NoError(t, err)
+	assert.Len(t, solution.Moves, len(game.LegalMoves()))
+	assert.NotEmpty(t, solution.BestMoves())
+	if solution.Winner == 2 {
+		assert.Zero(t, solution.Plies%2)
+	}
+}
+
+func TestSolveMatchesSearch(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 40; i++ {
+		board := NewBoard(3, 3)
+		for _, player := range []Player{1, 2, 1, 2, 1, 2} {
+			for {
+				row, col := random.Intn(3), random.Intn(3)
+				if board[row][col].IsEmpty() {
+					board[row][col] = player.ToTile()
+					break
+				}
+			}
+		}
+		game := newPlayingGame(board, Player(1+random.Intn(2)))
+		player := game.CurrentPlayer()
+
+		solution, err := Solve(game)
+		assert.NoError(t, err)
+
+		switch {
+		case solution.Winner == player && solution.Plies <= 5:
+			assert.True(t, forcedWin(game, solution.Plies), "no win in %d on %v", solution.Plies, board)
+			assert.False(t, forcedWin(game, solution.Plies-2), "faster win than %d on %v", solution.Plies, board)
+		case solution.Winner != player:
+			assert.False(t, forcedWin(game, 5), "missed a win on %v", board)
+		}
+
+		for _, move := range solution.BestMoves() {
+			next := game.Clone()
+			assert.NoError(t, next.Play(player, move))
+			if next.Stage() == StageOver {
+				continue
+			}
+
+			after, err := Solve(next)
+			assert.NoError(t, err)
+			assert.Equal(t, solution.Winner, after.Winner)
+			if solution.Winner != 0 {
+				assert.Equal(t, solution.Plies-1, after.Plies)
+			}
+		}
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	game := NewGame(NewBoard(3, 3))
+	game.AddPlayers(1, 2)
+	_, err := Solve(game)
+	assert.ErrorIs(t, err, ErrorNotPlayingStage)
+
+	game = NewGame(NewBoard(3, 3))
+	game.AddPlayers(1, 2, 3)
+	game.ProgressStage()
+	game.ProgressStage()
+	_, err = Solve(game)
+	assert.ErrorIs(t, err, ErrorSolverPlayers)
+
+	game = newPlayingGame(NewBoard(6, 6), 1)
+	_, err = Solve(game)
+	assert.ErrorIs(t, err, ErrorSolverBoardTooLarge)
+
+	game = newPlayingGame(Board{{1, 2, 1}, {2, 1, 2}, {1, 2, 0}}, 1)
+	_, err = Solver{MaxPositions: 1}.Solve(game)
+	assert.ErrorIs(t, err, ErrorSolverTooManyPositions)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/api"
+	"github.com/Denloob/cadere/engine"
+)
+
+// requestGameToken returns the game token of an API request, sent either as
+// a bearer token or as the game cookie
+func requestGameToken(c echo.Context) (string, bool) {
+	if token, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer "); ok {
+		return token, true
+	}
+
+	cookie, err := c.Cookie(SessionCookieName)
+	if err != nil {
+		return "", false
+	}
+
+	return cookie.Value, true
+}
+
+// wantsJSON returns whether the client asked for JSON rather than the HTML
+// of the browsers
+func wantsJSON(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMEApplicationJSON)
+}
+
+// jsonConn is the game websocket of a client which accepts JSON. Instead of
+// the HTML of the browsers, it is sent an update message whenever the game
+// changes.
+type jsonConn struct {
+	mutex sync.Mutex
+	ws    *websocket.Conn
+}
+
+func (c *jsonConn) WriteMessage(messageType int, data []byte) error {
+	if messageType == websocket.TextMessage {
+		return c.send(api.GameMessage{Type: api.MessageUpdate})
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.ws.WriteMessage(messageType, data)
+}
+
+func (c *jsonConn) send(message api.GameMessage) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.ws.WriteJSON(message)
+}
+
+func (c *jsonConn) Close() error {
+	return c.ws.Close()
+}
+
+// gameState describes the game to the player. The caller must hold the
+// SessionMutex.
+func (webSession *WebGameSession) gameState(player engine.Player) api.GameState {
+	session := webSession.Session
+	game := session.Game
+
+	players := []api.Player{}
+	for _, p := range game.Players() {
+		profile := session.Profile(p)
+		_, isBot := webSession.Bots[p]
+		if username := session.Account(p); username != "" && !isBot {
+			account, err := accountStore.Get(username)
+			isBot = err == nil && account.Bot
+		}
+		players = append(players, api.Player{
+			Player: p,
+			Name:   profile.Name,
+			Color:  profile.Color,
+			Bot:    isBot,
+		})
+	}
+
+	state := api.GameState{
+		Id:          gameUrlId(session.Nonce()),
+		GameId:      session.Nonce(),
+		Stage:       api.StageName(game.Stage()),
+		Board:       game.Clone().Board,
+		Players:     players,
+		Moves:       len(game.History()),
+		You:         player,
+		Host:        webSession.Host == player,
+		Draw:        game.IsDraw(),
+		DrawOffered: game.DrawOffered(),
+	}
+
+	if game.Stage() == engine.StageInit || game.Stage() == engine.StatePlaying {
+		state.CurrentPlayer = game.CurrentPlayer()
+	}
+	if game.Stage() == engine.StageOver {
+		if winner, err := game.Winner(); err == nil {
+			state.Winner = winner
+		}
+	}
+
+	return state
+}
+
+func registerGameApiRoutes(e *echo.Echo) {
+	e.GET("/api/game", func(c echo.Context) error {
+		token, ok := requestGameToken(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "missing game token"})
+		}
+
+		webSession, err := games.GetWebSessionForToken(token)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "no such game"})
+		}
+		player, err := webSession.Session.ExtractPlayerFromToken(token)
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid game token"})
+		}
+
+		webSession.SessionMutex.RLock()
+		defer webSession.SessionMutex.RUnlock()
+
+		if !webSession.IsMember(player) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "not a member of the game"})
+		}
+
+		return c.JSON(http.StatusOK, webSession.gameState(player))
+	})
+}
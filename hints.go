@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/bot"
+	"github.com/Denloob/cadere/engine"
+)
+
+// HINT_BOT is the bot whose move is suggested to the player to move
+const HINT_BOT = "perfect"
+
+// ShiftHint is what the hints say about a single shift
+type ShiftHint struct {
+	// Threatened is the player who would lose a tile to the shift, or nil
+	Threatened *auth.PlayerProfile
+	// Best is whether the hint bot would play the shift
+	Best bool
+}
+
+// Hints point out the shifts which push a tile of a player off the board,
+// and the shift the bot would play for the player to move
+type Hints struct {
+	shifts map[engine.Move]ShiftHint
+}
+
+// newHints computes the hints of a game in the playing stage. The caller
+// must hold the SessionMutex.
+func newHints(webSession *WebGameSession) *Hints {
+	session := webSession.Session
+	game := session.Game
+	hints := &Hints{shifts: make(map[engine.Move]ShiftHint)}
+
+	for player, moves := range game.Threats() {
+		profile := session.Profile(player)
+		for _, move := range moves {
+			hints.shifts[move] = ShiftHint{Threatened: &profile}
+		}
+	}
+
+	// Bots play right away, so there is nothing to suggest to them
+	if _, isBot := webSession.Bots[game.CurrentPlayer()]; isBot {
+		return hints
+	}
+
+	hintBot, err := bot.New(HINT_BOT)
+	if err != nil {
+		return hints
+	}
+	if best, err := hintBot.Move(*game); err == nil {
+		hint := hints.shifts[best]
+		hint.Best = true
+		hints.shifts[best] = hint
+	}
+
+	return hints
+}
+
+// currentHints returns the hints of the current position, computing them
+// only once per move. The caller must hold the SessionMutex.
+func (webSession *WebGameSession) currentHints() *Hints {
+	webSession.hintsMutex.Lock()
+	defer webSession.hintsMutex.Unlock()
+
+	moves := len(webSession.Session.Game.History())
+	if webSession.hints == nil || webSession.hintsMoves != moves {
+		webSession.hints = newHints(webSession)
+		webSession.hintsMoves = moves
+	}
+
+	return webSession.hints
+}
+
+// Shift returns the hint for shifting the line at the index in the
+// direction, named as in the shift actions
+func (h *Hints) Shift(direction string, index int) ShiftHint {
+	if h == nil {
+		return ShiftHint{}
+	}
+
+	parsed, err := engine.ParseDirection(direction)
+	if err != nil {
+		return ShiftHint{}
+	}
+
+	return h.shifts[engine.ShiftMove(parsed, index)]
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+
+	"github.com/Denloob/cadere/archive"
+	"github.com/Denloob/cadere/engine"
+)
+
+var archiveStore *archive.Store
+
+// archiveLogger logs the games which could not be archived. main replaces it
+// with the logger of the server.
+var archiveLogger echo.Logger = log.New("archive")
+
+// gameUrlId converts the nonce of a game to an ID which can be used in URLs
+func gameUrlId(nonce string) string {
+	raw, err := base64.URLEncoding.DecodeString(nonce)
+	if err != nil {
+		return base64.RawURLEncoding.EncodeToString([]byte(nonce))
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// recordFinishedGame archives the game and updates the ratings once it is
+// over. A game which could not be archived is tried again the next time.
+// Returns whether the game screen changed. The caller must hold the
+// SessionMutex.
+func (webSession *WebGameSession) recordFinishedGame() bool {
+	if webSession.Session.Game.Stage() != engine.StageOver {
+		return false
+	}
+
+	if !webSession.Archived {
+		err := archiveStore.Add(webSession.archiveRecord(time.Now()))
+		if err != nil && !errors.Is(err, archive.ErrorAlreadyArchived) {
+			archiveLogger.Error(err)
+		} else {
+			webSession.Archived = true
+		}
+	}
+
+	return webSession.recordRating()
+}
+
+// archiveRecord creates the archive record of a finished game. The caller
+// must hold the SessionMutex.
+func (webSession *WebGameSession) archiveRecord(finished time.Time) archive.Record {
+	session := webSession.Session
+	game := session.Game
+
+	var players []archive.PlayerRecord
+	for _, player := range game.StartingPlayers() {
+		profile := session.Profile(player)
+		_, isBot := webSession.Bots[player]
+
+		players = append(players, archive.PlayerRecord{
+			Player:   player,
+			Username: session.Account(player),
+			Name:     profile.Name,
+			Color:    profile.Color,
+			Bot:      isBot,
+		})
+	}
+
+	winner, err := game.Winner()
+	if err != nil {
+		winner = 0
+	}
+
+	return archive.Record{
+		Id:       gameUrlId(session.Nonce()),
+		Width:    len(game.Board[0]),
+		Height:   len(game.Board),
+		Players:  players,
+		History:  game.History(),
+		Winner:   winner,
+		Draw:     game.IsDraw(),
+		Rated:    webSession.Rated,
+		Started:  webSession.StartedAt,
+		Finished: finished,
+	}
+}
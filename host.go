@@ -0,0 +1,215 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/clock"
+	"github.com/Denloob/cadere/engine"
+)
+
+const HOST_RECONNECT_GRACE = 30 * time.Second
+
+var WebsocketCloseKicked = websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "kicked")
+
+func (webSession *WebGameSession) requireHost(player engine.Player) error {
+	if player != webSession.Host {
+		return GameErrorf("Only the Host can do that")
+	}
+
+	return nil
+}
+
+func (webSession *WebGameSession) requireLobby() error {
+	if webSession.Session.Game.Stage() != engine.StageLobby {
+		return GameErrorf("Game has already started")
+	}
+
+	return nil
+}
+
+// IsMember returns whether the token of the player is still good for this
+// game. Players who resigned or were eliminated stay members, so that they
+// can watch the rest of the game and ask for a rematch. Only kicked players
+// lose their membership.
+func (webSession *WebGameSession) IsMember(player engine.Player) bool {
+	if webSession.Kicked[player] {
+		return false
+	}
+
+	return len(webSession.seats(player)) > 0 ||
+		slices.Contains(webSession.StartingOrder, player) ||
+		slices.Contains(webSession.Session.Game.StartingPlayers(), player)
+}
+
+// NewTokenForPlayer issues a token for the player, with the host role if they
+// are the host. The caller must hold the SessionMutex.
+func (webSession *WebGameSession) NewTokenForPlayer(player engine.Player) (string, error) {
+	role := auth.RolePlayer
+	if player == webSession.Host {
+		role = auth.RoleHost
+	}
+
+	return webSession.Session.NewTokenWithRole(player, role)
+}
+
+// nextPlayerID returns an ID which was never used in this game, so that
+// tokens of kicked players can't be reused
+func (webSession *WebGameSession) nextPlayerID() engine.Player {
+	maxPlayer := engine.Player(0)
+	for _, player := range webSession.Session.Game.Players() {
+		maxPlayer = max(maxPlayer, player)
+	}
+	for player := range webSession.Kicked {
+		maxPlayer = max(maxPlayer, player)
+	}
+
+	return maxPlayer + 1
+}
+
+// kick removes a player from the lobby and closes their sockets
+func (webSession *WebGameSession) kick(player, target engine.Player) error {
+	if err := webSession.requireHost(player); err != nil {
+		return err
+	}
+	if err := webSession.requireLobby(); err != nil {
+		return err
+	}
+	if target == player {
+		return GameErrorf("You cannot kick yourself")
+	}
+
+	if err := webSession.Session.Game.RemovePlayer(target); err != nil {
+		return ErrorBadRequest
+	}
+	webSession.Kicked[target] = true
+	webSession.Session.RevokePlayer(target)
+	delete(webSession.Bots, target)
+	delete(webSession.LocalSeats, target)
+
+	// The local seats leave together with the browser they are played from
+	for seat, owner := range webSession.LocalSeats {
+		if owner == target && webSession.Session.Game.RemovePlayer(seat) == nil {
+			webSession.Kicked[seat] = true
+			delete(webSession.LocalSeats, seat)
+		}
+	}
+
+	notice, err := templates.RenderToBytes("kickedNotice", nil)
+	if err != nil {
+		return err
+	}
+
+	webSession.FilterForEach(func(conn *GameSocket) bool {
+		if conn.Player != target {
+			return true
+		}
+
+		conn.WriteMessage(websocket.TextMessage, notice)
+		conn.WriteMessage(websocket.CloseMessage, WebsocketCloseKicked)
+		conn.Close()
+		return false
+	})
+
+	lobby.Notify()
+	return nil
+}
+
+func (webSession *WebGameSession) transferHost(player, target engine.Player) error {
+	if err := webSession.requireHost(player); err != nil {
+		return err
+	}
+
+	_, isBot := webSession.Bots[target]
+	_, isLocal := webSession.LocalSeats[target]
+	if isBot || isLocal || !webSession.IsMember(target) {
+		return ErrorBadRequest
+	}
+
+	webSession.Host = target
+	return nil
+}
+
+// changeSettings changes the board size, time control, visibility and hints
+// of a game which has not started yet
+func (webSession *WebGameSession) changeSettings(player engine.Player, action GameAction) error {
+	if err := webSession.requireHost(player); err != nil {
+		return err
+	}
+	if err := webSession.requireLobby(); err != nil {
+		return err
+	}
+
+	game := webSession.Session.Game
+
+	size, err := strconv.Atoi(action.FormValue("size"))
+	if err != nil || size < GAME_SIZE_MIN || size > GAME_SIZE_MAX {
+		return GameErrorf("Board cannot be smaller than %d or larger than %d", GAME_SIZE_MIN, GAME_SIZE_MAX)
+	}
+
+	board := engine.NewBoard(size, size)
+	if board.MaxPlayerCount(engine.MinTilesPerPlayer) < game.PlayerCount() {
+		return GameErrorf("The board is too small for %d players", game.PlayerCount())
+	}
+
+	timeControl, err := parseTimeControl(action.FormValue)
+	if err != nil {
+		return GameError{err}
+	}
+
+	game.Board = board
+	webSession.Clock = clock.New(timeControl)
+	webSession.Public = action.FormValue("public") != ""
+	webSession.Hints = action.FormValue("hints") != "" && !webSession.Rated
+
+	lobby.Notify()
+	return nil
+}
+
+func (webSession *WebGameSession) hasSocket(player engine.Player) bool {
+	webSession.socketsMutex.RLock()
+	defer webSession.socketsMutex.RUnlock()
+
+	for _, conn := range webSession.Sockets {
+		if conn.Player == player {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HandleDisconnect passes the host status on to another connected player if
+// the host does not reconnect in time
+func (webSession *WebGameSession) HandleDisconnect(player engine.Player) {
+	if webSession.hasSocket(player) {
+		return
+	}
+
+	time.AfterFunc(HOST_RECONNECT_GRACE, func() {
+		webSession.SessionMutex.Lock()
+		defer webSession.SessionMutex.Unlock()
+
+		if webSession.Host != player || webSession.hasSocket(player) {
+			return
+		}
+
+		for _, candidate := range webSession.Session.Game.Players() {
+			if candidate != player && webSession.hasSocket(candidate) {
+				webSession.Host = candidate
+				break
+			}
+		}
+		if webSession.Host == player {
+			return
+		}
+
+		if response, err := templates.RenderToBytes("gameScreen", NewGameView(webSession)); err == nil {
+			webSession.Broadcast(response)
+		}
+	})
+}
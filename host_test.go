@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Denloob/cadere/archive"
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/clock"
+	"github.com/Denloob/cadere/engine"
+)
+
+// newTestGame creates a game of the host and a second player, still in the
+// lobby
+func newTestGame(t *testing.T) *WebGameSession {
+	var err error
+	archiveStore, err = archive.NewStore("")
+	assert.NoError(t, err)
+
+	session, err := newGameSession(3, auth.DefaultProfile(CreatorPlayerID), "")
+	assert.NoError(t, err)
+	webSession := games.AddSession(session, clock.TimeControl{})
+
+	webSession.SessionMutex.Lock()
+	defer webSession.SessionMutex.Unlock()
+	assert.NoError(t, webSession.joinPlayer(2, auth.DefaultProfile(2), ""))
+
+	return webSession
+}
+
+// reload requests the game page with the token of the player
+func reload(t *testing.T, webSession *WebGameSession, player engine.Player) *httptest.ResponseRecorder {
+	webSession.SessionMutex.RLock()
+	token, err := webSession.NewTokenForPlayer(player)
+	webSession.SessionMutex.RUnlock()
+	assert.NoError(t, err)
+
+	e := echo.New()
+	e.Renderer = templates
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(&http.Cookie{Name: SessionCookieName, Value: token})
+	recorder := httptest.NewRecorder()
+
+	assert.NoError(t, gamePage(e.NewContext(request, recorder)))
+	return recorder
+}
+
+func TestResignedPlayerStaysMember(t *testing.T) {
+	webSession := newTestGame(t)
+
+	_, err := webSession.ExecuteAction(GameAction{Action: "start"}, CreatorPlayerID)
+	assert.NoError(t, err)
+	_, err = webSession.ExecuteAction(GameAction{Action: "resign"}, 2)
+	assert.NoError(t, err)
+
+	webSession.SessionMutex.RLock()
+	assert.Equal(t, engine.StageOver, webSession.Session.Game.Stage())
+	assert.True(t, webSession.IsMember(2))
+	webSession.SessionMutex.RUnlock()
+
+	recorder := reload(t, webSession, 2)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Rematch")
+}
+
+func TestKickedPlayerIsNotMember(t *testing.T) {
+	webSession := newTestGame(t)
+
+	_, err := webSession.ExecuteAction(GameAction{Action: "kick", Player: 2}, CreatorPlayerID)
+	assert.NoError(t, err)
+
+	webSession.SessionMutex.RLock()
+	assert.False(t, webSession.IsMember(2))
+	webSession.SessionMutex.RUnlock()
+
+	recorder := reload(t, webSession, 2)
+	assert.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "/new", recorder.Header().Get(echo.HeaderLocation))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+// controls returns whether the player plays for the seat, either because it
+// is their own or because it is one of their local seats
+func (webSession *WebGameSession) controls(player, seat engine.Player) bool {
+	return seat == player || webSession.LocalSeats[seat] == player
+}
+
+// seats returns the players in the game the player plays for, in turn order
+func (webSession *WebGameSession) seats(player engine.Player) []engine.Player {
+	var seats []engine.Player
+	for _, seat := range webSession.Session.Game.Players() {
+		if webSession.controls(player, seat) {
+			seats = append(seats, seat)
+		}
+	}
+
+	return seats
+}
+
+// actingPlayer returns the player an action from the player's socket is
+// played as. Moves are played for whichever of the player's seats has the
+// turn, and a resignation can name one of the local seats.
+func (webSession *WebGameSession) actingPlayer(action GameAction, player engine.Player) (engine.Player, error) {
+	game := webSession.Session.Game
+
+	switch action.Action {
+	case "put", "shift":
+		if current := game.CurrentPlayer(); webSession.controls(player, current) {
+			return current, nil
+		}
+	case "resign":
+		if action.Player == 0 {
+			break
+		}
+		if !webSession.controls(player, action.Player) {
+			return 0, ErrorBadRequest
+		}
+		return action.Player, nil
+	}
+
+	return player, nil
+}
+
+// addLocalPlayer seats another player who plays from the same browser
+func (webSession *WebGameSession) addLocalPlayer(player engine.Player, action GameAction) error {
+	session := webSession.Session
+	game := session.Game
+
+	if _, isLocal := webSession.LocalSeats[player]; isLocal {
+		return ErrorBadRequest
+	}
+	if webSession.Rated {
+		return GameErrorf("Rated games cannot have local players")
+	}
+	if err := checkJoinable(game); err != nil {
+		return GameError{err}
+	}
+
+	seat := webSession.nextPlayerID()
+	profile, err := parseProfile(action.FormValue, seat)
+	if err != nil {
+		return GameError{err}
+	}
+	for _, other := range game.Players() {
+		if session.Profile(other).Color == profile.Color {
+			return GameErrorf("This color is already taken")
+		}
+	}
+
+	if err := game.AddPlayers(seat); err != nil {
+		return err
+	}
+	session.SetProfile(seat, profile)
+	webSession.LocalSeats[seat] = player
+
+	lobby.Notify()
+	return nil
+}
+
+// removeLocalPlayer removes a local seat of the player from the lobby
+func (webSession *WebGameSession) removeLocalPlayer(player, seat engine.Player) error {
+	if err := webSession.requireLobby(); err != nil {
+		return err
+	}
+	if _, isLocal := webSession.LocalSeats[seat]; !isLocal {
+		return ErrorBadRequest
+	}
+	if !webSession.controls(player, seat) && player != webSession.Host {
+		return ErrorBadRequest
+	}
+
+	if err := webSession.Session.Game.RemovePlayer(seat); err != nil {
+		return ErrorBadRequest
+	}
+	delete(webSession.LocalSeats, seat)
+	webSession.Kicked[seat] = true
+
+	lobby.Notify()
+	return nil
+}
+
+// localDrawAction agrees to or declines a draw for all the seats of the player
+func (webSession *WebGameSession) localDrawAction(action string, player engine.Player) error {
+	game := webSession.Session.Game
+
+	seats := webSession.seats(player)
+	if len(seats) == 0 {
+		return ErrorBadRequest
+	}
+
+	switch action {
+	case "offerDraw":
+		if err := drawAction(game.OfferDraw, seats[0]); err != nil {
+			return err
+		}
+	case "acceptDraw":
+		if err := drawAction(game.AcceptDraw, seats[0]); err != nil {
+			return err
+		}
+	case "declineDraw":
+		return drawAction(game.DeclineDraw, seats[0])
+	default:
+		return fmt.Errorf("unknown draw action: %s", action)
+	}
+
+	for _, seat := range seats[1:] {
+		if game.Stage() == engine.StageOver {
+			break
+		}
+		if err := drawAction(game.OfferDraw, seat); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+// LobbyGame is a public game which is waiting for players
+type LobbyGame struct {
+	Nonce          string
+	Width          int
+	Height         int
+	PlayerCount    int
+	MaxPlayerCount int
+	Rated          bool
+}
+
+// Lobby keeps the sockets of everyone browsing the public games, and pushes
+// the game list to them whenever it might have changed.
+type Lobby struct {
+	socketsMutex sync.Mutex
+	sockets      []GameConn
+
+	changed chan struct{}
+}
+
+var lobby = NewLobby()
+
+func NewLobby() *Lobby {
+	return &Lobby{
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// Notify signals that the public game list might have changed. It never
+// blocks, so it is safe to call while holding any of the session locks.
+func (l *Lobby) Notify() {
+	select {
+	case l.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Run broadcasts the game list after every notification
+func (l *Lobby) Run() {
+	for range l.changed {
+		response, err := templates.RenderToBytes("lobbyGames", games.PublicGames())
+		if err != nil {
+			continue
+		}
+
+		l.socketsMutex.Lock()
+		var newSockets []GameConn
+		for _, conn := range l.sockets {
+			if conn.WriteMessage(websocket.TextMessage, response) != websocket.ErrCloseSent {
+				newSockets = append(newSockets, conn)
+			}
+		}
+		l.sockets = newSockets
+		l.socketsMutex.Unlock()
+	}
+}
+
+func (l *Lobby) addSocket(conn GameConn) {
+	l.socketsMutex.Lock()
+	defer l.socketsMutex.Unlock()
+
+	l.sockets = append(l.sockets, conn)
+}
+
+func (l *Lobby) removeSocket(conn GameConn) {
+	l.socketsMutex.Lock()
+	defer l.socketsMutex.Unlock()
+
+	var newSockets []GameConn
+	for _, currConn := range l.sockets {
+		if currConn != conn {
+			newSockets = append(newSockets, currConn)
+		}
+	}
+	l.sockets = newSockets
+}
+
+// PublicGames returns the public games which can still be joined
+func (g Games) PublicGames() []LobbyGame {
+	// The sessions are locked only after gamesMutex is released, because a
+	// rematch adds its session while holding the SessionMutex
+	gamesMutex.RLock()
+	sessions := make(map[string]*WebGameSession, len(g))
+	for nonce, webSession := range g {
+		sessions[nonce] = webSession
+	}
+	gamesMutex.RUnlock()
+
+	publicGames := []LobbyGame{}
+	for nonce, webSession := range sessions {
+		webSession.SessionMutex.RLock()
+		game := webSession.Session.Game
+
+		maxPlayerCount := game.Board.MaxPlayerCount(engine.MinTilesPerPlayer)
+		if webSession.Public && game.Stage() == engine.StageLobby && game.PlayerCount() < maxPlayerCount {
+			publicGames = append(publicGames, LobbyGame{
+				Nonce:          nonce,
+				Width:          len(game.Board[0]),
+				Height:         len(game.Board),
+				PlayerCount:    game.PlayerCount(),
+				MaxPlayerCount: maxPlayerCount,
+				Rated:          webSession.Rated,
+			})
+		}
+		webSession.SessionMutex.RUnlock()
+	}
+
+	sort.Slice(publicGames, func(i, j int) bool {
+		return publicGames[i].Nonce < publicGames[j].Nonce
+	})
+
+	return publicGames
+}
+
+func registerLobbyRoutes(e *echo.Echo) {
+	e.GET("/lobby", func(c echo.Context) error {
+		return c.Render(http.StatusOK, "lobby", games.PublicGames())
+	})
+
+	e.GET("/lobby/ws", func(c echo.Context) error {
+		ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+
+		lobby.addSocket(ws)
+		defer lobby.removeSocket(ws)
+
+		// The list is pushed by the server, so only wait for the socket to close
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return nil
+			}
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/engine"
+)
+
+const BrowserCookieName = "browser"
+
+const (
+	BROWSER_GAMES_MAX      = 20
+	BROWSER_COOKIE_MAX_AGE = 365 * 24 * time.Hour
+)
+
+// StoredToken is a game token remembered for a browser or account
+type StoredToken struct {
+	GameId string
+	Token  string
+}
+
+// TokenStore remembers the game tokens of every browser or account, so a
+// player can be seated in several games at once and switch between them. The
+// `game` cookie only holds the token of the game which is currently open.
+type TokenStore struct {
+	mutex  sync.Mutex
+	tokens map[string][]StoredToken
+}
+
+var tokenStore = NewTokenStore()
+
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		tokens: make(map[string][]StoredToken),
+	}
+}
+
+// Add remembers the token under the key, replacing the previous token of
+// the same game. Only the newest BROWSER_GAMES_MAX games are kept.
+func (s *TokenStore) Add(key string, token string) error {
+	gameId, err := auth.ExtractNonceFromToken(token)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored := s.tokens[key]
+	for i := range stored {
+		if stored[i].GameId == gameId {
+			stored[i].Token = token
+			return nil
+		}
+	}
+
+	stored = append(stored, StoredToken{GameId: gameId, Token: token})
+	if len(stored) > BROWSER_GAMES_MAX {
+		stored = stored[len(stored)-BROWSER_GAMES_MAX:]
+	}
+	s.tokens[key] = stored
+
+	return nil
+}
+
+func (s *TokenStore) Get(key string, gameId string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, stored := range s.tokens[key] {
+		if stored.GameId == gameId {
+			return stored.Token, true
+		}
+	}
+
+	return "", false
+}
+
+// Tokens returns a copy of the tokens under the key, oldest first
+func (s *TokenStore) Tokens(key string) []StoredToken {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]StoredToken(nil), s.tokens[key]...)
+}
+
+func (s *TokenStore) Remove(key string, gameId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.removeLocked(key, gameId)
+}
+
+func (s *TokenStore) removeLocked(key string, gameId string) {
+	var newStored []StoredToken
+	for _, stored := range s.tokens[key] {
+		if stored.GameId != gameId {
+			newStored = append(newStored, stored)
+		}
+	}
+
+	if len(newStored) == 0 {
+		delete(s.tokens, key)
+	} else {
+		s.tokens[key] = newStored
+	}
+}
+
+// Merge moves the tokens of one key into another, keeping the newer token
+// when both have one for the same game
+func (s *TokenStore) Merge(from string, to string) {
+	s.mutex.Lock()
+	stored := s.tokens[from]
+	delete(s.tokens, from)
+	s.mutex.Unlock()
+
+	for _, token := range stored {
+		s.Add(to, token.Token)
+	}
+}
+
+// Prune forgets the tokens of games which no longer exist
+func (s *TokenStore) Prune() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, stored := range s.tokens {
+		for _, token := range stored {
+			if _, ok := games.Get(token.GameId); !ok {
+				s.removeLocked(key, token.GameId)
+			}
+		}
+	}
+}
+
+func (s *TokenStore) PruneEvery(interval time.Duration) {
+	for {
+		s.Prune()
+		time.Sleep(interval)
+	}
+}
+
+// ActiveGame is a game the player is seated in
+type ActiveGame struct {
+	GameId   string
+	Profile  auth.PlayerProfile
+	Stage    engine.Stage
+	Width    int
+	Height   int
+	YourTurn bool
+	Current  bool
+}
+
+// ActiveGames returns the games under the key the player is still a member of, forgetting
+// the tokens which are no longer valid (kicked, revoked or expired).
+func (s *TokenStore) ActiveGames(key string, currentToken string) []ActiveGame {
+	currentGameId, _ := auth.ExtractNonceFromToken(currentToken)
+
+	activeGames := []ActiveGame{}
+	for _, stored := range s.Tokens(key) {
+		activeGame, ok := activeGameForToken(stored)
+		if !ok {
+			s.Remove(key, stored.GameId)
+			continue
+		}
+
+		activeGame.Current = stored.GameId == currentGameId
+		activeGames = append(activeGames, activeGame)
+	}
+
+	return activeGames
+}
+
+func activeGameForToken(stored StoredToken) (ActiveGame, bool) {
+	webSession, ok := games.Get(stored.GameId)
+	if !ok {
+		return ActiveGame{}, false
+	}
+
+	webSession.SessionMutex.RLock()
+	defer webSession.SessionMutex.RUnlock()
+
+	session := webSession.Session
+	player, err := session.ExtractPlayerFromToken(stored.Token)
+	if err != nil || !webSession.IsMember(player) {
+		return ActiveGame{}, false
+	}
+
+	game := session.Game
+	stage := game.Stage()
+	yourTurn := (stage == engine.StageInit || stage == engine.StatePlaying) && webSession.controls(player, game.CurrentPlayer())
+
+	return ActiveGame{
+		GameId:   stored.GameId,
+		Profile:  session.Profile(player),
+		Stage:    stage,
+		Width:    len(game.Board[0]),
+		Height:   len(game.Board),
+		YourTurn: yourTurn,
+	}, true
+}
+
+// browserId returns the id of the requesting browser, issuing a new one if it
+// has none yet
+func browserId(c echo.Context) (string, error) {
+	if cookie, err := c.Cookie(BrowserCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	id, err := auth.GenerateNonce(NonceBitLength)
+	if err != nil {
+		return "", err
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     BrowserCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(BROWSER_COOKIE_MAX_AGE.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return id, nil
+}
+
+// setGameCookie makes the token's game the current one and remembers it for
+// the browser or account
+func setGameCookie(c echo.Context, token string) {
+	c.SetCookie(&http.Cookie{
+		Name:  SessionCookieName,
+		Value: token,
+		Path:  "/",
+	})
+
+	rememberGameToken(c, token)
+}
+
+// rememberGameToken adds the token to the player's games without making it
+// the current one
+func rememberGameToken(c echo.Context, token string) {
+	key, err := tokenStoreKey(c)
+	if err != nil {
+		return
+	}
+
+	tokenStore.Add(key, token)
+}
+
+func currentGameToken(c echo.Context) string {
+	cookie, err := c.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+func registerMyGamesRoutes(e *echo.Echo) {
+	e.GET("/games", func(c echo.Context) error {
+		key, err := tokenStoreKey(c)
+		if err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.Render(http.StatusOK, "myGames", tokenStore.ActiveGames(key, currentGameToken(c)))
+	})
+
+	e.POST("/switch", func(c echo.Context) error {
+		key, err := tokenStoreKey(c)
+		if err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		gameId := c.FormValue("gameId")
+		token, ok := tokenStore.Get(key, gameId)
+		if !ok {
+			return c.NoContent(http.StatusNotFound)
+		}
+
+		if _, ok := activeGameForToken(StoredToken{GameId: gameId, Token: token}); !ok {
+			tokenStore.Remove(key, gameId)
+			return c.NoContent(http.StatusNotFound)
+		}
+
+		c.SetCookie(&http.Cookie{
+			Name:  SessionCookieName,
+			Value: token,
+		})
+
+		c.Response().Header().Set("HX-Redirect", "/")
+		return c.NoContent(http.StatusOK)
+	})
+}
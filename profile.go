@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/archive"
+	"github.com/Denloob/cadere/stats"
+)
+
+const PROFILE_RECENT_GAMES = 10
+
+// PlayerProfileView is the data the profile page is rendered with, and the
+// JSON the API returns for a player
+type PlayerProfileView struct {
+	Username    string              `json:"username"`
+	Registered  time.Time           `json:"registered"`
+	Rating      float64             `json:"rating"`
+	RatedGames  int                 `json:"ratedGames"`
+	Stats       stats.Stats         `json:"stats"`
+	RecentGames []RecentGameSummary `json:"recentGames"`
+}
+
+// RecentGameSummary is a finished game as seen by one of its players
+type RecentGameSummary struct {
+	Id       string    `json:"id"`
+	Size     string    `json:"size"`
+	Players  int       `json:"players"`
+	Result   string    `json:"result"`
+	Rated    bool      `json:"rated"`
+	Finished time.Time `json:"finished"`
+}
+
+func summarizeGame(record archive.Record, username string) RecentGameSummary {
+	result := "loss"
+	for _, player := range record.Players {
+		if !strings.EqualFold(player.Username, username) {
+			continue
+		}
+
+		if record.Draw {
+			result = "draw"
+		} else if record.Winner == player.Player {
+			result = "win"
+		}
+	}
+
+	return RecentGameSummary{
+		Id:       record.Id,
+		Size:     fmt.Sprintf("%dx%d", record.Width, record.Height),
+		Players:  len(record.Players),
+		Result:   result,
+		Rated:    record.Rated,
+		Finished: record.Finished,
+	}
+}
+
+func newPlayerProfileView(username string) (PlayerProfileView, error) {
+	account, err := accountStore.Get(username)
+	if err != nil {
+		return PlayerProfileView{}, err
+	}
+
+	records := archiveStore.ByUsername(account.Username)
+	recentGames := []RecentGameSummary{}
+	for i := 0; i < len(records) && i < PROFILE_RECENT_GAMES; i++ {
+		recentGames = append(recentGames, summarizeGame(records[i], account.Username))
+	}
+
+	playerRating := ratingStore.Get(account.Username)
+	return PlayerProfileView{
+		Username:    account.Username,
+		Registered:  account.Created,
+		Rating:      playerRating.Rating,
+		RatedGames:  playerRating.Games,
+		Stats:       stats.Compute(account.Username, records),
+		RecentGames: recentGames,
+	}, nil
+}
+
+func registerProfileRoutes(e *echo.Echo) {
+	e.GET("/profile/:username", func(c echo.Context) error {
+		view, err := newPlayerProfileView(c.Param("username"))
+		if err != nil {
+			return c.Render(http.StatusNotFound, "errorPage", "Error: No such player")
+		}
+
+		return c.Render(http.StatusOK, "profile", view)
+	})
+
+	e.GET("/api/players/:username", func(c echo.Context) error {
+		view, err := newPlayerProfileView(c.Param("username"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "no such player"})
+		}
+
+		return c.JSON(http.StatusOK, view)
+	})
+}
+
+// AverageGameLength returns the average duration of the player's games, like 4:05
+func (v PlayerProfileView) AverageGameLength() string {
+	return formatClock(int64(v.Stats.AverageDurationSeconds))
+}
@@ -0,0 +1,312 @@
+// Package puzzle has "eliminate in N shifts" puzzles: positions of two players
+// where the player to move can push all the tiles of their opponent off the
+// board in N of their own moves, however the opponent defends. The solutions
+// are checked by the solver of the engine.
+package puzzle
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/rating"
+)
+
+const (
+	// MaxBestMoves is how many winning first moves a generated puzzle may
+	// have, so that it has something to find
+	MaxBestMoves = 2
+	// GenerateAttempts is how many random positions Generate tries
+	GenerateAttempts = 2000
+	// MovesRating is how much harder every move makes a puzzle
+	MovesRating = 200.0
+)
+
+var (
+	ErrorNotAPuzzle     = errors.New("the player to move has no forced win")
+	ErrorNotFound       = errors.New("no puzzle was found")
+	ErrorAttemptOver    = errors.New("the puzzle is already over")
+	ErrorTooManyPlayers = errors.New("puzzles are for two players")
+)
+
+type Puzzle struct {
+	Id string
+	// Position is the puzzle in the position notation of the engine
+	Position string
+	// Moves is how many moves the player has to eliminate their opponent
+	Moves int
+	// Line is the best line of play from the start, alternating the moves of
+	// the player and of their opponent
+	Line []engine.Move
+	// Rating is the difficulty of the puzzle, which changes as players solve
+	// or fail it
+	Rating   float64
+	Attempts int
+	Solved   int
+}
+
+// New creates the puzzle of the position, finding the number of moves with
+// the solver
+func New(position string) (Puzzle, error) {
+	game, err := engine.ParsePosition(position)
+	if err != nil {
+		return Puzzle{}, err
+	}
+	if game.PlayerCount() != 2 {
+		return Puzzle{}, ErrorTooManyPlayers
+	}
+	if game.Stage() != engine.StatePlaying {
+		return Puzzle{}, ErrorNotAPuzzle
+	}
+
+	solution, err := solve(game)
+	if err != nil {
+		return Puzzle{}, err
+	}
+	if solution.Winner != game.CurrentPlayer() {
+		return Puzzle{}, ErrorNotAPuzzle
+	}
+
+	line, err := bestLine(game)
+	if err != nil {
+		return Puzzle{}, err
+	}
+
+	// Normalize the position, so that every puzzle has a single ID
+	position, err = engine.FormatPosition(game)
+	if err != nil {
+		return Puzzle{}, err
+	}
+
+	moves := (solution.Plies + 1) / 2
+	return Puzzle{
+		Id:       id(position),
+		Position: position,
+		Moves:    moves,
+		Line:     line,
+		Rating:   rating.InitialRating + MovesRating*float64(moves-2),
+	}, nil
+}
+
+func id(position string) string {
+	hash := sha256.Sum256([]byte(position))
+	return base64.RawURLEncoding.EncodeToString(hash[:6])
+}
+
+// Game returns the starting position of the puzzle
+func (p Puzzle) Game() engine.Game {
+	game, err := engine.ParsePosition(p.Position)
+	if err != nil {
+		panic(fmt.Sprintf("invalid puzzle position %q: %v", p.Position, err))
+	}
+
+	return game
+}
+
+// Player returns the player who solves the puzzle
+func (p Puzzle) Player() engine.Player {
+	return p.Game().CurrentPlayer()
+}
+
+// Generate looks for a random puzzle on a board of the given size, where
+// each player has the given number of tiles, which is solved in exactly the
+// given number of moves
+func Generate(random *rand.Rand, width, height, tiles, moves int) (Puzzle, error) {
+	for attempt := 0; attempt < GenerateAttempts; attempt++ {
+		game := engine.NewGame(engine.NewBoard(width, height))
+		game.AddPlayers(1, 2)
+		game.ProgressStage()
+		game.ProgressStage()
+
+		cells := random.Perm(width * height)
+		if len(cells) < 2*tiles {
+			return Puzzle{}, ErrorNotFound
+		}
+		for i, cell := range cells[:2*tiles] {
+			game.Board[cell/width][cell%width] = engine.Player(1 + i%2).ToTile()
+		}
+
+		solution, err := engine.Solve(game)
+		if err != nil {
+			return Puzzle{}, err
+		}
+		if solution.Winner != 1 || solution.Plies != 2*moves-1 || len(solution.BestMoves()) > MaxBestMoves {
+			continue
+		}
+
+		position, err := engine.FormatPosition(game)
+		if err != nil {
+			return Puzzle{}, err
+		}
+
+		return New(position)
+	}
+
+	return Puzzle{}, ErrorNotFound
+}
+
+// ReadList reads puzzles written in the position notation, one on each line.
+// Empty lines and lines starting with '#' are skipped.
+func ReadList(reader io.Reader) ([]Puzzle, error) {
+	puzzles := []Puzzle{}
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		puzzle, err := New(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		puzzles = append(puzzles, puzzle)
+	}
+
+	return puzzles, scanner.Err()
+}
+
+type Status int
+
+const (
+	StatusPlaying Status = iota
+	StatusSolved
+	StatusFailed
+)
+
+// Attempt is the progress of a player in a puzzle. The opponent defends with
+// the move which holds out the longest.
+type Attempt struct {
+	Puzzle Puzzle
+	Game   engine.Game
+	Player engine.Player
+	// Moves are the moves of the player, and Replies the moves of their
+	// opponent
+	Moves   []engine.Move
+	Replies []engine.Move
+	Status  Status
+}
+
+func (p Puzzle) Start() *Attempt {
+	game := p.Game()
+
+	return &Attempt{
+		Puzzle: p,
+		Game:   game,
+		Player: game.CurrentPlayer(),
+	}
+}
+
+// Replay starts the puzzle and plays the moves. Use it to continue an
+// attempt from the moves played so far.
+func (p Puzzle) Replay(moves []engine.Move) (*Attempt, error) {
+	attempt := p.Start()
+	for _, move := range moves {
+		if err := attempt.Play(move); err != nil {
+			return nil, err
+		}
+	}
+
+	return attempt, nil
+}
+
+// MovesLeft returns how many moves the player has left to win
+func (a *Attempt) MovesLeft() int {
+	return a.Puzzle.Moves - len(a.Moves)
+}
+
+// Play plays the move of the player, and the reply of the opponent. A move
+// after which the player can't win in the moves they have left fails the
+// puzzle.
+func (a *Attempt) Play(move engine.Move) error {
+	if a.Status != StatusPlaying {
+		return ErrorAttemptOver
+	}
+
+	solution, err := solve(a.Game)
+	if err != nil {
+		return err
+	}
+	pliesLeft := 2*a.MovesLeft() - 1
+	wins := slices.ContainsFunc(solution.Moves, func(solved engine.SolvedMove) bool {
+		return solved.Move == move && solved.Winner == a.Player && solved.Plies <= pliesLeft
+	})
+
+	if err := a.Game.Play(a.Player, move); err != nil {
+		return err
+	}
+	a.Moves = append(a.Moves, move)
+
+	if a.finishIfOver() {
+		return nil
+	}
+	if !wins {
+		a.Status = StatusFailed
+		return nil
+	}
+
+	defence, err := solve(a.Game)
+	if err != nil {
+		return err
+	}
+	reply := defence.BestMoves()[0]
+	if err := a.Game.Play(a.Game.CurrentPlayer(), reply); err != nil {
+		return err
+	}
+	a.Replies = append(a.Replies, reply)
+	a.finishIfOver()
+
+	return nil
+}
+
+// finishIfOver sets the status once the game is over, and returns whether it
+// is
+func (a *Attempt) finishIfOver() bool {
+	if a.Game.Stage() != engine.StageOver {
+		return false
+	}
+
+	if winner, err := a.Game.Winner(); err == nil && winner == a.Player {
+		a.Status = StatusSolved
+	} else {
+		a.Status = StatusFailed
+	}
+	return true
+}
+
+// Solution returns the best line of play from the start of the puzzle,
+// alternating the moves of the player and of their opponent
+func (p Puzzle) Solution() ([]engine.Move, error) {
+	if p.Line != nil {
+		return slices.Clone(p.Line), nil
+	}
+
+	return bestLine(p.Game())
+}
+
+// bestLine plays the best moves of both players until the game is over
+func bestLine(game engine.Game) ([]engine.Move, error) {
+	game = game.Clone()
+	line := []engine.Move{}
+	for game.Stage() == engine.StatePlaying {
+		solution, err := solve(game)
+		if err != nil {
+			return nil, err
+		}
+
+		move := solution.BestMoves()[0]
+		if err := game.Play(game.CurrentPlayer(), move); err != nil {
+			return nil, err
+		}
+		line = append(line, move)
+	}
+
+	return line, nil
+}
@@ -0,0 +1,152 @@
+package puzzle
+
+import (
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/rating"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	puzzle, err := New("  12.  12 ")
+	assert.NoError(t, err)
+	assert.Equal(t, "12. 12", puzzle.Position)
+	assert.Equal(t, 1, puzzle.Moves)
+	assert.Equal(t, engine.Player(1), puzzle.Player())
+	assert.NotEmpty(t, puzzle.Id)
+	assert.Len(t, puzzle.Line, 1)
+
+	again, err := New("12. 12")
+	assert.NoError(t, err)
+	assert.Equal(t, puzzle.Id, again.Id)
+
+	_, err = New("1.. 12")
+	assert.ErrorIs(t, err, ErrorNotAPuzzle)
+	_, err = New("123 123")
+	assert.ErrorIs(t, err, ErrorTooManyPlayers)
+	_, err = New("12")
+	assert.ErrorIs(t, err, engine.ErrorInvalidPosition)
+}
+
+func TestReadList(t *testing.T) {
+	puzzles, err := ReadList(strings.NewReader("# eliminate in 1\n12. 12\n\n.21 21\n"))
+	assert.NoError(t, err)
+	assert.Len(t, puzzles, 2)
+
+	_, err = ReadList(strings.NewReader("12. 12\n1.. 12\n"))
+	assert.ErrorIs(t, err, ErrorNotAPuzzle)
+	assert.ErrorContains(t, err, "line 2")
+}
+
+// generate creates a 3x3 puzzle. With n tiles each, random positions are won
+// in n moves.
+func generate(t *testing.T, moves int) Puzzle {
+	puzzle, err := Generate(rand.New(rand.NewSource(1)), 3, 3, moves, moves)
+	assert.NoError(t, err)
+	assert.Equal(t, moves, puzzle.Moves)
+
+	return puzzle
+}
+
+func TestSolve(t *testing.T) {
+	puzzle := generate(t, 2)
+
+	solution, err := puzzle.Solution()
+	assert.NoError(t, err)
+	assert.Len(t, solution, 3)
+
+	moves := []engine.Move{solution[0], solution[2]}
+	attempt, err := puzzle.Replay(moves[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, StatusPlaying, attempt.Status)
+	assert.Equal(t, 1, attempt.MovesLeft())
+	assert.Equal(t, solution[1:2], attempt.Replies)
+
+	assert.NoError(t, attempt.Play(moves[1]))
+	assert.Equal(t, StatusSolved, attempt.Status)
+	assert.ErrorIs(t, attempt.Play(moves[1]), ErrorAttemptOver)
+}
+
+func TestFail(t *testing.T) {
+	puzzle := generate(t, 2)
+	best, err := engine.Solve(puzzle.Game())
+	assert.NoError(t, err)
+
+	for _, move := range best.Moves {
+		if move.Winner == puzzle.Player() && move.Plies == best.Plies {
+			continue
+		}
+
+		attempt := puzzle.Start()
+		assert.NoError(t, attempt.Play(move.Move))
+		assert.Equal(t, StatusFailed, attempt.Status, "move %s", engine.FormatMove(move.Move))
+	}
+}
+
+func TestStoreRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "puzzles.json")
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+
+	easy, _ := New("12. 12")
+	hard := generate(t, 3)
+	added, err := store.Add(easy, hard, easy)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	next, err := store.Next("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, hard.Id, next.Id)
+
+	delta, err := store.Record("Alice", hard.Id, true)
+	assert.NoError(t, err)
+	assert.Greater(t, delta, 0.0)
+	_, err = store.Record("alice", hard.Id, false)
+	assert.ErrorIs(t, err, ErrorAlreadyAttempted)
+	_, err = store.Record("alice", "missing", false)
+	assert.ErrorIs(t, err, ErrorNotFound)
+
+	next, err = store.Next("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, easy.Id, next.Id)
+
+	reloaded, err := NewStore(path)
+	assert.NoError(t, err)
+	player := reloaded.Player("alice")
+	assert.Equal(t, rating.InitialRating+delta, player.Rating)
+	assert.Equal(t, 1, player.Solved)
+	assert.True(t, reloaded.Attempted("ALICE", hard.Id))
+
+	puzzle, ok := reloaded.Get(hard.Id)
+	assert.True(t, ok)
+	assert.Less(t, puzzle.Rating, hard.Rating)
+	assert.Equal(t, 1, puzzle.Attempts)
+	assert.Equal(t, hard.Line, puzzle.Line)
+}
+
+func TestStoreDaily(t *testing.T) {
+	store, err := NewStore("")
+	assert.NoError(t, err)
+
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	_, err = store.Daily(day)
+	assert.ErrorIs(t, err, ErrorNotFound)
+
+	easy, _ := New("12. 12")
+	store.Add(easy, generate(t, 2))
+
+	first, err := store.Daily(day)
+	assert.NoError(t, err)
+	again, err := store.Daily(day.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, first.Id, again.Id)
+
+	second, err := store.Daily(day.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Id, second.Id)
+}
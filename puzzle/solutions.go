@@ -0,0 +1,50 @@
+package puzzle
+
+import (
+	"sync"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+// SolutionCacheSize is how many solved positions are kept. The attempts only
+// reach the positions of the winning lines of the puzzles, so the cache
+// rarely fills up.
+const SolutionCacheSize = 1 << 12
+
+// solutionCache keeps the solutions of the positions the attempts reach.
+// Attempts are replayed from the start on every move, and solving a position
+// takes up to seconds, so each position is solved only once.
+type solutionCache struct {
+	mutex     sync.Mutex
+	solutions map[string]engine.Solution
+}
+
+var solutions = solutionCache{solutions: make(map[string]engine.Solution)}
+
+// solve solves the position, or returns its solution from the cache
+func solve(game engine.Game) (engine.Solution, error) {
+	position, err := engine.FormatPosition(game)
+	if err != nil {
+		return engine.Solution{}, err
+	}
+
+	solutions.mutex.Lock()
+	solution, ok := solutions.solutions[position]
+	solutions.mutex.Unlock()
+	if ok {
+		return solution, nil
+	}
+
+	solution, err = engine.Solve(game)
+	if err != nil {
+		return engine.Solution{}, err
+	}
+
+	solutions.mutex.Lock()
+	if len(solutions.solutions) < SolutionCacheSize {
+		solutions.solutions[position] = solution
+	}
+	solutions.mutex.Unlock()
+
+	return solution, nil
+}
@@ -0,0 +1,341 @@
+package puzzle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Denloob/cadere/rating"
+	"github.com/Denloob/cadere/util"
+)
+
+// PuzzleKFactor is how much a single attempt moves the rating of a puzzle.
+// Puzzles are attempted by many players, so they move slower than players.
+const PuzzleKFactor = rating.KFactor / 2
+
+var ErrorAlreadyAttempted = errors.New("the puzzle was already attempted")
+
+// Player is the puzzle record of a player
+type Player struct {
+	Username string
+	Rating   float64
+	Attempts int
+	Solved   int
+}
+
+// Store keeps the puzzles, the puzzle ratings of the players and the daily
+// puzzles in a JSON file. An empty path keeps them in memory only.
+type Store struct {
+	mutex sync.Mutex
+	path  string
+	data  storeData
+}
+
+type storeData struct {
+	Puzzles map[string]*Puzzle
+	Players map[string]*Player
+	// Attempted are the puzzles every player attempted. Only the first
+	// attempt is rated.
+	Attempted map[string]map[string]bool
+	// Daily are the puzzles of the days, by their date
+	Daily map[string]string
+}
+
+func NewStore(path string) (*Store, error) {
+	store := &Store{path: path}
+	store.data.init()
+
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &store.data); err != nil {
+		return nil, fmt.Errorf("corrupted puzzles file %s: %w", path, err)
+	}
+	store.data.init()
+
+	// Puzzles stored before they had their line are solved once here, rather
+	// than on every attempt
+	for _, puzzle := range store.data.Puzzles {
+		if puzzle.Line != nil {
+			continue
+		}
+
+		line, err := puzzle.Solution()
+		if err != nil {
+			return nil, fmt.Errorf("puzzle %s: %w", puzzle.Id, err)
+		}
+		puzzle.Line = line
+	}
+
+	return store, nil
+}
+
+func (d *storeData) init() {
+	if d.Puzzles == nil {
+		d.Puzzles = make(map[string]*Puzzle)
+	}
+	if d.Players == nil {
+		d.Players = make(map[string]*Player)
+	}
+	if d.Attempted == nil {
+		d.Attempted = make(map[string]map[string]bool)
+	}
+	if d.Daily == nil {
+		d.Daily = make(map[string]string)
+	}
+}
+
+func key(username string) string {
+	return strings.ToLower(username)
+}
+
+// Add adds the puzzles which are not in the store yet, and returns how many
+// were added
+func (s *Store) Add(puzzles ...Puzzle) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	added := 0
+	for _, puzzle := range puzzles {
+		if _, ok := s.data.Puzzles[puzzle.Id]; ok {
+			continue
+		}
+
+		puzzle := puzzle
+		s.data.Puzzles[puzzle.Id] = &puzzle
+		added++
+	}
+
+	if added == 0 {
+		return 0, nil
+	}
+	return added, s.save()
+}
+
+func (s *Store) Get(id string) (Puzzle, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	puzzle, ok := s.data.Puzzles[id]
+	if !ok {
+		return Puzzle{}, false
+	}
+
+	return *puzzle, true
+}
+
+func (s *Store) Count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.data.Puzzles)
+}
+
+// sortedIds returns the IDs of the puzzles in a stable order. The caller
+// must hold the mutex.
+func (s *Store) sortedIds() []string {
+	ids := make([]string, 0, len(s.data.Puzzles))
+	for id := range s.data.Puzzles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Daily returns the puzzle of the day. It is chosen the first time it is
+// asked for, preferring puzzles which were not a daily puzzle yet, and stays
+// the same for the whole day.
+func (s *Store) Daily(day time.Time) (Puzzle, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	date := day.UTC().Format(time.DateOnly)
+	if id, ok := s.data.Daily[date]; ok {
+		if puzzle, ok := s.data.Puzzles[id]; ok {
+			return *puzzle, nil
+		}
+	}
+
+	used := make(map[string]bool, len(s.data.Daily))
+	for _, id := range s.data.Daily {
+		used[id] = true
+	}
+
+	candidates := []string{}
+	for _, id := range s.sortedIds() {
+		if !used[id] {
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = s.sortedIds()
+	}
+	if len(candidates) == 0 {
+		return Puzzle{}, ErrorNotFound
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(date))
+	id := candidates[int(hash.Sum32()%uint32(len(candidates)))]
+
+	s.data.Daily[date] = id
+	if err := s.save(); err != nil {
+		delete(s.data.Daily, date)
+		return Puzzle{}, err
+	}
+
+	return *s.data.Puzzles[id], nil
+}
+
+// Next returns the puzzle the player has not attempted yet whose rating is
+// the closest to theirs. Anonymous players, with an empty username, get the
+// puzzle closest to the initial rating.
+func (s *Store) Next(username string) (Puzzle, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	playerRating := rating.InitialRating
+	if player, ok := s.data.Players[key(username)]; ok {
+		playerRating = player.Rating
+	}
+	attempted := s.data.Attempted[key(username)]
+
+	var next *Puzzle
+	for _, id := range s.sortedIds() {
+		puzzle := s.data.Puzzles[id]
+		if attempted[id] {
+			continue
+		}
+		if next == nil || math.Abs(puzzle.Rating-playerRating) < math.Abs(next.Rating-playerRating) {
+			next = puzzle
+		}
+	}
+	if next == nil {
+		return Puzzle{}, ErrorNotFound
+	}
+
+	return *next, nil
+}
+
+// Random returns a random puzzle
+func (s *Store) Random(random *rand.Rand) (Puzzle, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ids := s.sortedIds()
+	if len(ids) == 0 {
+		return Puzzle{}, ErrorNotFound
+	}
+
+	return *s.data.Puzzles[ids[random.Intn(len(ids))]], nil
+}
+
+// Player returns the puzzle record of the player, with the initial rating
+// if they have not attempted any puzzle yet
+func (s *Store) Player(username string) Player {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if player, ok := s.data.Players[key(username)]; ok {
+		return *player
+	}
+
+	return Player{Username: username, Rating: rating.InitialRating}
+}
+
+// Attempted returns whether the player attempted the puzzle
+func (s *Store) Attempted(username, id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.data.Attempted[key(username)][id]
+}
+
+// Record rates the first attempt of the player at the puzzle as a game
+// between the two, and returns the change of the rating of the player
+func (s *Store) Record(username, id string, solved bool) (float64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	puzzle, ok := s.data.Puzzles[id]
+	if !ok {
+		return 0, ErrorNotFound
+	}
+	if s.data.Attempted[key(username)][id] {
+		return 0, ErrorAlreadyAttempted
+	}
+
+	player, ok := s.data.Players[key(username)]
+	if !ok {
+		player = &Player{Username: username, Rating: rating.InitialRating}
+		s.data.Players[key(username)] = player
+	}
+	before := *player
+	beforePuzzle := *puzzle
+
+	score := 0.0
+	if solved {
+		score = 1
+	}
+	expected := rating.Expected(player.Rating, puzzle.Rating)
+	kFactor := rating.KFactor
+	if player.Attempts < rating.ProvisionalGames {
+		kFactor = rating.ProvisionalKFactor
+	}
+
+	delta := kFactor * (score - expected)
+	player.Rating += delta
+	player.Attempts++
+	puzzle.Rating -= PuzzleKFactor * (score - expected)
+	puzzle.Attempts++
+	if solved {
+		player.Solved++
+		puzzle.Solved++
+	}
+
+	if s.data.Attempted[key(username)] == nil {
+		s.data.Attempted[key(username)] = make(map[string]bool)
+	}
+	s.data.Attempted[key(username)][id] = true
+
+	if err := s.save(); err != nil {
+		*player = before
+		*puzzle = beforePuzzle
+		delete(s.data.Attempted[key(username)], id)
+		return 0, err
+	}
+
+	return delta, nil
+}
+
+// save writes the store to the file. The caller must hold the mutex.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(s.path, data)
+}
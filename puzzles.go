@@ -0,0 +1,353 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/puzzle"
+)
+
+// PUZZLE_MOVES_MAX is the longest line of moves a puzzle page accepts, so
+// that a request can't replay an unbounded number of moves
+const PUZZLE_MOVES_MAX = 10
+
+var puzzleStore *puzzle.Store
+
+// defaultPuzzles are generated when there are no puzzles yet, as the board
+// size, the tiles of each player and how many puzzles. Random positions with
+// N tiles each are won in N moves.
+var defaultPuzzles = []struct {
+	size, tiles, count int
+}{
+	{3, 1, 5},
+	{3, 2, 15},
+	{4, 2, 15},
+	{3, 3, 10},
+	{4, 3, 5},
+}
+
+// loadPuzzles imports the curated puzzles of puzzles.txt in the data
+// directory, and generates the default puzzles in the background if there
+// are none at all
+func loadPuzzles(logger echo.Logger) error {
+	path := dataPath("puzzles.txt")
+	file, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		defer file.Close()
+
+		puzzles, err := puzzle.ReadList(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if _, err := puzzleStore.Add(puzzles...); err != nil {
+			return err
+		}
+	}
+
+	if puzzleStore.Count() > 0 {
+		return nil
+	}
+
+	go func() {
+		random := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for _, config := range defaultPuzzles {
+			for i := 0; i < config.count; i++ {
+				p, err := puzzle.Generate(random, config.size, config.size, config.tiles, config.tiles)
+				if err != nil {
+					logger.Error(err)
+					break
+				}
+				if _, err := puzzleStore.Add(p); err != nil {
+					logger.Error(err)
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// PuzzlesView is the data the puzzle overview is rendered with
+type PuzzlesView struct {
+	Username string
+	Player   puzzle.Player
+	Daily    *puzzle.Puzzle
+	Count    int
+}
+
+// PuzzleView is an attempt at a puzzle. It can be rendered with the
+// puzzleBoard template.
+type PuzzleView struct {
+	engine.Game
+	Attempt *puzzle.Attempt
+
+	// Username is the logged in player, "" for anonymous players
+	Username string
+	// Rated is whether the attempt changed the rating of the player, by
+	// RatingDelta
+	Rated       bool
+	RatingDelta float64
+	// Solution is the best line of play, shown once the attempt is over
+	Solution []string
+	Error    string
+}
+
+func newPuzzleView(attempt *puzzle.Attempt, username string) PuzzleView {
+	return PuzzleView{
+		Game:     attempt.Game,
+		Attempt:  attempt,
+		Username: username,
+	}
+}
+
+func (v PuzzleView) Puzzle() puzzle.Puzzle {
+	return v.Attempt.Puzzle
+}
+
+func (v PuzzleView) Solved() bool {
+	return v.Attempt.Status == puzzle.StatusSolved
+}
+
+func (v PuzzleView) Failed() bool {
+	return v.Attempt.Status == puzzle.StatusFailed
+}
+
+func (v PuzzleView) Playing() bool {
+	return v.Attempt.Status == puzzle.StatusPlaying
+}
+
+func (v PuzzleView) TileProfile(tile engine.Tile) auth.PlayerProfile {
+	player, err := tile.ToPlayer()
+	if err != nil {
+		return auth.PlayerProfile{}
+	}
+
+	profile := auth.DefaultProfile(player)
+	if player == v.Attempt.Player {
+		profile.Name = "You"
+	} else {
+		profile.Name = "Opponent"
+	}
+
+	return profile
+}
+
+// Moves returns the moves the player played, in the move notation
+func (v PuzzleView) Moves() string {
+	return formatMoves(v.Attempt.Moves)
+}
+
+// Line returns the moves played so far by both sides, in the move notation
+func (v PuzzleView) Line() []string {
+	line := []string{}
+	for i, move := range v.Attempt.Moves {
+		line = append(line, engine.FormatMove(move))
+		if i < len(v.Attempt.Replies) {
+			line = append(line, engine.FormatMove(v.Attempt.Replies[i]))
+		}
+	}
+
+	return line
+}
+
+func formatMoves(moves []engine.Move) string {
+	notations := make([]string, len(moves))
+	for i, move := range moves {
+		notations[i] = engine.FormatMove(move)
+	}
+
+	return strings.Join(notations, ",")
+}
+
+func parseMoves(notation string) ([]engine.Move, error) {
+	moves := []engine.Move{}
+	if notation == "" {
+		return moves, nil
+	}
+
+	for _, field := range strings.Split(notation, ",") {
+		move, err := engine.ParseMove(field)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, move)
+	}
+	if len(moves) > PUZZLE_MOVES_MAX {
+		return nil, engine.ErrorInvalidMove
+	}
+
+	return moves, nil
+}
+
+// parseShift parses the shift of a puzzle board arrow
+func parseShift(formValue func(string) string) (engine.Move, error) {
+	index, err := strconv.Atoi(formValue("index"))
+	if err != nil {
+		return engine.Move{}, engine.ErrorInvalidMove
+	}
+	direction, err := engine.ParseDirection(formValue("direction"))
+	if err != nil {
+		return engine.Move{}, engine.ErrorInvalidMove
+	}
+
+	return engine.ShiftMove(direction, index), nil
+}
+
+// finishPuzzle rates the first attempt of a logged in player once it is
+// over, and shows the solution
+func finishPuzzle(view *PuzzleView) error {
+	if view.Playing() {
+		return nil
+	}
+
+	solution, err := view.Puzzle().Solution()
+	if err != nil {
+		return err
+	}
+	for _, move := range solution {
+		view.Solution = append(view.Solution, engine.FormatMove(move))
+	}
+
+	if view.Username == "" {
+		return nil
+	}
+
+	delta, err := puzzleStore.Record(view.Username, view.Puzzle().Id, view.Solved())
+	if errors.Is(err, puzzle.ErrorAlreadyAttempted) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	view.Rated = true
+	view.RatingDelta = delta
+
+	return nil
+}
+
+func registerPuzzleRoutes(e *echo.Echo) {
+	e.GET("/puzzles", func(c echo.Context) error {
+		username := currentAccount(c)
+		view := PuzzlesView{
+			Username: username,
+			Player:   puzzleStore.Player(username),
+			Count:    puzzleStore.Count(),
+		}
+		if daily, err := puzzleStore.Daily(time.Now()); err == nil {
+			view.Daily = &daily
+		}
+
+		return c.Render(http.StatusOK, "puzzles", view)
+	})
+
+	e.GET("/puzzle/daily", func(c echo.Context) error {
+		daily, err := puzzleStore.Daily(time.Now())
+		if err != nil {
+			return c.Render(http.StatusNotFound, "errorPage", "Error: There are no puzzles yet")
+		}
+
+		return c.Redirect(http.StatusFound, "/puzzle/"+daily.Id)
+	})
+
+	e.GET("/puzzle/next", func(c echo.Context) error {
+		username := currentAccount(c)
+
+		var next puzzle.Puzzle
+		var err error
+		if username == "" {
+			next, err = puzzleStore.Random(rand.New(rand.NewSource(time.Now().UnixNano())))
+		} else {
+			next, err = puzzleStore.Next(username)
+		}
+		if err != nil {
+			return c.Render(http.StatusNotFound, "errorPage", "Error: There are no more puzzles")
+		}
+
+		return c.Redirect(http.StatusFound, "/puzzle/"+next.Id)
+	})
+
+	e.GET("/puzzle/:id", func(c echo.Context) error {
+		p, ok := puzzleStore.Get(c.Param("id"))
+		if !ok {
+			return c.Render(http.StatusNotFound, "errorPage", "Error: No such puzzle")
+		}
+
+		return c.Render(http.StatusOK, "puzzle", newPuzzleView(p.Start(), currentAccount(c)))
+	})
+
+	// The attempt isn't kept on the server. Every move carries the moves
+	// played before it, and the attempt is replayed from them.
+	e.POST("/puzzle/:id/move", func(c echo.Context) error {
+		p, ok := puzzleStore.Get(c.Param("id"))
+		if !ok {
+			return c.NoContent(http.StatusNotFound)
+		}
+
+		moves, err := parseMoves(c.FormValue("moves"))
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		attempt, err := p.Replay(moves)
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		view := newPuzzleView(attempt, currentAccount(c))
+		move, err := parseShift(c.FormValue)
+		if err == nil {
+			err = attempt.Play(move)
+		}
+		if err != nil {
+			view.Error = "Invalid move"
+			return c.Render(http.StatusOK, "puzzleState", view)
+		}
+
+		view.Game = attempt.Game
+		if err := finishPuzzle(&view); err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.Render(http.StatusOK, "puzzleState", view)
+	})
+
+	// Giving up shows the solution, and counts as a failed attempt
+	e.POST("/puzzle/:id/solution", func(c echo.Context) error {
+		p, ok := puzzleStore.Get(c.Param("id"))
+		if !ok {
+			return c.NoContent(http.StatusNotFound)
+		}
+
+		moves, err := parseMoves(c.FormValue("moves"))
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		attempt, err := p.Replay(moves)
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		if attempt.Status == puzzle.StatusPlaying {
+			attempt.Status = puzzle.StatusFailed
+		}
+
+		view := newPuzzleView(attempt, currentAccount(c))
+		if err := finishPuzzle(&view); err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.Render(http.StatusOK, "puzzleState", view)
+	})
+}
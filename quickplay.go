@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/bot"
+	"github.com/Denloob/cadere/clock"
+	"github.com/Denloob/cadere/engine"
+)
+
+const (
+	QUICKPLAY_BOT_OFFER_DELAY = 30 * time.Second
+	QUICKPLAY_PLAYERS_MIN     = 2
+)
+
+var QuickPlayTimeControl = clock.TimeControl{
+	Mode:      clock.ModePerMove,
+	Limit:     time.Minute,
+	OnTimeout: clock.TimeoutAutoPlay,
+}
+
+// MatchPreference is the kind of game a player is waiting for
+type MatchPreference struct {
+	Size        int
+	PlayerCount int
+}
+
+type matchWaiter struct {
+	conn       *websocket.Conn
+	preference MatchPreference
+	profile    auth.PlayerProfile
+	// account is the username of the waiter, if they are logged in
+	account    string
+	since      time.Time
+	botOffered bool
+}
+
+// QuickPlayMessage is sent to the waiting players as JSON
+type QuickPlayMessage struct {
+	// One of: botOffer, match
+	Type  string `json:"type"`
+	Token string `json:"token,omitempty"`
+}
+
+// Matchmaker groups waiting players by their preference, and starts a game
+// as soon as enough of them are waiting. All the writes to the waiting
+// sockets happen while holding the mutex.
+type Matchmaker struct {
+	mutex  sync.Mutex
+	queues map[MatchPreference][]*matchWaiter
+}
+
+var matchmaker = NewMatchmaker()
+
+func NewMatchmaker() *Matchmaker {
+	return &Matchmaker{
+		queues: make(map[MatchPreference][]*matchWaiter),
+	}
+}
+
+func (m *Matchmaker) enqueue(waiter *matchWaiter) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	preference := waiter.preference
+	m.queues[preference] = append(m.queues[preference], waiter)
+
+	queue := m.queues[preference]
+	if len(queue) >= preference.PlayerCount {
+		m.queues[preference] = queue[preference.PlayerCount:]
+		m.startGame(preference, queue[:preference.PlayerCount], 0)
+	}
+}
+
+func (m *Matchmaker) remove(waiter *matchWaiter) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.removeLocked(waiter)
+}
+
+func (m *Matchmaker) removeLocked(waiter *matchWaiter) bool {
+	queue := m.queues[waiter.preference]
+	for i, currWaiter := range queue {
+		if currWaiter == waiter {
+			m.queues[waiter.preference] = append(queue[:i:i], queue[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// fillWithBots starts a game with everyone waiting for the same kind of game
+// as the waiter, and bots in the remaining seats.
+func (m *Matchmaker) fillWithBots(waiter *matchWaiter) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.removeLocked(waiter) {
+		return
+	}
+
+	preference := waiter.preference
+	queue := m.queues[preference]
+	waiters := append([]*matchWaiter{waiter}, queue...)
+	delete(m.queues, preference)
+
+	m.startGame(preference, waiters, preference.PlayerCount-len(waiters))
+}
+
+// botProfile is the profile of a bot which fills a seat in quick play. The
+// caller must hold the SessionMutex.
+func botProfile(webSession *WebGameSession, player engine.Player) auth.PlayerProfile {
+	profile := webSession.freeDefaultProfile(player)
+	profile.Name = fmt.Sprintf("Bot %d", player)
+	return profile
+}
+
+// startGame creates and starts a game for the waiters, sending each of them
+// their token. The waiters get the first player IDs, so the host is a human.
+func (m *Matchmaker) startGame(preference MatchPreference, waiters []*matchWaiter, botCount int) {
+	nonce, err := auth.GenerateNonce(NonceBitLength)
+	if err != nil {
+		return
+	}
+
+	game := engine.NewGame(engine.NewBoard(preference.Size, preference.Size))
+	for i := 0; i < len(waiters)+botCount; i++ {
+		game.AddPlayers(engine.Player(i + 1))
+	}
+
+	session := auth.NewGameSession(&game, nonce)
+	webSession := games.AddSession(session, QuickPlayTimeControl)
+
+	webSession.SessionMutex.Lock()
+	usedColors := make(map[string]bool)
+	for i, waiter := range waiters {
+		profile := waiter.profile
+		if profile.Color == "" || usedColors[profile.Color] {
+			profile.Color = webSession.freeDefaultProfile(engine.Player(i + 1)).Color
+		}
+		profile = withDefaultProfile(profile, engine.Player(i+1))
+		usedColors[profile.Color] = true
+
+		session.SetProfile(engine.Player(i+1), profile)
+		if waiter.account != "" {
+			session.SetAccount(engine.Player(i+1), waiter.account)
+		}
+	}
+	for i := len(waiters); i < len(waiters)+botCount; i++ {
+		player := engine.Player(i + 1)
+		webSession.Bots[player] = bot.Random{}
+		session.SetProfile(player, botProfile(webSession, player))
+	}
+	webSession.StartingOrder = game.Players()
+	webSession.StartedAt = time.Now()
+	game.ProgressStage()
+	webSession.playBotTurns()
+	webSession.updateClock(time.Now())
+	webSession.SessionMutex.Unlock()
+
+	for i, waiter := range waiters {
+		webSession.SessionMutex.RLock()
+		token, err := webSession.NewTokenForPlayer(engine.Player(i + 1))
+		webSession.SessionMutex.RUnlock()
+		if err != nil {
+			continue
+		}
+
+		waiter.conn.WriteJSON(QuickPlayMessage{Type: "match", Token: token})
+	}
+}
+
+// OfferBots offers a game against bots to everyone who waited for too long
+func (m *Matchmaker) OfferBots() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, queue := range m.queues {
+		for _, waiter := range queue {
+			if !waiter.botOffered && time.Since(waiter.since) >= QUICKPLAY_BOT_OFFER_DELAY {
+				waiter.conn.WriteJSON(QuickPlayMessage{Type: "botOffer"})
+				waiter.botOffered = true
+			}
+		}
+	}
+}
+
+func (m *Matchmaker) OfferBotsEvery(interval time.Duration) {
+	for {
+		m.OfferBots()
+		time.Sleep(interval)
+	}
+}
+
+func parseMatchPreference(c echo.Context) (MatchPreference, bool) {
+	size, err := strconv.Atoi(c.QueryParam("size"))
+	if err != nil || size < GAME_SIZE_MIN || size > GAME_SIZE_MAX {
+		return MatchPreference{}, false
+	}
+
+	playerCount, err := strconv.Atoi(c.QueryParam("players"))
+	maxPlayerCount := engine.NewBoard(size, size).MaxPlayerCount(engine.MinTilesPerPlayer)
+	if err != nil || playerCount < QUICKPLAY_PLAYERS_MIN || playerCount > maxPlayerCount {
+		return MatchPreference{}, false
+	}
+
+	return MatchPreference{Size: size, PlayerCount: playerCount}, true
+}
+
+func registerQuickPlayRoutes(e *echo.Echo) {
+	e.GET("/quickplay", func(c echo.Context) error {
+		preference, ok := parseMatchPreference(c)
+		if !ok {
+			return c.Render(http.StatusUnprocessableEntity, "errorPage", "Error: Invalid board size or player count")
+		}
+
+		return c.Render(http.StatusOK, "quickplay", preference)
+	})
+
+	e.GET("/quickplay/ws", func(c echo.Context) error {
+		preference, ok := parseMatchPreference(c)
+		if !ok {
+			return c.NoContent(http.StatusUnprocessableEntity)
+		}
+
+		// The player ID is not known until the game starts, so the defaults
+		// are filled in later
+		profile, err := parseProfileFields(c.FormValue)
+		if err != nil {
+			return c.NoContent(http.StatusUnprocessableEntity)
+		}
+		account := currentAccount(c)
+		if profile.Name == "" {
+			profile.Name = account
+		}
+
+		ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+
+		waiter := &matchWaiter{
+			conn:       ws,
+			preference: preference,
+			profile:    profile,
+			account:    account,
+			since:      time.Now(),
+		}
+		matchmaker.enqueue(waiter)
+		defer matchmaker.remove(waiter)
+
+		for {
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return nil
+			}
+
+			if string(message) == "bot" {
+				matchmaker.fillWithBots(waiter)
+			}
+		}
+	})
+}
@@ -0,0 +1,316 @@
+package rating
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Denloob/cadere/util"
+)
+
+const (
+	InitialRating = 1500.0
+
+	// KFactor is how much a single game can move a rating. New players move
+	// faster until they have played ProvisionalGames games.
+	KFactor            = 24.0
+	ProvisionalKFactor = 40.0
+	ProvisionalGames   = 20
+
+	// HistorySize is how many rating changes are kept per player
+	HistorySize = 200
+)
+
+var (
+	ErrorAlreadyRecorded = errors.New("game already recorded")
+	ErrorTooFewPlayers   = errors.New("a rated game needs at least two players")
+	ErrorDuplicatePlayer = errors.New("a player appears twice in the results")
+)
+
+// Result is the placement of a player in a game. Rank 1 is the winner, and
+// players who tie share a rank.
+type Result struct {
+	Username string
+	Rank     int
+}
+
+// Change is an entry in the rating history of a player
+type Change struct {
+	GameId string
+	Time   time.Time
+	Rating float64
+	Delta  float64
+	Rank   int
+	// Players is how many players were in the game
+	Players int
+}
+
+type Player struct {
+	Username string
+	Rating   float64
+	Games    int
+	Wins     int
+	Draws    int
+	History  []Change
+}
+
+// Expected returns the expected score of a player rated a against a player
+// rated b, between 0 and 1
+func Expected(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// score returns the score of a placement against another, 1 for placing
+// higher, 0.5 for a tie and 0 for placing lower
+func score(rank, otherRank int) float64 {
+	switch {
+	case rank < otherRank:
+		return 1
+	case rank == otherRank:
+		return 0.5
+	}
+
+	return 0
+}
+
+func kFactor(games int) float64 {
+	if games < ProvisionalGames {
+		return ProvisionalKFactor
+	}
+
+	return KFactor
+}
+
+// Deltas calculates the rating changes of a free-for-all game. Every pair of
+// players is treated as a game between the two, and the changes are scaled by
+// the number of opponents, so that a multiplayer game weighs as much as a
+// 2-player one. ratings and games hold the current ratings and game counts of
+// the players in the results.
+func Deltas(results []Result, ratings map[string]float64, games map[string]int) map[string]float64 {
+	deltas := make(map[string]float64, len(results))
+	if len(results) < 2 {
+		return deltas
+	}
+
+	opponents := float64(len(results) - 1)
+	for _, result := range results {
+		rating := ratings[result.Username]
+
+		var total float64
+		for _, other := range results {
+			if other.Username == result.Username {
+				continue
+			}
+
+			total += score(result.Rank, other.Rank) - Expected(rating, ratings[other.Username])
+		}
+
+		deltas[result.Username] = kFactor(games[result.Username]) * total / opponents
+	}
+
+	return deltas
+}
+
+// Store keeps the ratings in a JSON file. An empty path keeps them in memory
+// only.
+type Store struct {
+	mutex sync.Mutex
+	path  string
+	data  storeData
+}
+
+type storeData struct {
+	Players  map[string]*Player
+	Recorded map[string]bool
+}
+
+func NewStore(path string) (*Store, error) {
+	store := &Store{
+		path: path,
+		data: storeData{
+			Players:  make(map[string]*Player),
+			Recorded: make(map[string]bool),
+		},
+	}
+
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &store.data); err != nil {
+		return nil, fmt.Errorf("corrupted ratings file %s: %w", path, err)
+	}
+	if store.data.Players == nil {
+		store.data.Players = make(map[string]*Player)
+	}
+	if store.data.Recorded == nil {
+		store.data.Recorded = make(map[string]bool)
+	}
+
+	return store, nil
+}
+
+func key(username string) string {
+	return strings.ToLower(username)
+}
+
+// playerLocked returns the player, creating them with the initial rating if
+// they have not played yet. The caller must hold the mutex.
+func (s *Store) playerLocked(username string) *Player {
+	player, ok := s.data.Players[key(username)]
+	if !ok {
+		player = &Player{Username: username, Rating: InitialRating}
+		s.data.Players[key(username)] = player
+	}
+
+	return player
+}
+
+// Record updates the ratings with the results of a game, and returns the
+// rating changes. Every game is recorded only once.
+func (s *Store) Record(gameId string, results []Result, now time.Time) (map[string]float64, error) {
+	if len(results) < 2 {
+		return nil, ErrorTooFewPlayers
+	}
+
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if seen[key(result.Username)] {
+			return nil, ErrorDuplicatePlayer
+		}
+		seen[key(result.Username)] = true
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data.Recorded[gameId] {
+		return nil, ErrorAlreadyRecorded
+	}
+
+	ratings := make(map[string]float64)
+	games := make(map[string]int)
+	for _, result := range results {
+		player := s.playerLocked(result.Username)
+		ratings[result.Username] = player.Rating
+		games[result.Username] = player.Games
+	}
+
+	// A game is won by a single player. Players who share the best rank drew,
+	// even when others placed below them.
+	bestRank := results[0].Rank
+	for _, result := range results {
+		bestRank = min(bestRank, result.Rank)
+	}
+	bestCount := 0
+	for _, result := range results {
+		if result.Rank == bestRank {
+			bestCount++
+		}
+	}
+
+	deltas := Deltas(results, ratings, games)
+	for _, result := range results {
+		player := s.playerLocked(result.Username)
+		delta := deltas[result.Username]
+
+		player.Rating += delta
+		player.Games++
+		switch {
+		case result.Rank == bestRank && bestCount == 1:
+			player.Wins++
+		case result.Rank == bestRank:
+			player.Draws++
+		}
+
+		player.History = append(player.History, Change{
+			GameId:  gameId,
+			Time:    now,
+			Rating:  player.Rating,
+			Delta:   delta,
+			Rank:    result.Rank,
+			Players: len(results),
+		})
+		if len(player.History) > HistorySize {
+			player.History = player.History[len(player.History)-HistorySize:]
+		}
+	}
+	s.data.Recorded[gameId] = true
+
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+
+	return deltas, nil
+}
+
+// Get returns a copy of the player. Players who have not played a rated game
+// yet have the initial rating.
+func (s *Store) Get(username string) Player {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	player, ok := s.data.Players[key(username)]
+	if !ok {
+		return Player{Username: username, Rating: InitialRating}
+	}
+
+	copied := *player
+	copied.History = append([]Change(nil), player.History...)
+	return copied
+}
+
+// Leaderboard returns up to limit players with the highest ratings, without
+// their history
+func (s *Store) Leaderboard(limit int) []Player {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	players := make([]Player, 0, len(s.data.Players))
+	for _, player := range s.data.Players {
+		copied := *player
+		copied.History = nil
+		players = append(players, copied)
+	}
+
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Rating != players[j].Rating {
+			return players[i].Rating > players[j].Rating
+		}
+		return key(players[i].Username) < key(players[j].Username)
+	})
+
+	if len(players) > limit {
+		players = players[:limit]
+	}
+
+	return players
+}
+
+// save writes the ratings to the file. The caller must hold the mutex.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(s.path, data)
+}
@@ -0,0 +1,105 @@
+package rating
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpected(t *testing.T) {
+	assert.InDelta(t, 0.5, Expected(1500, 1500), 1e-9)
+	assert.InDelta(t, 0.76, Expected(1700, 1500), 0.01)
+	assert.InDelta(t, 1, Expected(1700, 1500)+Expected(1500, 1700), 1e-9)
+}
+
+func TestDeltasTwoPlayers(t *testing.T) {
+	results := []Result{{"alice", 1}, {"bob", 2}}
+	ratings := map[string]float64{"alice": 1500, "bob": 1500}
+	games := map[string]int{"alice": ProvisionalGames, "bob": ProvisionalGames}
+
+	deltas := Deltas(results, ratings, games)
+	assert.InDelta(t, KFactor/2, deltas["alice"], 1e-9)
+	assert.InDelta(t, -KFactor/2, deltas["bob"], 1e-9)
+}
+
+func TestDeltasDraw(t *testing.T) {
+	results := []Result{{"alice", 1}, {"bob", 1}}
+	ratings := map[string]float64{"alice": 1600, "bob": 1400}
+	games := map[string]int{"alice": ProvisionalGames, "bob": ProvisionalGames}
+
+	deltas := Deltas(results, ratings, games)
+	assert.Less(t, deltas["alice"], 0.0)
+	assert.Greater(t, deltas["bob"], 0.0)
+	assert.InDelta(t, 0, deltas["alice"]+deltas["bob"], 1e-9)
+}
+
+func TestDeltasFreeForAll(t *testing.T) {
+	results := []Result{{"alice", 1}, {"bob", 2}, {"carol", 2}, {"dave", 2}}
+	ratings := map[string]float64{"alice": 1500, "bob": 1500, "carol": 1500, "dave": 1500}
+
+	deltas := Deltas(results, ratings, map[string]int{})
+	assert.InDelta(t, ProvisionalKFactor/2, deltas["alice"], 1e-9)
+	assert.InDelta(t, deltas["bob"], deltas["carol"], 1e-9)
+	assert.Less(t, deltas["bob"], 0.0)
+
+	var total float64
+	for _, delta := range deltas {
+		total += delta
+	}
+	assert.InDelta(t, 0, total, 1e-9)
+}
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratings.json")
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	_, err = store.Record("game", []Result{{"Alice", 1}, {"Bob", 2}}, now)
+	assert.NoError(t, err)
+
+	_, err = store.Record("game", []Result{{"Alice", 1}, {"Bob", 2}}, now)
+	assert.ErrorIs(t, err, ErrorAlreadyRecorded)
+
+	_, err = store.Record("solo", []Result{{"Alice", 1}}, now)
+	assert.ErrorIs(t, err, ErrorTooFewPlayers)
+
+	_, err = store.Record("twice", []Result{{"Alice", 1}, {"alice", 2}}, now)
+	assert.ErrorIs(t, err, ErrorDuplicatePlayer)
+
+	store, err = NewStore(path)
+	assert.NoError(t, err)
+
+	alice := store.Get("alice")
+	assert.Greater(t, alice.Rating, InitialRating)
+	assert.Equal(t, 1, alice.Games)
+	assert.Equal(t, 1, alice.Wins)
+	assert.Len(t, alice.History, 1)
+
+	leaderboard := store.Leaderboard(10)
+	assert.Len(t, leaderboard, 2)
+	assert.Equal(t, "Alice", leaderboard[0].Username)
+	assert.Equal(t, "Bob", leaderboard[1].Username)
+
+	assert.Equal(t, InitialRating, store.Get("carol").Rating)
+}
+
+func TestRecordSharedBestRank(t *testing.T) {
+	store, err := NewStore("")
+	assert.NoError(t, err)
+
+	_, err = store.Record("game", []Result{{"Alice", 1}, {"Bob", 1}, {"Carol", 2}}, time.Now())
+	assert.NoError(t, err)
+
+	for _, username := range []string{"Alice", "Bob"} {
+		player := store.Get(username)
+		assert.Equal(t, 0, player.Wins)
+		assert.Equal(t, 1, player.Draws)
+	}
+
+	carol := store.Get("Carol")
+	assert.Equal(t, 0, carol.Wins)
+	assert.Equal(t, 0, carol.Draws)
+}
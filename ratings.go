@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/rating"
+)
+
+const LEADERBOARD_SIZE = 100
+
+var ratingStore *rating.Store
+
+// recordRating records the result of a rated game once it is over. Returns
+// whether the ratings were updated. The caller must hold the SessionMutex.
+func (webSession *WebGameSession) recordRating() bool {
+	game := webSession.Session.Game
+	if !webSession.Rated || webSession.RatingDeltas != nil || game.Stage() != engine.StageOver {
+		return false
+	}
+
+	ranks := gameRanks(game)
+
+	var results []rating.Result
+	for _, player := range webSession.StartingOrder {
+		username := webSession.Session.Account(player)
+		if username == "" {
+			continue
+		}
+
+		results = append(results, rating.Result{Username: username, Rank: ranks[player]})
+	}
+
+	deltas, err := ratingStore.Record(webSession.Session.Nonce(), results, time.Now())
+	if err != nil {
+		return false
+	}
+
+	webSession.RatingDeltas = make(map[engine.Player]float64)
+	for _, player := range webSession.StartingOrder {
+		if delta, ok := deltas[webSession.Session.Account(player)]; ok {
+			webSession.RatingDeltas[player] = delta
+		}
+	}
+
+	return true
+}
+
+// gameRanks ranks the players of a finished game by their standings, see
+// engine.Game.Standings. Players who tie share a rank.
+func gameRanks(game *engine.Game) map[engine.Player]int {
+	ranks := make(map[engine.Player]int)
+	for i, group := range game.Standings() {
+		for _, player := range group {
+			ranks[player] = i + 1
+		}
+	}
+
+	return ranks
+}
+
+// requireAccountFor checks that the username may take a seat in the game.
+// Rated games are only for logged in players, each with a single seat. The
+// caller must hold the SessionMutex.
+func (webSession *WebGameSession) requireAccountFor(username string) error {
+	if !webSession.Rated {
+		return nil
+	}
+
+	if username == "" {
+		return GameErrorf("This game is rated. Log in to join it")
+	}
+
+	session := webSession.Session
+	if slices.ContainsFunc(session.Game.Players(), func(player engine.Player) bool {
+		return session.Account(player) == username
+	}) {
+		return GameErrorf("You are already playing in this game")
+	}
+
+	return nil
+}
+
+// Rating returns the rating of the player, rounded, or 0 if the player is not
+// logged in
+func (v GameView) Rating(player engine.Player) int {
+	username := v.session.Account(player)
+	if username == "" {
+		return 0
+	}
+
+	return int(math.Round(ratingStore.Get(username).Rating))
+}
+
+// RatingDelta returns the rating change of the player in a finished rated
+// game, like "+12", or "" if the ratings were not updated
+func (v GameView) RatingDelta(player engine.Player) string {
+	delta, ok := v.RatingDeltas[player]
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("%+.0f", delta)
+}
+
+func registerRatingRoutes(e *echo.Echo) {
+	e.GET("/leaderboard", func(c echo.Context) error {
+		return c.Render(http.StatusOK, "leaderboard", ratingStore.Leaderboard(LEADERBOARD_SIZE))
+	})
+
+	e.GET("/rating/:username", func(c echo.Context) error {
+		username := c.Param("username")
+		if _, err := accountStore.Get(username); err != nil {
+			return c.Render(http.StatusNotFound, "errorPage", "Error: No such player")
+		}
+
+		player := ratingStore.Get(username)
+		slices.Reverse(player.History)
+
+		return c.Render(http.StatusOK, "ratingHistory", player)
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+func TestGameRanksByElimination(t *testing.T) {
+	game := engine.NewGame(engine.NewBoard(4, 4))
+	game.AddPlayers(1, 2, 3)
+	game.ProgressStage()
+
+	assert.NoError(t, game.EliminatePlayer(2))
+	assert.NoError(t, game.EliminatePlayer(1))
+	assert.Equal(t, engine.StageOver, game.Stage())
+
+	assert.Equal(t, map[engine.Player]int{3: 1, 1: 2, 2: 3}, gameRanks(&game))
+}
+
+func TestGameRanksByShifts(t *testing.T) {
+	game := engine.NewGame(engine.NewBoard(6, 1))
+	game.AddPlayers(1, 2, 3)
+	game.ProgressStage()
+
+	for _, col := range []int{4, 2, 0, 5, 3, 1} {
+		assert.NoError(t, game.Play(game.CurrentPlayer(), engine.PutMove(0, col)))
+	}
+	assert.Equal(t, engine.StatePlaying, game.Stage())
+
+	// 3 3 2 2 1 1: the shifts to the left push player 3 off, then player 2
+	for _, player := range []engine.Player{1, 2, 3, 1} {
+		assert.NoError(t, game.Play(player, engine.ShiftMove(engine.DirectionLeft, 0)))
+	}
+	assert.Equal(t, engine.StageOver, game.Stage())
+	assert.Equal(t, []engine.Player{1, 2, 3}, game.Players())
+
+	assert.Equal(t, map[engine.Player]int{1: 1, 2: 2, 3: 3}, gameRanks(&game))
+}
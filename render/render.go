@@ -0,0 +1,243 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"strconv"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+const (
+	// CellSize is the size of a tile in pixels, including the gap around it
+	CellSize = 32
+	// cellGap is the space between two tiles
+	cellGap = 2
+
+	// GIFMaxColors is the palette size of a GIF, which limits the number of
+	// players that can be drawn
+	GIFMaxColors = 256
+)
+
+var (
+	BackgroundColor = color.RGBA{0x13, 0x24, 0x2f, 0xff}
+	EmptyTileColor  = color.RGBA{0x2b, 0x3f, 0x4d, 0xff}
+	ArrowColor      = color.RGBA{0x86, 0xbb, 0xd8, 0xff}
+)
+
+var ErrorInvalidColor = errors.New("invalid color")
+
+// Colors maps the players to the colors their tiles are drawn with
+type Colors map[engine.Player]color.RGBA
+
+// ParseHexColor parses a CSS hex color, like #f26419
+func ParseHexColor(hex string) (color.RGBA, error) {
+	if len(hex) != 7 || hex[0] != '#' {
+		return color.RGBA{}, ErrorInvalidColor
+	}
+
+	value, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, ErrorInvalidColor
+	}
+
+	return color.RGBA{uint8(value >> 16), uint8(value >> 8), uint8(value), 0xff}, nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (colors Colors) tileColor(tile engine.Tile) color.RGBA {
+	player, err := tile.ToPlayer()
+	if err != nil {
+		return EmptyTileColor
+	}
+
+	if c, ok := colors[player]; ok {
+		return c
+	}
+
+	return ArrowColor
+}
+
+// arrow is an edge arrow, pointing in the direction the line it is next to
+// is shifted
+type arrow struct {
+	row, col  int
+	direction engine.Direction
+}
+
+// arrows returns the edge arrows of a board. The board is drawn with a ring
+// of arrow cells around it, so tile (row, col) is in cell (row+1, col+1).
+func arrows(width, height int) []arrow {
+	var arrows []arrow
+	for col := 0; col < width; col++ {
+		arrows = append(arrows,
+			arrow{0, col + 1, engine.DirectionDown},
+			arrow{height + 1, col + 1, engine.DirectionUp})
+	}
+	for row := 0; row < height; row++ {
+		arrows = append(arrows,
+			arrow{row + 1, 0, engine.DirectionRight},
+			arrow{row + 1, width + 1, engine.DirectionLeft})
+	}
+
+	return arrows
+}
+
+// triangle returns the corners of the arrow in pixels
+func (a arrow) triangle() [3]image.Point {
+	x, y := a.col*CellSize, a.row*CellSize
+	quarter, half := CellSize/4, CellSize/2
+
+	switch a.direction {
+	case engine.DirectionUp:
+		return [3]image.Point{{x + half, y + quarter}, {x + 3*quarter, y + 3*quarter}, {x + quarter, y + 3*quarter}}
+	case engine.DirectionDown:
+		return [3]image.Point{{x + quarter, y + quarter}, {x + 3*quarter, y + quarter}, {x + half, y + 3*quarter}}
+	case engine.DirectionLeft:
+		return [3]image.Point{{x + quarter, y + half}, {x + 3*quarter, y + quarter}, {x + 3*quarter, y + 3*quarter}}
+	}
+
+	return [3]image.Point{{x + quarter, y + quarter}, {x + 3*quarter, y + half}, {x + quarter, y + 3*quarter}}
+}
+
+func boardSize(board engine.Board) (width, height int) {
+	if len(board) == 0 {
+		return 0, 0
+	}
+
+	return len(board[0]), len(board)
+}
+
+// Size returns the size of the image of the board in pixels
+func Size(board engine.Board) (width, height int) {
+	width, height = boardSize(board)
+	return (width + 2) * CellSize, (height + 2) * CellSize
+}
+
+// Image draws the board with its edge arrows
+func Image(board engine.Board, colors Colors) *image.RGBA {
+	width, height := Size(board)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(BackgroundColor), image.Point{}, draw.Src)
+
+	for row := range board {
+		for col, tile := range board[row] {
+			draw.Draw(img, tileRect(row, col), image.NewUniform(colors.tileColor(tile)), image.Point{}, draw.Src)
+		}
+	}
+
+	boardWidth, boardHeight := boardSize(board)
+	for _, arrow := range arrows(boardWidth, boardHeight) {
+		fillTriangle(img, arrow.triangle(), ArrowColor)
+	}
+
+	return img
+}
+
+func tileRect(row, col int) image.Rectangle {
+	x, y := (col+1)*CellSize, (row+1)*CellSize
+	return image.Rect(x+cellGap/2, y+cellGap/2, x+CellSize-cellGap/2, y+CellSize-cellGap/2)
+}
+
+// fillTriangle fills the pixels whose centers are inside the triangle
+func fillTriangle(img draw.Image, corners [3]image.Point, c color.Color) {
+	var bounds image.Rectangle
+	for _, corner := range corners {
+		bounds = bounds.Union(image.Rectangle{corner, corner.Add(image.Pt(1, 1))})
+	}
+
+	// Twice the signed area of the triangle (a, b, p), in doubled coordinates
+	// so that pixel centers are integers
+	edge := func(a, b image.Point, px, py int) int {
+		return (2*b.X-2*a.X)*(py-2*a.Y) - (2*b.Y-2*a.Y)*(px-2*a.X)
+	}
+
+	a, b, t := corners[0], corners[1], corners[2]
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			px, py := 2*x+1, 2*y+1
+			e1, e2, e3 := edge(a, b, px, py), edge(b, t, px, py), edge(t, a, px, py)
+			if (e1 >= 0 && e2 >= 0 && e3 >= 0) || (e1 <= 0 && e2 <= 0 && e3 <= 0) {
+				img.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// PNG writes the board as a PNG image
+func PNG(w io.Writer, board engine.Board, colors Colors) error {
+	return png.Encode(w, Image(board, colors))
+}
+
+// SVG writes the board as an SVG image
+func SVG(w io.Writer, board engine.Board, colors Colors) error {
+	width, height := Size(board)
+
+	if _, err := fmt.Fprintf(w,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, `<rect width="%d" height="%d" fill="%s"/>`+"\n", width, height, hexColor(BackgroundColor))
+
+	for row := range board {
+		for col, tile := range board[row] {
+			rect := tileRect(row, col)
+			fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+				rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), hexColor(colors.tileColor(tile)))
+		}
+	}
+
+	boardWidth, boardHeight := boardSize(board)
+	for _, arrow := range arrows(boardWidth, boardHeight) {
+		corners := arrow.triangle()
+		fmt.Fprintf(w, `<polygon points="%d,%d %d,%d %d,%d" fill="%s"/>`+"\n",
+			corners[0].X, corners[0].Y, corners[1].X, corners[1].Y, corners[2].X, corners[2].Y,
+			hexColor(ArrowColor))
+	}
+
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
+
+// GIF writes an animation of the boards, showing each for delay hundredths
+// of a second. The last board is shown for longer.
+func GIF(w io.Writer, boards []engine.Board, colors Colors, delay int) error {
+	if len(boards) == 0 {
+		return errors.New("no boards to animate")
+	}
+
+	palette := color.Palette{BackgroundColor, EmptyTileColor, ArrowColor}
+	for _, c := range colors {
+		palette = append(palette, c)
+	}
+	if len(palette) > GIFMaxColors {
+		return errors.New("too many colors for a GIF")
+	}
+
+	animation := &gif.GIF{}
+	for i, board := range boards {
+		img := Image(board, colors)
+		paletted := image.NewPaletted(img.Bounds(), palette)
+		draw.Draw(paletted, img.Bounds(), img, image.Point{}, draw.Src)
+
+		frameDelay := delay
+		if i == len(boards)-1 {
+			frameDelay = delay * 4
+		}
+
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, frameDelay)
+	}
+
+	return gif.EncodeAll(w, animation)
+}
@@ -0,0 +1,87 @@
+package render
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+var testColors = Colors{
+	1: {0xf2, 0x64, 0x19, 0xff},
+	2: {0x33, 0x65, 0x8a, 0xff},
+}
+
+func TestParseHexColor(t *testing.T) {
+	c, err := ParseHexColor("#f26419")
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA{0xf2, 0x64, 0x19, 0xff}, c)
+
+	for _, invalid := range []string{"", "f26419", "#f2641", "#f2641g", "#f264190"} {
+		_, err := ParseHexColor(invalid)
+		assert.ErrorIs(t, err, ErrorInvalidColor, invalid)
+	}
+}
+
+func TestImage(t *testing.T) {
+	board := engine.Board{{1, 0, 2}, {0, 2, 0}}
+	img := Image(board, testColors)
+
+	width, height := Size(board)
+	assert.Equal(t, 5*CellSize, width)
+	assert.Equal(t, 4*CellSize, height)
+	assert.Equal(t, width, img.Bounds().Dx())
+
+	center := func(row, col int) (int, int) {
+		return (col+1)*CellSize + CellSize/2, (row+1)*CellSize + CellSize/2
+	}
+
+	assert.Equal(t, testColors[1], img.RGBAAt(center(0, 0)))
+	assert.Equal(t, EmptyTileColor, img.RGBAAt(center(0, 1)))
+	assert.Equal(t, testColors[2], img.RGBAAt(center(1, 1)))
+
+	// The arrow of the first column is drawn above it
+	assert.Equal(t, ArrowColor, img.RGBAAt(center(-1, 0)))
+	// The corners have no arrows
+	assert.Equal(t, BackgroundColor, img.RGBAAt(center(-1, -1)))
+}
+
+func TestPNG(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, PNG(&buf, engine.Board{{1, 2}, {0, 0}}, testColors))
+
+	img, err := png.Decode(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 4*CellSize, img.Bounds().Dx())
+}
+
+func TestSVG(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, SVG(&buf, engine.Board{{1, 2}, {0, 0}}, testColors))
+
+	svg := buf.String()
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, `fill="#f26419"`)
+	assert.Contains(t, svg, `fill="#33658a"`)
+	assert.Equal(t, 8, strings.Count(svg, "<polygon"))
+}
+
+func TestGIF(t *testing.T) {
+	boards := []engine.Board{{{0, 0}}, {{1, 0}}, {{1, 2}}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, GIF(&buf, boards, testColors, 50))
+
+	animation, err := gif.DecodeAll(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, animation.Image, 3)
+	assert.Equal(t, []int{50, 50, 200}, animation.Delay)
+
+	assert.Error(t, GIF(&buf, nil, testColors, 50))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/archive"
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/engine"
+)
+
+// ReplayView is a position in an archived game. It can be rendered with the
+// board template, which shows it read-only.
+type ReplayView struct {
+	engine.Game
+	Record archive.Record
+
+	// Move is the number of events played to reach the position
+	Move  int
+	Moves int
+}
+
+// newReplayView replays the archived game up to the move. Moves outside of
+// the game are clamped to its start or end.
+func newReplayView(record archive.Record, move int) (ReplayView, error) {
+	positions, err := record.Replay()
+	if err != nil {
+		return ReplayView{}, err
+	}
+
+	move = max(0, min(move, len(positions)-1))
+	return ReplayView{
+		Game:   positions[move],
+		Record: record,
+		Move:   move,
+		Moves:  len(positions) - 1,
+	}, nil
+}
+
+func (v ReplayView) ReadOnly() bool {
+	return true
+}
+
+// Hints returns nil, because replays don't show hints
+func (v ReplayView) Hints() *Hints {
+	return nil
+}
+
+func (v ReplayView) Profile(player engine.Player) auth.PlayerProfile {
+	playerRecord, ok := v.Record.Player(player)
+	if !ok {
+		return auth.DefaultProfile(player)
+	}
+
+	return auth.PlayerProfile{Name: playerRecord.Name, Color: playerRecord.Color}
+}
+
+func (v ReplayView) TileProfile(tile engine.Tile) auth.PlayerProfile {
+	player, err := tile.ToPlayer()
+	if err != nil {
+		return auth.PlayerProfile{}
+	}
+
+	return v.Profile(player)
+}
+
+func (v ReplayView) InProgress() bool {
+	return v.Stage() == engine.StageInit || v.Stage() == engine.StatePlaying
+}
+
+// LastEvent describes the event which led to the position
+func (v ReplayView) LastEvent() string {
+	if v.Move == 0 {
+		return "Game start"
+	}
+
+	event := v.Record.History[v.Move-1]
+	name := v.Profile(event.Player).Name
+
+	switch event.Kind {
+	case engine.EventMove:
+		move := event.Move
+		if move.Kind == engine.MovePut {
+			return fmt.Sprintf("%s placed a tile on row %d, column %d", name, move.Row+1, move.Col+1)
+		}
+
+		line := "row"
+		if move.Direction == engine.DirectionUp || move.Direction == engine.DirectionDown {
+			line = "column"
+		}
+		return fmt.Sprintf("%s shifted %s %d %s", name, line, move.Index+1, move.Direction)
+	case engine.EventPass:
+		return fmt.Sprintf("%s passed", name)
+	case engine.EventEliminate:
+		return fmt.Sprintf("%s was eliminated", name)
+	case engine.EventDraw:
+		return "The players agreed to a draw"
+	}
+
+	return ""
+}
+
+// Result describes how the game ended
+func (v ReplayView) Result() string {
+	if v.Record.Draw {
+		return "Draw"
+	}
+
+	return fmt.Sprintf("%s won", v.Profile(v.Record.Winner).Name)
+}
+
+func registerReplayRoutes(e *echo.Echo) {
+	renderReplay := func(c echo.Context, name string) error {
+		record, ok := archiveStore.Get(c.Param("id"))
+		if !ok {
+			return c.Render(http.StatusNotFound, "errorPage", "Error: No such game")
+		}
+
+		move, err := strconv.Atoi(c.QueryParam("move"))
+		if err != nil {
+			move = 0
+		}
+
+		view, err := newReplayView(record, move)
+		if err != nil {
+			return c.Render(http.StatusInternalServerError, "errorPage", "Error: The game could not be replayed")
+		}
+
+		return c.Render(http.StatusOK, name, view)
+	}
+
+	e.GET("/replay/:id", func(c echo.Context) error {
+		return renderReplay(c, "replay")
+	})
+
+	e.GET("/replay/:id/position", func(c echo.Context) error {
+		return renderReplay(c, "replayPosition")
+	})
+}
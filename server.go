@@ -2,22 +2,36 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
+	"os"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
+	"github.com/Denloob/cadere/accounts"
+	"github.com/Denloob/cadere/api"
+	"github.com/Denloob/cadere/archive"
 	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/bot"
+	"github.com/Denloob/cadere/clock"
 	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/puzzle"
+	"github.com/Denloob/cadere/rating"
+	"github.com/Denloob/cadere/tournament"
 )
 
 type Templates struct {
@@ -42,6 +56,109 @@ func newTemplates() *Templates {
 	}
 }
 
+// GameView is the data the game templates are rendered with
+type GameView struct {
+	*engine.Game
+	session auth.GameSession
+
+	Host        engine.Player
+	Public      bool
+	TimeControl clock.TimeControl
+	LocalSeats  map[engine.Player]engine.Player
+
+	Rated        bool
+	RatingDeltas map[engine.Player]float64
+
+	// HintsEnabled is the hints setting of the game, and Hints the hints
+	// for the current position, nil when there are none to show
+	HintsEnabled bool
+	Hints        *Hints
+}
+
+// NewGameView creates a view of the web session. The caller must hold the
+// SessionMutex for as long as the view is used.
+func NewGameView(webSession *WebGameSession) GameView {
+	var hints *Hints
+	if webSession.Hints && webSession.Session.Game.Stage() == engine.StatePlaying {
+		hints = webSession.currentHints()
+	}
+
+	return GameView{
+		Game:    webSession.Session.Game,
+		session: webSession.Session,
+
+		Host:        webSession.Host,
+		Public:      webSession.Public,
+		TimeControl: webSession.Clock.Control(),
+		LocalSeats:  webSession.LocalSeats,
+
+		Rated:        webSession.Rated,
+		RatingDeltas: webSession.RatingDeltas,
+
+		HintsEnabled: webSession.Hints,
+		Hints:        hints,
+	}
+}
+
+func (v GameView) Width() int {
+	return len(v.Board[0])
+}
+
+// TimeControlName returns the value of the timeControl form field matching the time control
+func (v GameView) TimeControlName() string {
+	switch v.TimeControl.Mode {
+	case clock.ModePerMove:
+		return "move"
+	case clock.ModeBank:
+		return "bank"
+	}
+
+	return "none"
+}
+
+// TimeoutPolicyName returns the value of the onTimeout form field matching the time control
+func (v GameView) TimeoutPolicyName() string {
+	switch v.TimeControl.OnTimeout {
+	case clock.TimeoutAutoPlay:
+		return "autoplay"
+	case clock.TimeoutEliminate:
+		return "eliminate"
+	}
+
+	return "pass"
+}
+
+func (v GameView) Nonce() string {
+	return v.session.Nonce()
+}
+
+func (v GameView) Profile(player engine.Player) auth.PlayerProfile {
+	return v.session.Profile(player)
+}
+
+func (v GameView) TileProfile(tile engine.Tile) auth.PlayerProfile {
+	player, err := tile.ToPlayer()
+	if err != nil {
+		return auth.PlayerProfile{}
+	}
+
+	return v.session.Profile(player)
+}
+
+// ReadOnly is false, because the board of a live game can be played on
+func (v GameView) ReadOnly() bool {
+	return false
+}
+
+// ReplayId returns the ID of the game in the archive, once it is over
+func (v GameView) ReplayId() string {
+	return gameUrlId(v.Nonce())
+}
+
+func (v GameView) InProgress() bool {
+	return v.Stage() == engine.StageInit || v.Stage() == engine.StatePlaying
+}
+
 type GameError struct {
 	error
 }
@@ -75,6 +192,22 @@ var stageFuncMap = template.FuncMap{
 	"GameWebsocketErrInvalidToken": func() string { return GameWebsocketErrInvalidToken },
 
 	"WebsocketCloseProtocolError": func() int { return websocket.CloseProtocolError },
+
+	"FormatClock": formatClock,
+
+	"OpenGraph": openGraphFor,
+
+	"Inc":     func(i int) int { return i + 1 },
+	"Percent": func(share float64) string { return fmt.Sprintf("%.0f%%", share*100) },
+
+	"ChatMessageMaxLength": func() int { return CHAT_MESSAGE_MAX_LENGTH },
+
+	"PlayerNameMaxLength": func() int { return PLAYER_NAME_MAX_LENGTH },
+	"DefaultProfile":      func() auth.PlayerProfile { return auth.DefaultProfile(CreatorPlayerID) },
+}
+
+func formatClock(seconds int64) string {
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
 }
 
 const (
@@ -83,6 +216,11 @@ const (
 
 	GAME_INACTIVITY_TIMEOUT        = 10 * time.Minute
 	GAME_INACTIVITY_TIMEOUT_NOTICE = 1*time.Minute + 30*time.Second
+
+	PLAYER_NAME_MAX_LENGTH = 20
+
+	TIME_CONTROL_LIMIT_MAX     = 24 * time.Hour
+	TIME_CONTROL_INCREMENT_MAX = 10 * time.Minute
 )
 
 const CreatorPlayerID = 1
@@ -90,6 +228,9 @@ const CreatorPlayerID = 1
 var WebsocketCloseInvalidToken = websocket.FormatCloseMessage(websocket.CloseProtocolError, GameWebsocketErrInvalidToken)
 
 type GameAction struct {
+	// One of: start, put, shift, resign, offerDraw, acceptDraw, declineDraw,
+	// rematch, chat, mute, unmute, kick, transferHost, settings,
+	// addLocalPlayer, removeLocalPlayer
 	Action string
 
 	// action shift
@@ -100,50 +241,175 @@ type GameAction struct {
 	Row    int
 	Col    int
 	Player engine.Player
+
+	// action chat
+	Message string
+	// actions mute, unmute, kick and transferHost use Player
+
+	// Form holds all the fields of the message, for actions which submit a
+	// form, like settings
+	Form map[string]any `json:"-"`
+}
+
+// FormValue returns the named field of the form as a string
+func (action GameAction) FormValue(name string) string {
+	value, ok := action.Form[name]
+	if !ok || value == nil {
+		return ""
+	}
+
+	if str, ok := value.(string); ok {
+		return str
+	}
+	return fmt.Sprint(value)
+}
+
+// GameConn is a connection the game screen is pushed to, like the websocket
+// of a browser or an SSH session
+type GameConn interface {
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// GameSocket is a connection of a player
+type GameSocket struct {
+	GameConn
+	Player engine.Player
+}
+
+// notifyConn is a GameConn for the frontends which don't show the HTML of the
+// browsers, like SSH sessions and bots. The messages only tell them that the
+// game changed.
+type notifyConn struct {
+	updates   chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newNotifyConn() *notifyConn {
+	return &notifyConn{
+		updates: make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (c *notifyConn) WriteMessage(messageType int, data []byte) error {
+	select {
+	case <-c.closed:
+		return websocket.ErrCloseSent
+	default:
+	}
+
+	if messageType == websocket.CloseMessage {
+		return c.Close()
+	}
+
+	select {
+	case c.updates <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *notifyConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
 }
 
 type WebGameSession struct {
 	socketsMutex *sync.RWMutex
-	Sockets      []*websocket.Conn
+	Sockets      []*GameSocket
 
 	SessionMutex *sync.RWMutex
 	Session      auth.GameSession
+	// Clock is guarded by SessionMutex
+	Clock *clock.Clock
+	// StartingOrder is the turn order the game started with, including
+	// players who were eliminated since. Guarded by SessionMutex
+	StartingOrder []engine.Player
+	// RematchNonce is the nonce of the rematch session, if one was created.
+	// Guarded by SessionMutex
+	RematchNonce string
+	// Public games are listed in the lobby. Guarded by SessionMutex
+	Public bool
+	// Host is the player who can start the game and change its settings.
+	// Guarded by SessionMutex
+	Host engine.Player
+	// Kicked players can no longer use their tokens. Guarded by SessionMutex
+	Kicked map[engine.Player]bool
+	// Rated games update the ratings of the players when they are over.
+	// Guarded by SessionMutex
+	Rated bool
+	// RatingDeltas are the rating changes of a finished rated game, nil until
+	// they are recorded. Guarded by SessionMutex
+	RatingDeltas map[engine.Player]float64
+	// Hints show the players which shifts push their tiles off, and suggest
+	// a move. Rated games never show them. Guarded by SessionMutex
+	Hints bool
+	// StartedAt is when the game left the lobby. Guarded by SessionMutex
+	StartedAt time.Time
+	// Archived is set once the finished game was archived. Guarded by
+	// SessionMutex
+	Archived bool
+	// Bots play automatically for the players they are assigned to.
+	// Guarded by SessionMutex
+	Bots map[engine.Player]bot.Bot
+	// LocalSeats maps the players who play from the browser of another
+	// player to that player. Guarded by SessionMutex
+	LocalSeats map[engine.Player]engine.Player
+
+	Chat *Chat
+
+	// hints are the hints of the position after hintsMoves events, so that
+	// the hint bot runs once per move rather than on every render. Guarded by
+	// hintsMutex, because the views are also created under the read lock.
+	hintsMutex *sync.Mutex
+	hints      *Hints
+	hintsMoves int
 
 	lastActionTimestamp int64
 }
 
-func (session WebGameSession) LastActionTimestamp() int64 {
+func (session *WebGameSession) LastActionTimestamp() int64 {
 	return atomic.LoadInt64(&session.lastActionTimestamp)
 }
 
-func (session WebGameSession) SetLastActionTimestamp(timestamp int64) {
+func (session *WebGameSession) SetLastActionTimestamp(timestamp int64) {
 	atomic.StoreInt64(&session.lastActionTimestamp, timestamp)
 }
 
-func NewWebGameSession(session auth.GameSession) *WebGameSession {
+func NewWebGameSession(session auth.GameSession, timeControl clock.TimeControl) *WebGameSession {
 	return &WebGameSession{
 		socketsMutex: &sync.RWMutex{},
-		Sockets:      []*websocket.Conn{},
+		Sockets:      []*GameSocket{},
 
 		SessionMutex: &sync.RWMutex{},
 		Session:      session,
+		Clock:        clock.New(timeControl),
+		Bots:         make(map[engine.Player]bot.Bot),
+		LocalSeats:   make(map[engine.Player]engine.Player),
+		Host:         CreatorPlayerID,
+		Kicked:       make(map[engine.Player]bool),
+		Chat:         NewChat(),
+
+		hintsMutex: &sync.Mutex{},
 
 		lastActionTimestamp: time.Now().Unix(),
 	}
 }
 
-func (w *WebGameSession) AddSocket(conn *websocket.Conn) {
+func (w *WebGameSession) AddSocket(conn *GameSocket) {
 	w.socketsMutex.Lock()
 	defer w.socketsMutex.Unlock()
 
 	w.Sockets = append(w.Sockets, conn)
 }
 
-func (w *WebGameSession) RemoveSocket(conn *websocket.Conn) {
+func (w *WebGameSession) RemoveSocket(conn *GameSocket) {
 	w.socketsMutex.Lock()
 	defer w.socketsMutex.Unlock()
 
-	var new_connections []*websocket.Conn
+	var new_connections []*GameSocket
 	for _, currConn := range w.Sockets {
 		if currConn != conn {
 			new_connections = append(new_connections, currConn)
@@ -153,12 +419,19 @@ func (w *WebGameSession) RemoveSocket(conn *websocket.Conn) {
 	w.Sockets = new_connections
 }
 
+// Broadcast sends the message to all the sockets, dropping the closed ones
+func (w *WebGameSession) Broadcast(message []byte) {
+	w.FilterForEach(func(conn *GameSocket) bool {
+		return conn.WriteMessage(websocket.TextMessage, message) != websocket.ErrCloseSent
+	})
+}
+
 // FilterForEach Execute f for each element, and remove them if `f` returns false
-func (w *WebGameSession) FilterForEach(f func(conn *websocket.Conn) bool) {
+func (w *WebGameSession) FilterForEach(f func(conn *GameSocket) bool) {
 	w.socketsMutex.Lock()
 	defer w.socketsMutex.Unlock()
 
-	var new_connections []*websocket.Conn
+	var new_connections []*GameSocket
 	for _, currConn := range w.Sockets {
 
 		if f(currConn) {
@@ -172,13 +445,19 @@ func (w *WebGameSession) FilterForEach(f func(conn *websocket.Conn) bool) {
 type Games map[string]*WebGameSession
 
 var games = make(Games)
+
+// gamesMutex guards games. It may be taken while holding a SessionMutex, so
+// a SessionMutex must never be locked while holding it.
 var gamesMutex = sync.RWMutex{}
 
-func (g Games) AddSession(session auth.GameSession) {
+func (g Games) AddSession(session auth.GameSession, timeControl clock.TimeControl) *WebGameSession {
 	gamesMutex.Lock()
 	defer gamesMutex.Unlock()
 
-	g[session.Nonce()] = NewWebGameSession(session)
+	webSession := NewWebGameSession(session, timeControl)
+	g[session.Nonce()] = webSession
+
+	return webSession
 }
 
 func (g Games) GetWebSessionForToken(token string) (*WebGameSession, error) {
@@ -219,7 +498,7 @@ func (g Games) CleanupStaleGames() {
 			expired := timeToExpiration <= 0
 			notice, err := templates.RenderToBytes("expirationNotice", int64(timeToExpiration.Seconds()))
 
-			session.FilterForEach(func(conn *websocket.Conn) bool {
+			session.FilterForEach(func(conn *GameSocket) bool {
 
 				if err == nil {
 					conn.WriteMessage(websocket.TextMessage, notice)
@@ -235,6 +514,7 @@ func (g Games) CleanupStaleGames() {
 
 			if expired {
 				delete(g, nonce)
+				lobby.Notify()
 			}
 		}
 	}
@@ -247,103 +527,704 @@ func (g Games) CleanupStaleGamesEvery(interval time.Duration) {
 	}
 }
 
-type shiftFunction func(engine.Board, int) error
+func (g Games) EnforceClocks() {
+	gamesMutex.RLock()
+	sessions := make([]*WebGameSession, 0, len(g))
+	for _, session := range g {
+		sessions = append(sessions, session)
+	}
+	gamesMutex.RUnlock()
+
+	now := time.Now()
+	for _, session := range sessions {
+		response, err := session.EnforceClock(now)
+		if err != nil || response == nil {
+			continue
+		}
+
+		session.Broadcast(response)
+	}
+}
+
+func (g Games) EnforceClocksEvery(interval time.Duration) {
+	for {
+		g.EnforceClocks()
+		time.Sleep(interval)
+	}
+}
+
+// HandleAction executes an action of the player, sent from any of the
+// frontends, and pushes the result to everyone in the game
+func (webSession *WebGameSession) HandleAction(action GameAction, player engine.Player) error {
+	var response []byte
+	var err error
+	if isChatAction(action.Action) {
+		err = webSession.ExecuteChatAction(action, player)
+	} else {
+		response, err = webSession.ExecuteAction(action, player)
+	}
+	if err != nil {
+		return err
+	}
+
+	webSession.SetLastActionTimestamp(time.Now().Unix())
+
+	if response != nil {
+		webSession.Broadcast(response)
+	}
+
+	if action.Action == "start" || action.Action == "settings" {
+		lobby.Notify()
+	}
 
-func (webSession WebGameSession) ExecuteAction(action GameAction, player engine.Player) (response []byte, err error) {
+	return nil
+}
+
+// ExecuteAction executes the action and returns the response which should be
+// broadcasted to all the players. The response is nil if there is nothing to
+// broadcast.
+func (webSession *WebGameSession) ExecuteAction(action GameAction, player engine.Player) (response []byte, err error) {
 	webSession.SessionMutex.Lock()
 	defer webSession.SessionMutex.Unlock()
 
+	if action.Action == "rematch" {
+		return nil, webSession.rematch(player)
+	}
+
+	response, err = webSession.executeAction(action, player)
+	if err != nil {
+		return nil, err
+	}
+
+	botsPlayed := webSession.playBotTurns()
+	if webSession.recordFinishedGame() || botsPlayed {
+		response, err = templates.RenderToBytes("gameScreen", NewGameView(webSession))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return webSession.withClocks(response, time.Now())
+}
+
+// playBotTurns plays for the bots until it's the turn of a human player.
+// Returns whether any moves were played.
+func (webSession *WebGameSession) playBotTurns() bool {
+	game := webSession.Session.Game
+
+	played := false
+	for game.Stage() == engine.StageInit || game.Stage() == engine.StatePlaying {
+		player := game.CurrentPlayer()
+		playerBot, ok := webSession.Bots[player]
+		if !ok || !webSession.anyHumanPlaying() {
+			break
+		}
+
+		move, err := playerBot.Move(*game)
+		if err != nil {
+			break
+		}
+		if err := game.Play(player, move); err != nil {
+			break
+		}
+
+		played = true
+	}
+
+	return played
+}
+
+func (webSession *WebGameSession) anyHumanPlaying() bool {
+	for _, player := range webSession.Session.Game.Players() {
+		if _, ok := webSession.Bots[player]; !ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (webSession *WebGameSession) executeAction(action GameAction, player engine.Player) ([]byte, error) {
+	if err := webSession.applyAction(action, player); err != nil {
+		return nil, err
+	}
+
+	return templates.RenderToBytes("gameScreen", NewGameView(webSession))
+}
+
+func (webSession *WebGameSession) applyAction(action GameAction, player engine.Player) error {
 	session := webSession.Session
+	player, err := webSession.actingPlayer(action, player)
+	if err != nil {
+		return err
+	}
+
 	switch action.Action {
 	case "shift":
-		switch action.Direction {
-		case "up":
-			return shiftWith(engine.Board.ShiftUp, session, player, action.Index)
-		case "down":
-			return shiftWith(engine.Board.ShiftDown, session, player, action.Index)
-		case "left":
-			return shiftWith(engine.Board.ShiftLeft, session, player, action.Index)
-		case "right":
-			return shiftWith(engine.Board.ShiftRight, session, player, action.Index)
+		direction, err := engine.ParseDirection(action.Direction)
+		if err != nil {
+			return ErrorBadRequest
 		}
+		return shift(session, player, direction, action.Index)
 	case "put":
 		return putTile(session, player, action.Row, action.Col)
 	case "start":
-		return startSession(session, player)
+		err := webSession.startSession(player)
+		if err == nil {
+			webSession.StartingOrder = session.Game.Players()
+			webSession.StartedAt = time.Now()
+		}
+		return err
+	case "resign":
+		return resign(session, player)
+	case "offerDraw", "acceptDraw", "declineDraw":
+		return webSession.localDrawAction(action.Action, player)
+	case "kick":
+		return webSession.kick(player, action.Player)
+	case "transferHost":
+		return webSession.transferHost(player, action.Player)
+	case "settings":
+		return webSession.changeSettings(player, action)
+	case "addLocalPlayer":
+		return webSession.addLocalPlayer(player, action)
+	case "removeLocalPlayer":
+		return webSession.removeLocalPlayer(player, action.Player)
 	}
 
-	return nil, fmt.Errorf("unknown action: %s", action.Action)
+	return fmt.Errorf("unknown action: %s", action.Action)
 }
 
-func startSession(session auth.GameSession, player engine.Player) ([]byte, error) {
-	game := session.Game
+func resign(session auth.GameSession, player engine.Player) error {
+	if err := session.Game.Resign(player); err != nil {
+		if errors.Is(err, engine.ErrorGameNotInProgress) {
+			return GameErrorf("You can resign only while the game is in progress")
+		}
+		return ErrorBadRequest
+	}
+
+	return nil
+}
+
+func drawAction(action func(engine.Player) error, player engine.Player) error {
+	if err := action(player); err != nil {
+		if errors.Is(err, engine.ErrorGameNotInProgress) {
+			return GameErrorf("Draws can be offered only while the game is in progress")
+		}
+		return ErrorBadRequest
+	}
+
+	return nil
+}
+
+// rematch creates a new session with the same players and the turn order
+// rotated by one, and sends every connected player a fresh token for it.
+func (webSession *WebGameSession) rematch(player engine.Player) error {
+	game := webSession.Session.Game
+	if game.Stage() != engine.StageOver {
+		return GameErrorf("A rematch can be requested only after the game is over")
+	}
+
+	players := webSession.StartingOrder
+	if len(players) == 0 {
+		players = game.Players()
+	}
+	if !slices.Contains(players, player) {
+		return ErrorBadRequest
+	}
+
+	if webSession.RematchNonce == "" {
+		nonce, err := auth.GenerateNonce(NonceBitLength)
+		if err != nil {
+			return err
+		}
+
+		rematchGame := engine.NewGame(engine.NewBoard(len(game.Board[0]), len(game.Board)))
+		rotated := append(append([]engine.Player{}, players[1:]...), players[0])
+		if err := rematchGame.AddPlayers(rotated...); err != nil {
+			return err
+		}
+
+		rematchSession := games.AddSession(auth.NewGameSession(&rematchGame, nonce), webSession.Clock.Control())
+		for player, playerBot := range webSession.Bots {
+			rematchSession.Bots[player] = playerBot
+		}
+		for seat, player := range webSession.LocalSeats {
+			rematchSession.LocalSeats[seat] = player
+		}
+		for _, player := range players {
+			rematchSession.Session.SetProfile(player, webSession.Session.Profile(player))
+			if username := webSession.Session.Account(player); username != "" {
+				rematchSession.Session.SetAccount(player, username)
+			}
+		}
+		rematchSession.Host = webSession.Host
+		rematchSession.Rated = webSession.Rated
+		rematchSession.Hints = webSession.Hints
+		webSession.RematchNonce = nonce
+	}
+
+	rematchSession, ok := games.Get(webSession.RematchNonce)
+	if !ok {
+		return GameErrorf("The rematch has expired")
+	}
+
+	rematchSession.SessionMutex.RLock()
+	defer rematchSession.SessionMutex.RUnlock()
+
+	webSession.FilterForEach(func(conn *GameSocket) bool {
+		token, err := rematchSession.NewTokenForPlayer(conn.Player)
+		if err != nil {
+			return true
+		}
+
+		message, err := templates.RenderToBytes("rematch", token)
+		if err != nil {
+			return true
+		}
+
+		return conn.WriteMessage(websocket.TextMessage, message) != websocket.ErrCloseSent
+	})
+
+	return nil
+}
+
+// withClocks updates the clock and appends the rendered clocks to the response.
+func (webSession *WebGameSession) withClocks(response []byte, now time.Time) ([]byte, error) {
+	webSession.updateClock(now)
+
+	clocks, err := webSession.renderClocks(now)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(response, clocks...), nil
+}
+
+// updateClock hands the turn clock to the current player, or stops it if the
+// game is over.
+func (webSession *WebGameSession) updateClock(now time.Time) {
+	game := webSession.Session.Game
+	switch game.Stage() {
+	case engine.StageInit, engine.StatePlaying:
+		if current, running := webSession.Clock.Running(); !running || current != game.CurrentPlayer() {
+			webSession.Clock.Start(game.CurrentPlayer(), now)
+		}
+	case engine.StageOver:
+		webSession.Clock.Stop(now)
+	}
+}
+
+type clockView struct {
+	Profile auth.PlayerProfile
+	Seconds int64
+	Running bool
+}
+
+func (webSession *WebGameSession) renderClocks(now time.Time) ([]byte, error) {
+	views := []clockView{}
+	if webSession.Clock.Control().Enabled() {
+		running, isRunning := webSession.Clock.Running()
+		for _, player := range webSession.Session.Game.Players() {
+			views = append(views, clockView{
+				Profile: webSession.Session.Profile(player),
+				Seconds: int64(webSession.Clock.Remaining(player, now).Seconds()),
+				Running: isRunning && running == player,
+			})
+		}
+	}
+
+	return templates.RenderToBytes("clocks", views)
+}
+
+// EnforceClock applies the timeout policy if the current player ran out of
+// time. Returns nil if nothing has changed.
+func (webSession *WebGameSession) EnforceClock(now time.Time) ([]byte, error) {
+	webSession.SessionMutex.Lock()
+	defer webSession.SessionMutex.Unlock()
+
+	player, expired := webSession.Clock.Expired(now)
+	if !expired {
+		return nil, nil
+	}
+
+	return webSession.playTimedOutTurn(player, webSession.Clock.Control().OnTimeout, now)
+}
+
+// playTimedOutTurn applies the timeout policy to the turn of the player and
+// lets the bots move. Returns the response to broadcast. The caller must hold
+// the SessionMutex.
+func (webSession *WebGameSession) playTimedOutTurn(player engine.Player, policy clock.TimeoutPolicy, now time.Time) ([]byte, error) {
+	if err := applyTimeoutPolicy(webSession.Session.Game, player, policy); err != nil {
+		return nil, err
+	}
+
+	// Force the clock to restart even if the same player is to move again.
+	webSession.Clock.Stop(now)
+
+	webSession.playBotTurns()
+	webSession.recordFinishedGame()
+
+	response, err := templates.RenderToBytes("gameScreen", NewGameView(webSession))
+	if err != nil {
+		return nil, err
+	}
+
+	return webSession.withClocks(response, now)
+}
+
+// applyTimeoutPolicy plays the turn of a player who ran out of time. Passing
+// is not possible during the init stage, because every player has to place
+// their tiles, so a random tile is placed instead.
+func applyTimeoutPolicy(game *engine.Game, player engine.Player, policy clock.TimeoutPolicy) error {
+	if policy == clock.TimeoutEliminate && game.PlayerCount() > 1 {
+		return game.EliminatePlayer(player)
+	}
+
+	if policy == clock.TimeoutPass && game.Stage() == engine.StatePlaying {
+		return game.Pass(player)
+	}
+
+	move, err := bot.Random{}.Move(*game)
+	if err != nil {
+		return err
+	}
+
+	return game.Play(player, move)
+}
+
+func (webSession *WebGameSession) startSession(player engine.Player) error {
+	game := webSession.Session.Game
 	if game.Stage() != engine.StageLobby {
-		return nil, GameErrorf("Game has already started")
+		return GameErrorf("Game has already started")
 	}
 
-	if player != CreatorPlayerID {
-		return nil, GameErrorf("Only the Host can start the game")
+	if player != webSession.Host {
+		return GameErrorf("Only the Host can start the game")
 	}
 
-	session.Game.ProgressStage()
-	return templates.RenderToBytes("gameScreen", session.Game)
+	game.ProgressStage()
+	return nil
 }
 
-func shiftWith(shiftFunc shiftFunction, session auth.GameSession, player engine.Player, index int) ([]byte, error) {
-	game := session.Game
+// moveError converts an engine error into an error that can be shown to the player
+func moveError(err error) error {
+	switch {
+	case errors.Is(err, engine.ErrorNotYourTurn):
+		return GameErrorNotYourTurn
+	case errors.Is(err, engine.ErrorNotPlayingStage):
+		return GameErrorf("The game is not in play yet")
+	case errors.Is(err, engine.ErrorNotInitStage):
+		return GameErrorf("Putting new tiles is allowed only in the init stage")
+	case errors.Is(err, engine.ErrorTileOccupied):
+		return GameErrorf("Tile is already occupied by another player")
+	}
 
-	if game.Stage() != engine.StatePlaying {
-		return nil, GameErrorf("The game is not in play yet")
+	return ErrorBadRequest
+}
+
+func shift(session auth.GameSession, player engine.Player, direction engine.Direction, index int) error {
+	if err := session.Game.Play(player, engine.ShiftMove(direction, index)); err != nil {
+		return moveError(err)
 	}
 
-	if game.CurrentPlayer() != player {
-		return nil, GameErrorNotYourTurn
+	return nil
+}
+
+func putTile(session auth.GameSession, player engine.Player, row, col int) error {
+	if err := session.Game.Play(player, engine.PutMove(row, col)); err != nil {
+		return moveError(err)
 	}
 
-	if err := shiftFunc(game.Board, index); err != nil {
-		return nil, ErrorBadRequest
+	return nil
+}
+
+func parseTimeControl(formValue func(string) string) (clock.TimeControl, error) {
+	var timeControl clock.TimeControl
+
+	switch formValue("timeControl") {
+	case "", "none":
+		return timeControl, nil
+	case "move":
+		timeControl.Mode = clock.ModePerMove
+	case "bank":
+		timeControl.Mode = clock.ModeBank
+	default:
+		return timeControl, errors.New("Unknown time control")
 	}
 
-	game.NextPlayer()
+	limit, err := strconv.Atoi(formValue("timeLimit"))
+	if err != nil || limit <= 0 || time.Duration(limit)*time.Second > TIME_CONTROL_LIMIT_MAX {
+		return timeControl, errors.New("Time limit must be a positive number of seconds, up to a day")
+	}
+	timeControl.Limit = time.Duration(limit) * time.Second
 
-	if _, err := game.Winner(); err == nil {
-		game.ProgressStage()
+	if timeControl.Mode == clock.ModeBank && formValue("timeIncrement") != "" {
+		increment, err := strconv.Atoi(formValue("timeIncrement"))
+		if err != nil || increment < 0 || time.Duration(increment)*time.Second > TIME_CONTROL_INCREMENT_MAX {
+			return timeControl, errors.New("Increment must be a number of seconds, up to 10 minutes")
+		}
+		timeControl.Increment = time.Duration(increment) * time.Second
+	}
+
+	switch formValue("onTimeout") {
+	case "", "pass":
+		timeControl.OnTimeout = clock.TimeoutPass
+	case "autoplay":
+		timeControl.OnTimeout = clock.TimeoutAutoPlay
+	case "eliminate":
+		timeControl.OnTimeout = clock.TimeoutEliminate
+	default:
+		return timeControl, errors.New("Unknown timeout action")
 	}
 
-	return templates.RenderToBytes("gameScreen", game)
+	return timeControl, nil
 }
 
-func putTile(session auth.GameSession, player engine.Player, row, col int) ([]byte, error) {
+type JoinFormView struct {
+	GameId  string
+	Profile auth.PlayerProfile
+	Error   string
 
-	if session.Game.Stage() != engine.StageInit {
-		return nil, GameErrorf("Putting new tiles is allowed only in the init stage")
+	// BoardImage is the absolute URL of the image of the board, used for the
+	// preview of the invite link
+	BoardImage string
+}
+
+func (v JoinFormView) OpenGraph() OpenGraph {
+	return OpenGraph{
+		Title:       "Join a game of Shifter",
+		Description: "You have been invited to a game of Shifter",
+		Image:       v.BoardImage,
 	}
+}
+
+// newGameSession creates a game with its creator waiting in the lobby
+func newGameSession(size int, profile auth.PlayerProfile, username string) (auth.GameSession, error) {
+	nonce, err := auth.GenerateNonce(NonceBitLength)
+	if err != nil {
+		return auth.GameSession{}, err
+	}
+
+	game := engine.NewGame(engine.NewBoard(size, size))
+	game.AddPlayers(CreatorPlayerID)
+
+	session := auth.NewGameSession(&game, nonce)
+	session.SetProfile(CreatorPlayerID, profile)
+	if username != "" {
+		session.SetAccount(CreatorPlayerID, username)
+	}
+
+	return session, nil
+}
 
+// joinPlayer adds the player to the lobby of the game and shows them to the
+// other players. The caller must hold the SessionMutex.
+func (webSession *WebGameSession) joinPlayer(player engine.Player, profile auth.PlayerProfile, username string) error {
+	session := webSession.Session
 	game := session.Game
-	if game.CurrentPlayer() != player {
-		return nil, GameErrorNotYourTurn
+
+	if err := checkJoinable(game); err != nil {
+		return err
+	}
+	if err := webSession.requireAccountFor(username); err != nil {
+		return err
+	}
+	for _, other := range game.Players() {
+		if session.Profile(other).Color == profile.Color {
+			return errors.New("This color is already taken")
+		}
 	}
 
-	if err := game.Board.Put(row, col, player.ToTile()); err != nil {
-		if errors.Is(err, engine.ErrorTileOccupied) {
-			return nil, GameErrorf("Tile is already occupied by another player")
+	if err := game.AddPlayers(player); err != nil {
+		return err
+	}
+	session.SetProfile(player, profile)
+	if username != "" {
+		session.SetAccount(player, username)
+	}
+
+	if response, err := templates.RenderToBytes("gameScreen", NewGameView(webSession)); err == nil {
+		webSession.Broadcast(response)
+	}
+	lobby.Notify()
+
+	return nil
+}
+
+func checkJoinable(game *engine.Game) error {
+	if game.Stage() != engine.StageLobby {
+		return errors.New("Game has already started")
+	}
+
+	if game.PlayerCount() >= game.Board.MaxPlayerCount(engine.MinTilesPerPlayer) {
+		return errors.New("Game is full")
+	}
+
+	return nil
+}
+
+var colorRegex = regexp.MustCompile("^#[0-9a-fA-F]{6}$")
+
+// parseProfile reads the name and color fields, falling back to the default
+// profile of the player for the empty ones.
+func parseProfile(formValue func(string) string, player engine.Player) (auth.PlayerProfile, error) {
+	profile, err := parseProfileFields(formValue)
+	if err != nil {
+		return profile, err
+	}
+
+	return withDefaultProfile(profile, player), nil
+}
+
+// parseProfileFields reads the name and color fields, leaving the empty ones empty
+func parseProfileFields(formValue func(string) string) (auth.PlayerProfile, error) {
+	var profile auth.PlayerProfile
+
+	if name := strings.TrimSpace(formValue("name")); name != "" {
+		if utf8.RuneCountInString(name) > PLAYER_NAME_MAX_LENGTH {
+			return profile, fmt.Errorf("Name cannot be longer than %d characters", PLAYER_NAME_MAX_LENGTH)
+		}
+		profile.Name = name
+	}
+
+	if color := formValue("color"); color != "" {
+		if !colorRegex.MatchString(color) {
+			return profile, errors.New("Invalid color")
+		}
+		profile.Color = strings.ToLower(color)
+	}
+
+	return profile, nil
+}
+
+func withDefaultProfile(profile auth.PlayerProfile, player engine.Player) auth.PlayerProfile {
+	defaultProfile := auth.DefaultProfile(player)
+	if profile.Name == "" {
+		profile.Name = defaultProfile.Name
+	}
+	if profile.Color == "" {
+		profile.Color = defaultProfile.Color
+	}
+
+	return profile
+}
+
+// freeDefaultProfile is the default profile of the player, with the first
+// default color which no other player of the game uses yet. Players without
+// a profile of their own count as using their default color. The caller must
+// hold the SessionMutex.
+func (webSession *WebGameSession) freeDefaultProfile(player engine.Player) auth.PlayerProfile {
+	session := webSession.Session
+	taken := map[string]bool{}
+	for _, other := range session.Game.Players() {
+		if other != player {
+			taken[session.Profile(other).Color] = true
 		}
+	}
+
+	profile := auth.DefaultProfile(player)
+	for candidate := player; candidate < player+engine.Player(len(auth.DefaultColors)); candidate++ {
+		if color := auth.DefaultProfile(candidate).Color; !taken[color] {
+			profile.Color = color
+			break
+		}
+	}
+
+	return profile
+}
+
+// sendGameScreen sends the whole game screen to a browser which just
+// connected, with the clocks and the chat log
+func sendGameScreen(ws *websocket.Conn, webSession *WebGameSession, player engine.Player) error {
+	webSession.SessionMutex.RLock()
+	boardHTML, err := templates.RenderToBytes("gameScreen", NewGameView(webSession))
+	if err == nil {
+		var clocks []byte
+		clocks, err = webSession.renderClocks(time.Now())
+		boardHTML = append(boardHTML, clocks...)
+	}
+	webSession.SessionMutex.RUnlock()
+	if err == nil {
+		var chatLog []byte
+		chatLog, err = templates.RenderToBytes("chatLog", webSession.Chat.LogFor(player))
+		boardHTML = append(boardHTML, chatLog...)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ws.WriteMessage(websocket.TextMessage, boardHTML)
+}
+
+// sendErrorPopup shows the message to a browser in an error popup
+func sendErrorPopup(ws *websocket.Conn, message string) error {
+	errorPopup, err := templates.RenderToBytes("errorPopup", message)
+	if err != nil {
+		return err
+	}
+
+	return ws.WriteMessage(websocket.TextMessage, errorPopup)
+}
+
+// gamePage shows the game of the token in the game cookie, or sends the
+// player to create a new game when there is none
+func gamePage(c echo.Context) error {
+	cookie, err := c.Cookie(SessionCookieName)
+	if err != nil {
+		return c.Redirect(http.StatusFound, "/new")
+	}
+
+	webSession, err := games.GetWebSessionForToken(cookie.Value)
+	if err != nil {
+		return c.Redirect(http.StatusFound, "/new")
+	}
+	session := webSession.Session
+	game := session.Game
+	if game == nil {
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
-		return nil, ErrorBadRequest
+	player, err := session.ExtractPlayerFromToken(cookie.Value)
+	if err != nil {
+		return c.Redirect(http.StatusFound, "/new")
 	}
 
-	playerCount := game.PlayerCount()
+	webSession.SessionMutex.RLock()
+	defer webSession.SessionMutex.RUnlock()
 
-	nonEmptyTileCount := game.Board.CountNonEmptyTiles()
-	fullBoardNonEmptyTiles := playerCount * game.Board.TilesPerPlayerWhen(playerCount)
+	if !webSession.IsMember(player) {
+		return c.Redirect(http.StatusFound, "/new")
+	}
 
-	if nonEmptyTileCount == fullBoardNonEmptyTiles {
-		game.ProgressStage()
+	if auth.ShouldRefreshToken(cookie.Value) {
+		if token, err := session.RefreshToken(cookie.Value); err == nil {
+			setGameCookie(c, token)
+		}
+	} else {
+		rememberGameToken(c, cookie.Value)
 	}
 
-	game.NextPlayer()
+	return c.Render(http.StatusOK, "index", NewGameView(webSession))
+}
 
-	return templates.RenderToBytes("board", game)
+// configureSigning picks the token signing key from the environment.
+// CADERE_SIGNING_KEY_FILE is a PEM private key (Ed25519 or RSA), and
+// CADERE_SIGNING_ALG (EdDSA or RS256) generates a key on startup. Without
+// either, tokens are signed with a process-local HMAC secret.
+func configureSigning() error {
+	if keyFile := os.Getenv("CADERE_SIGNING_KEY_FILE"); keyFile != "" {
+		pemBytes, err := os.ReadFile(keyFile)
+		if err != nil {
+			return err
+		}
+		return auth.UseSigningKeyFromPEM(pemBytes)
+	}
+
+	if algorithm := os.Getenv("CADERE_SIGNING_ALG"); algorithm != "" {
+		return auth.GenerateSigningKey(algorithm)
+	}
+
+	return nil
 }
 
 var upgrader = websocket.Upgrader{}
@@ -354,6 +1235,36 @@ func main() {
 
 	e.Renderer = templates
 
+	if err := configureSigning(); err != nil {
+		e.Logger.Fatal(err)
+	}
+
+	var err error
+	accountStore, err = accounts.NewStore(dataPath("accounts.json"), dataPath("logins.json"))
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	ratingStore, err = rating.NewStore(dataPath("ratings.json"))
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	archiveStore, err = archive.NewStore(dataPath("games.jsonl"))
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	archiveLogger = e.Logger
+	tournamentStore, err = tournament.NewStore(dataPath("tournaments.json"))
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	puzzleStore, err = puzzle.NewStore(dataPath("puzzles.json"))
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	if err := loadPuzzles(e.Logger); err != nil {
+		e.Logger.Fatal(err)
+	}
+
 	e.Static("/css", "css")
 
 	e.GET("/play", func(c echo.Context) error {
@@ -363,6 +1274,14 @@ func main() {
 		}
 		defer ws.Close()
 
+		// Clients which accept JSON get messages of the API instead of HTML
+		var jsonClient *jsonConn
+		var conn GameConn = ws
+		if wantsJSON(c) {
+			jsonClient = &jsonConn{ws: ws}
+			conn = jsonClient
+		}
+
 		_, cookieBytes, err := ws.ReadMessage()
 		if err != nil {
 			return err
@@ -382,91 +1301,88 @@ func main() {
 			return err
 		}
 
-		boardHTML, err := templates.RenderToBytes("gameScreen", session.Game)
-		if err != nil {
-			return err
+		webSession.SessionMutex.RLock()
+		isMember := webSession.IsMember(player)
+		webSession.SessionMutex.RUnlock()
+		if !isMember {
+			ws.WriteMessage(websocket.CloseMessage, WebsocketCloseInvalidToken)
+			return nil
+		}
+
+		if jsonClient != nil {
+			err = jsonClient.send(api.GameMessage{Type: api.MessageUpdate})
+		} else {
+			err = sendGameScreen(ws, webSession, player)
 		}
-		err = ws.WriteMessage(websocket.TextMessage, boardHTML)
 		if err != nil {
 			return err
 		}
 
-		webSession.AddSocket(ws)
-		defer webSession.RemoveSocket(ws)
+		gameSocket := &GameSocket{GameConn: conn, Player: player}
+		webSession.AddSocket(gameSocket)
+		defer webSession.HandleDisconnect(player)
+		defer webSession.RemoveSocket(gameSocket)
 
 		for {
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return nil
+			}
 
 			var action GameAction
-			err := ws.ReadJSON(&action)
-			if err != nil {
+			if err := json.Unmarshal(message, &action); err != nil {
+				continue
+			}
+			if err := json.Unmarshal(message, &action.Form); err != nil {
 				continue
 			}
 
-			response, err := webSession.ExecuteAction(action, player)
-			if err != nil {
+			if err := webSession.HandleAction(action, player); err != nil {
 				if errors.Is(err, ErrorBadRequest) {
 					continue
 				}
 
-				var errorPopup []byte
-				var renderErr error
-
-				switch {
-				case errors.Is(err, ErrorBadRequest):
-					continue
-				case errors.As(err, &GameError{}):
-					errorPopup, renderErr = templates.RenderToBytes("errorPopup", err.Error())
-				default:
-					errorPopup, renderErr = templates.RenderToBytes("errorPopup", "Something went wrong")
-				}
-				if renderErr != nil {
-					return renderErr
+				message := "Something went wrong"
+				if errors.As(err, &GameError{}) {
+					message = err.Error()
 				}
 
-				if err := ws.WriteMessage(websocket.TextMessage, errorPopup); err != nil {
+				if jsonClient != nil {
+					err = jsonClient.send(api.GameMessage{Type: api.MessageError, Message: message})
+				} else {
+					err = sendErrorPopup(ws, message)
+				}
+				if err != nil {
 					return err
 				}
-				continue
 			}
+		}
+	})
 
-			webSession.SetLastActionTimestamp(time.Now().Unix())
-
-			webSession.FilterForEach(func(conn *websocket.Conn) bool {
-				if err = conn.WriteMessage(websocket.TextMessage, response); err == websocket.ErrCloseSent {
-					return false
-				}
+	e.GET("/", gamePage)
 
-				return true
-			})
-		}
+	e.GET("/.well-known/jwks.json", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, auth.PublicKeys())
 	})
 
-	e.GET("/", func(c echo.Context) error {
+	e.POST("/refresh", func(c echo.Context) error {
 		cookie, err := c.Cookie(SessionCookieName)
 		if err != nil {
-			return c.Redirect(http.StatusFound, "/new")
+			return c.NoContent(http.StatusUnauthorized)
 		}
 
 		webSession, err := games.GetWebSessionForToken(cookie.Value)
 		if err != nil {
-			return c.Redirect(http.StatusFound, "/new")
-		}
-		session := webSession.Session
-		game := session.Game
-		if game == nil {
-			return c.NoContent(http.StatusInternalServerError)
+			return c.NoContent(http.StatusUnauthorized)
 		}
 
-		player, err := session.ExtractPlayerFromToken(cookie.Value)
+		token, err := webSession.Session.RefreshToken(cookie.Value)
 		if err != nil {
-			return c.Redirect(http.StatusFound, "/new")
+			return c.NoContent(http.StatusUnauthorized)
 		}
 
-		if !game.PlayerExists(player) {
-			return c.NoContent(http.StatusInternalServerError)
-		}
-
-		return c.Render(http.StatusOK, "index", session)
+		setGameCookie(c, token)
+		return c.String(http.StatusOK, token)
 	})
 
 	e.GET("/new", func(c echo.Context) error {
@@ -474,36 +1390,69 @@ func main() {
 	})
 
 	e.POST("/new", func(c echo.Context) error {
+		renderNewForm := func(message string) error {
+			if wantsJSON(c) {
+				return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": message})
+			}
+			return c.Render(http.StatusUnprocessableEntity, "newForm", message)
+		}
+
 		size, err := strconv.Atoi(c.FormValue("size"))
 		if err != nil {
-			return c.Render(http.StatusUnprocessableEntity, "newForm", "The entered value is not a number")
+			return renderNewForm("The entered value is not a number")
 		}
 		if err != nil || size < GAME_SIZE_MIN || size > GAME_SIZE_MAX {
-			return c.Render(http.StatusUnprocessableEntity, "newForm", "Board cannot be smaller than 2 or larger than 100")
+			return renderNewForm("Board cannot be smaller than 2 or larger than 100")
 		}
 
-		nonce, err := auth.GenerateNonce(NonceBitLength)
+		timeControl, err := parseTimeControl(c.FormValue)
 		if err != nil {
-			return c.NoContent(http.StatusInternalServerError)
+			return renderNewForm(err.Error())
 		}
 
-		game := engine.NewGame(engine.NewBoard(size, size))
-		game.AddPlayers(CreatorPlayerID)
+		profile, username, err := parseAccountProfile(c, CreatorPlayerID)
+		if err != nil {
+			return renderNewForm(err.Error())
+		}
 
-		session := auth.NewGameSession(&game, nonce)
+		rated := c.FormValue("rated") != ""
+		if rated && username == "" {
+			return renderNewForm("Log in to create a rated game")
+		}
 
-		token, err := session.NewTokenForPlayer(CreatorPlayerID)
+		session, err := newGameSession(size, profile, username)
 		if err != nil {
 			return c.NoContent(http.StatusInternalServerError)
 		}
 
-		c.SetCookie(&http.Cookie{
-			Name:  SessionCookieName,
-			Value: token,
-		})
+		token, err := session.NewTokenWithRole(CreatorPlayerID, auth.RoleHost)
+		if err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
 
-		games.AddSession(session)
+		setGameCookie(c, token)
+
+		webSession := games.AddSession(session, timeControl)
+		if rated {
+			webSession.SessionMutex.Lock()
+			webSession.Rated = true
+			webSession.SessionMutex.Unlock()
+		} else if c.FormValue("hints") != "" {
+			webSession.SessionMutex.Lock()
+			webSession.Hints = true
+			webSession.SessionMutex.Unlock()
+		}
+		if c.FormValue("public") != "" {
+			webSession.SessionMutex.Lock()
+			webSession.Public = true
+			webSession.SessionMutex.Unlock()
+
+			lobby.Notify()
+		}
 
+		if wantsJSON(c) {
+			return c.JSON(http.StatusOK, api.GameToken{Token: token})
+		}
 		c.Response().Header().Set("HX-Redirect", "/")
 		return c.NoContent(http.StatusOK)
 	})
@@ -515,46 +1464,107 @@ func main() {
 		if !ok {
 			return c.NoContent(http.StatusNotFound)
 		}
+
+		webSession.SessionMutex.RLock()
+		defer webSession.SessionMutex.RUnlock()
+
+		game := webSession.Session.Game
+		if err := checkJoinable(game); err != nil {
+			return c.Render(http.StatusUnprocessableEntity, "errorGameFull", err.Error())
+		}
+
+		nextPlayer := webSession.nextPlayerID()
+		return c.Render(http.StatusOK, "join", JoinFormView{
+			GameId:     gameId,
+			Profile:    auth.DefaultProfile(nextPlayer),
+			BoardImage: absoluteUrl(c, "/game/"+gameUrlId(gameId)+"/board.png"),
+		})
+	})
+
+	e.POST("/join", func(c echo.Context) error {
+		gameId := c.FormValue("gameId")
+
+		webSession, ok := games.Get(gameId)
+		if !ok {
+			if wantsJSON(c) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "no such game"})
+			}
+			return c.NoContent(http.StatusNotFound)
+		}
 		session := webSession.Session
 		game := session.Game
 		if game == nil {
 			return c.NoContent(http.StatusInternalServerError)
 		}
 
-		if game.Stage() != engine.StageLobby {
-			return c.Render(http.StatusUnprocessableEntity, "errorGameFull", "Game has already started")
-		}
+		webSession.SessionMutex.Lock()
+		defer webSession.SessionMutex.Unlock()
 
-		if game.PlayerCount() > game.Board.MaxPlayerCount(engine.MinTilesPerPlayer) {
-			return c.Render(http.StatusUnprocessableEntity, "errorGameFull", "Game is full")
+		player := webSession.nextPlayerID()
+		renderJoinForm := func(message string) error {
+			if wantsJSON(c) {
+				return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": message})
+			}
+			return c.Render(http.StatusUnprocessableEntity, "joinForm", JoinFormView{
+				GameId:  gameId,
+				Profile: auth.DefaultProfile(player),
+				Error:   message,
+			})
 		}
 
-		player := engine.Player(game.PlayerCount() + 1)
+		if err := checkJoinable(game); err != nil {
+			return renderJoinForm(err.Error())
+		}
 
-		token, err := session.NewTokenForPlayer(player)
+		profile, username, err := parseAccountProfile(c, player)
 		if err != nil {
-			return c.NoContent(http.StatusInternalServerError)
+			return renderJoinForm(err.Error())
+		}
+		if err := webSession.joinPlayer(player, profile, username); err != nil {
+			return renderJoinForm(err.Error())
 		}
 
-		err = game.AddPlayers(player)
+		token, err := session.NewTokenForPlayer(player)
 		if err != nil {
 			return c.NoContent(http.StatusInternalServerError)
 		}
 
-		cookie := &http.Cookie{
-			Name:  SessionCookieName,
-			Value: token,
-		}
-		c.SetCookie(cookie)
+		setGameCookie(c, token)
 
-		return c.Redirect(http.StatusFound, "/")
+		if wantsJSON(c) {
+			return c.JSON(http.StatusOK, api.GameToken{Token: token})
+		}
+		c.Response().Header().Set("HX-Redirect", "/")
+		return c.NoContent(http.StatusOK)
 	})
 
+	registerLobbyRoutes(e)
+	registerQuickPlayRoutes(e)
+	registerMyGamesRoutes(e)
+	registerAccountRoutes(e)
+	registerRatingRoutes(e)
+	registerProfileRoutes(e)
+	registerReplayRoutes(e)
+	registerBoardImageRoutes(e)
+	registerGameApiRoutes(e)
+	registerBotApiRoutes(e)
+	registerTournamentRoutes(e)
+	registerPuzzleRoutes(e)
+
 	e.GET("/spectate", func(c echo.Context) error {
 		return c.Render(http.StatusNotImplemented, "errorPage", "Error: Not implemented")
 	})
 
 	go games.CleanupStaleGamesEvery(time.Minute)
+	go games.EnforceClocksEvery(time.Second)
+	go lobby.Run()
+	go matchmaker.OfferBotsEvery(5 * time.Second)
+	go tokenStore.PruneEvery(time.Minute)
+	go advanceTournamentsEvery(2 * time.Second)
+
+	if err := startSSHServer(e.Logger); err != nil {
+		e.Logger.Fatal(err)
+	}
 
 	e.Logger.Fatal(e.Start(":8080"))
 }
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/clock"
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/tui"
+	"github.com/Denloob/cadere/util"
+)
+
+const (
+	// SSH_GUEST_USER is the user to connect as for the default display name.
+	// Any other username becomes the display name.
+	SSH_GUEST_USER = "play"
+
+	SSH_DEFAULT_GAME_SIZE = 6
+)
+
+const sshGameHelp = "Arrows/hjkl: move  Enter/Space: play  s: start  R: resign  q: back to the lobby"
+const sshLobbyHelp = "Up/Down: select  Enter: join  n: new game  +/-: board size  q: quit"
+
+// startSSHServer starts the SSH frontend if CADERE_SSH_ADDR is set. The host
+// key is read from CADERE_SSH_HOST_KEY_FILE, or generated into the data
+// directory on the first start.
+func startSSHServer(logger echo.Logger) error {
+	addr := os.Getenv("CADERE_SSH_ADDR")
+	if addr == "" {
+		return nil
+	}
+
+	hostKeyPath := os.Getenv("CADERE_SSH_HOST_KEY_FILE")
+	if hostKeyPath == "" {
+		hostKeyPath = dataPath("ssh_host_key")
+	}
+
+	hostKey, err := loadSSHHostKey(hostKeyPath)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostKey)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				logger.Error(err)
+				return
+			}
+
+			go handleSSHConn(conn, config)
+		}
+	}()
+
+	return nil
+}
+
+// loadSSHHostKey reads the PEM host key at path, generating an Ed25519 key
+// there if it doesn't exist yet
+func loadSSHHostKey(path string) (ssh.Signer, error) {
+	pemBytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		pemBytes = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := util.WriteFileAtomic(path, pemBytes); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePrivateKey(pemBytes)
+}
+
+func handleSSHConn(netConn net.Conn, config *ssh.ServerConfig) {
+	serverConn, channels, requests, err := ssh.NewServerConn(netConn, config)
+	if err != nil {
+		netConn.Close()
+		return
+	}
+	defer serverConn.Close()
+
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go handleSSHSession(channel, channelRequests, serverConn.User())
+	}
+}
+
+// handleSSHSession runs the terminal interface once the client asks for a
+// shell. The client's terminal is already in raw mode, so the keys arrive as
+// they are pressed.
+func handleSSHSession(channel ssh.Channel, requests <-chan *ssh.Request, user string) {
+	started := false
+	for request := range requests {
+		switch request.Type {
+		case "pty-req", "window-change", "env":
+			request.Reply(true, nil)
+		case "shell":
+			request.Reply(!started, nil)
+			if started {
+				continue
+			}
+			started = true
+
+			go func() {
+				newSSHTerminal(channel, user).run()
+
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				channel.Close()
+			}()
+		default:
+			request.Reply(false, nil)
+		}
+	}
+}
+
+// sshTerminal is the lobby and game screens of an SSH session
+type sshTerminal struct {
+	channel ssh.Channel
+	keys    chan tui.Key
+
+	// name is the display name, empty for the default one
+	name string
+}
+
+func newSSHTerminal(channel ssh.Channel, user string) *sshTerminal {
+	name := strings.TrimSpace(user)
+	if name == SSH_GUEST_USER {
+		name = ""
+	}
+	for utf8.RuneCountInString(name) > PLAYER_NAME_MAX_LENGTH {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+
+	return &sshTerminal{
+		channel: channel,
+		keys:    make(chan tui.Key),
+		name:    name,
+	}
+}
+
+func (t *sshTerminal) run() {
+	go func() {
+		defer close(t.keys)
+
+		reader := bufio.NewReader(t.channel)
+		for {
+			key, err := tui.ReadKey(reader)
+			if err != nil {
+				return
+			}
+			t.keys <- key
+		}
+	}()
+
+	t.channel.Write([]byte(tui.HideCursor))
+	defer t.channel.Write([]byte(tui.ShowCursor + tui.ClearScreen))
+
+	status := ""
+	for {
+		webSession, player, ok := t.lobby(status)
+		if !ok {
+			return
+		}
+
+		status, ok = t.play(webSession, player)
+		if !ok {
+			return
+		}
+	}
+}
+
+// profile is the given default profile, with the display name of the
+// terminal if it has one
+func (t *sshTerminal) profile(profile auth.PlayerProfile) auth.PlayerProfile {
+	if t.name != "" {
+		profile.Name = t.name
+	}
+
+	return profile
+}
+
+func (t *sshTerminal) draw(screen *bytes.Buffer) {
+	t.channel.Write(screen.Bytes())
+}
+
+// lobby lists the public games until the player joins or creates one.
+// Returns false when the player quits.
+func (t *sshTerminal) lobby(status string) (*WebGameSession, engine.Player, bool) {
+	conn := newNotifyConn()
+	lobby.addSocket(conn)
+	defer lobby.removeSocket(conn)
+
+	selected := 0
+	size := SSH_DEFAULT_GAME_SIZE
+	for {
+		publicGames := games.PublicGames()
+		selected = max(0, min(selected, len(publicGames)-1))
+
+		t.drawLobby(publicGames, selected, size, status)
+
+		var key tui.Key
+		select {
+		case <-conn.updates:
+			continue
+		case pressed, ok := <-t.keys:
+			if !ok {
+				return nil, 0, false
+			}
+			key = pressed
+		}
+
+		status = ""
+		direction, isDirection := key.Direction()
+		switch {
+		case key.Code == tui.KeyInterrupt, key == tui.Key{Code: tui.KeyRune, Rune: 'q'}:
+			return nil, 0, false
+		case isDirection && direction == engine.DirectionUp:
+			selected--
+		case isDirection && direction == engine.DirectionDown:
+			selected++
+		case key == tui.Key{Code: tui.KeyRune, Rune: '+'}:
+			size = min(GAME_SIZE_MAX, size+1)
+		case key == tui.Key{Code: tui.KeyRune, Rune: '-'}:
+			size = max(GAME_SIZE_MIN, size-1)
+		case key == tui.Key{Code: tui.KeyRune, Rune: 'n'}:
+			webSession, err := t.createGame(size)
+			if err != nil {
+				status = "The game could not be created"
+				continue
+			}
+			return webSession, CreatorPlayerID, true
+		case key.Code == tui.KeyEnter && len(publicGames) > 0:
+			webSession, player, err := t.joinGame(publicGames[selected].Nonce)
+			if err != nil {
+				status = err.Error()
+				continue
+			}
+			return webSession, player, true
+		}
+	}
+}
+
+func (t *sshTerminal) drawLobby(publicGames []LobbyGame, selected, size int, status string) {
+	var screen bytes.Buffer
+	screen.WriteString(tui.ClearScreen + "Shifter: public games" + tui.Newline + tui.Newline)
+
+	if len(publicGames) == 0 {
+		screen.WriteString("  No public games are waiting for players." + tui.Newline)
+	}
+	for i, publicGame := range publicGames {
+		marker := "  "
+		if i == selected {
+			marker = "> "
+		}
+
+		mode := ""
+		if publicGame.Rated {
+			mode = "  rated"
+		}
+
+		fmt.Fprintf(&screen, "%s%dx%d  %d/%d players%s%s", marker,
+			publicGame.Width, publicGame.Height, publicGame.PlayerCount, publicGame.MaxPlayerCount, mode, tui.Newline)
+	}
+
+	fmt.Fprintf(&screen, "%sNew game size: %dx%d%s", tui.Newline, size, size, tui.Newline)
+	if status != "" {
+		screen.WriteString(status + tui.Newline)
+	}
+	screen.WriteString(tui.Newline + sshLobbyHelp + tui.Newline)
+
+	t.draw(&screen)
+}
+
+// createGame creates a public game hosted by the player
+func (t *sshTerminal) createGame(size int) (*WebGameSession, error) {
+	session, err := newGameSession(size, t.profile(auth.DefaultProfile(CreatorPlayerID)), "")
+	if err != nil {
+		return nil, err
+	}
+
+	webSession := games.AddSession(session, clock.TimeControl{})
+	webSession.SessionMutex.Lock()
+	webSession.Public = true
+	webSession.SessionMutex.Unlock()
+
+	lobby.Notify()
+	return webSession, nil
+}
+
+func (t *sshTerminal) joinGame(nonce string) (*WebGameSession, engine.Player, error) {
+	webSession, ok := games.Get(nonce)
+	if !ok {
+		return nil, 0, errors.New("The game no longer exists")
+	}
+
+	webSession.SessionMutex.Lock()
+	defer webSession.SessionMutex.Unlock()
+
+	player := webSession.nextPlayerID()
+	if err := webSession.joinPlayer(player, t.profile(webSession.freeDefaultProfile(player)), ""); err != nil {
+		return nil, 0, err
+	}
+
+	return webSession, player, nil
+}
+
+// play shows the game until the player goes back to the lobby or is removed
+// from the game. Returns the message to show in the lobby, and false when
+// the player disconnected.
+func (t *sshTerminal) play(webSession *WebGameSession, player engine.Player) (string, bool) {
+	conn := newNotifyConn()
+	socket := &GameSocket{GameConn: conn, Player: player}
+	webSession.AddSocket(socket)
+	defer webSession.HandleDisconnect(player)
+	defer webSession.RemoveSocket(socket)
+
+	cursor := tui.Cursor{}
+	status := ""
+	for {
+		webSession.SessionMutex.RLock()
+		kicked := webSession.Kicked[player]
+		state := webSession.gameState(player)
+		webSession.SessionMutex.RUnlock()
+
+		// Players who resigned or ran out of time keep watching the game
+		// until they go back to the lobby
+		if kicked {
+			return "You were removed from the game", true
+		}
+
+		var screen bytes.Buffer
+		tui.DrawGame(&screen, state, cursor, status)
+		screen.WriteString(tui.Newline + sshGameHelp + tui.Newline)
+		t.draw(&screen)
+
+		var key tui.Key
+		select {
+		case <-conn.updates:
+			continue
+		case <-conn.closed:
+			return "The game was closed", true
+		case pressed, ok := <-t.keys:
+			if !ok {
+				return "", false
+			}
+			key = pressed
+		}
+
+		status = ""
+		height, width := len(state.Board), len(state.Board[0])
+		if direction, ok := key.Direction(); ok {
+			cursor = cursor.Move(direction, width, height)
+			continue
+		}
+
+		var action GameAction
+		switch {
+		case key.Code == tui.KeyInterrupt, key == tui.Key{Code: tui.KeyRune, Rune: 'q'}:
+			return "", true
+		case key.Code == tui.KeyEnter, key == tui.Key{Code: tui.KeyRune, Rune: ' '}:
+			move, ok := cursor.Action(width, height)
+			if !ok {
+				continue
+			}
+			if move.Kind == engine.MovePut {
+				action = GameAction{Action: "put", Row: move.Row, Col: move.Col}
+			} else {
+				action = GameAction{Action: "shift", Direction: move.Direction.String(), Index: move.Index}
+			}
+		case key == tui.Key{Code: tui.KeyRune, Rune: 's'}:
+			action = GameAction{Action: "start"}
+		case key == tui.Key{Code: tui.KeyRune, Rune: 'R'}:
+			action = GameAction{Action: "resign"}
+		default:
+			continue
+		}
+
+		if err := webSession.HandleAction(action, player); err != nil {
+			status = sshActionError(err)
+		}
+	}
+}
+
+// sshActionError returns the message to show for an error of an action, like
+// the error popups of the browsers
+func sshActionError(err error) string {
+	switch {
+	case errors.Is(err, ErrorBadRequest):
+		return ""
+	case errors.As(err, &GameError{}):
+		return err.Error()
+	}
+
+	return "Something went wrong"
+}
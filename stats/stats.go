@@ -0,0 +1,230 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Denloob/cadere/archive"
+	"github.com/Denloob/cadere/engine"
+)
+
+// FavoriteOpeningsCount is how many of the most common openings are reported
+const FavoriteOpeningsCount = 3
+
+type Tally struct {
+	Games  int `json:"games"`
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+}
+
+// WinRate returns the share of the games which were won, between 0 and 1
+func (t Tally) WinRate() float64 {
+	if t.Games == 0 {
+		return 0
+	}
+
+	return float64(t.Wins) / float64(t.Games)
+}
+
+func (t *Tally) add(record archive.Record, player engine.Player) {
+	t.Games++
+	switch {
+	case record.Draw:
+		t.Draws++
+	case record.Winner == player:
+		t.Wins++
+	default:
+		t.Losses++
+	}
+}
+
+type SizeTally struct {
+	Size string `json:"size"`
+	Tally
+}
+
+// SeatTally counts the games played from a seat, where seat 1 moved first
+type SeatTally struct {
+	Seat int `json:"seat"`
+	Tally
+}
+
+// Opening is the first tile a player placed
+type Opening struct {
+	Size  string `json:"size"`
+	Row   int    `json:"row"`
+	Col   int    `json:"col"`
+	Count int    `json:"count"`
+}
+
+type Stats struct {
+	Username string `json:"username"`
+	Tally
+
+	BySize []SizeTally `json:"bySize"`
+	BySeat []SeatTally `json:"bySeat"`
+
+	// AverageTilesEliminated is how many tiles of other players the player
+	// pushed off the board per game
+	AverageTilesEliminated float64   `json:"averageTilesEliminated"`
+	AverageMoves           float64   `json:"averageMoves"`
+	AverageDurationSeconds float64   `json:"averageDurationSeconds"`
+	FavoriteOpenings       []Opening `json:"favoriteOpenings"`
+}
+
+func boardSize(record archive.Record) string {
+	return fmt.Sprintf("%dx%d", record.Width, record.Height)
+}
+
+// findPlayer returns the player the user played as, and their seat
+func findPlayer(record archive.Record, username string) (engine.Player, int, bool) {
+	for i, playerRecord := range record.Players {
+		if strings.EqualFold(playerRecord.Username, username) {
+			return playerRecord.Player, i + 1, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+func countTiles(board engine.Board, player engine.Player) (own int, others int) {
+	for _, row := range board {
+		for _, tile := range row {
+			switch {
+			case tile.IsEmpty():
+			case tile == player.ToTile():
+				own++
+			default:
+				others++
+			}
+		}
+	}
+
+	return own, others
+}
+
+// tilesEliminated counts the tiles of other players that the player pushed
+// off the board during the game
+func tilesEliminated(record archive.Record, player engine.Player) int {
+	positions, err := record.Replay()
+	if err != nil {
+		return 0
+	}
+
+	eliminated := 0
+	for i, event := range record.History {
+		if i+1 >= len(positions) {
+			break
+		}
+		if event.Kind != engine.EventMove || event.Player != player || event.Move.Kind != engine.MoveShift {
+			continue
+		}
+
+		_, before := countTiles(positions[i].Board, player)
+		_, after := countTiles(positions[i+1].Board, player)
+		eliminated += before - after
+	}
+
+	return eliminated
+}
+
+// Compute calculates the statistics of the user over their archived games
+func Compute(username string, records []archive.Record) Stats {
+	stats := Stats{
+		Username:         username,
+		BySize:           []SizeTally{},
+		BySeat:           []SeatTally{},
+		FavoriteOpenings: []Opening{},
+	}
+
+	bySize := make(map[string]*Tally)
+	bySeat := make(map[int]*Tally)
+	openings := make(map[Opening]int)
+
+	var totalEliminated, totalMoves int
+	var totalDuration float64
+	for _, record := range records {
+		player, seat, ok := findPlayer(record, username)
+		if !ok {
+			continue
+		}
+
+		stats.Tally.add(record, player)
+
+		size := boardSize(record)
+		if bySize[size] == nil {
+			bySize[size] = &Tally{}
+		}
+		bySize[size].add(record, player)
+
+		if bySeat[seat] == nil {
+			bySeat[seat] = &Tally{}
+		}
+		bySeat[seat].add(record, player)
+
+		opened := false
+		for _, event := range record.History {
+			if event.Kind != engine.EventMove {
+				continue
+			}
+			totalMoves++
+
+			if !opened && event.Player == player && event.Move.Kind == engine.MovePut {
+				openings[Opening{Size: size, Row: event.Move.Row, Col: event.Move.Col}]++
+				opened = true
+			}
+		}
+
+		totalEliminated += tilesEliminated(record, player)
+		totalDuration += record.Finished.Sub(record.Started).Seconds()
+	}
+
+	if stats.Games == 0 {
+		return stats
+	}
+
+	games := float64(stats.Games)
+	stats.AverageTilesEliminated = float64(totalEliminated) / games
+	stats.AverageMoves = float64(totalMoves) / games
+	stats.AverageDurationSeconds = totalDuration / games
+
+	for size, tally := range bySize {
+		stats.BySize = append(stats.BySize, SizeTally{Size: size, Tally: *tally})
+	}
+	sort.Slice(stats.BySize, func(i, j int) bool {
+		return stats.BySize[i].Games > stats.BySize[j].Games ||
+			stats.BySize[i].Games == stats.BySize[j].Games && stats.BySize[i].Size < stats.BySize[j].Size
+	})
+
+	for seat, tally := range bySeat {
+		stats.BySeat = append(stats.BySeat, SeatTally{Seat: seat, Tally: *tally})
+	}
+	sort.Slice(stats.BySeat, func(i, j int) bool {
+		return stats.BySeat[i].Seat < stats.BySeat[j].Seat
+	})
+
+	for opening, count := range openings {
+		opening.Count = count
+		stats.FavoriteOpenings = append(stats.FavoriteOpenings, opening)
+	}
+	sort.Slice(stats.FavoriteOpenings, func(i, j int) bool {
+		a, b := stats.FavoriteOpenings[i], stats.FavoriteOpenings[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Size != b.Size {
+			return a.Size < b.Size
+		}
+		if a.Row != b.Row {
+			return a.Row < b.Row
+		}
+		return a.Col < b.Col
+	})
+	if len(stats.FavoriteOpenings) > FavoriteOpeningsCount {
+		stats.FavoriteOpenings = stats.FavoriteOpenings[:FavoriteOpeningsCount]
+	}
+
+	return stats
+}
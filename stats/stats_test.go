@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Denloob/cadere/archive"
+	"github.com/Denloob/cadere/engine"
+)
+
+// playedRecord plays a game on a 4x1 board where both players push a tile of
+// player 1 off the board, and player 2 wins
+func playedRecord(t *testing.T, id string, players []archive.PlayerRecord) archive.Record {
+	game := engine.NewGame(engine.NewBoard(4, 1))
+	game.AddPlayers(1, 2)
+	game.ProgressStage()
+
+	assert.NoError(t, game.Play(1, engine.PutMove(0, 2)))
+	assert.NoError(t, game.Play(2, engine.PutMove(0, 0)))
+	assert.NoError(t, game.Play(1, engine.PutMove(0, 3)))
+	assert.NoError(t, game.Play(2, engine.PutMove(0, 1)))
+	assert.NoError(t, game.Play(1, engine.ShiftMove(engine.DirectionRight, 0)))
+	assert.NoError(t, game.Play(2, engine.ShiftMove(engine.DirectionRight, 0)))
+
+	winner, err := game.Winner()
+	assert.NoError(t, err)
+
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return archive.Record{
+		Id:       id,
+		Width:    4,
+		Height:   1,
+		Players:  players,
+		History:  game.History(),
+		Winner:   winner,
+		Started:  started,
+		Finished: started.Add(time.Minute),
+	}
+}
+
+func TestCompute(t *testing.T) {
+	records := []archive.Record{
+		playedRecord(t, "first", []archive.PlayerRecord{
+			{Player: 1, Username: "alice"},
+			{Player: 2, Username: "bob"},
+		}),
+		playedRecord(t, "second", []archive.PlayerRecord{
+			{Player: 1, Username: "bob"},
+			{Player: 2, Username: "Alice"},
+		}),
+		{Id: "draw", Width: 8, Height: 8, Draw: true, Players: []archive.PlayerRecord{
+			{Player: 1, Username: "carol"},
+			{Player: 2, Username: "alice"},
+		}},
+	}
+
+	stats := Compute("alice", records)
+	assert.Equal(t, Tally{Games: 3, Wins: 1, Losses: 1, Draws: 1}, stats.Tally)
+	assert.InDelta(t, 1.0/3, stats.WinRate(), 1e-9)
+
+	assert.Equal(t, []SizeTally{
+		{Size: "4x1", Tally: Tally{Games: 2, Wins: 1, Losses: 1}},
+		{Size: "8x8", Tally: Tally{Games: 1, Draws: 1}},
+	}, stats.BySize)
+
+	assert.Equal(t, []SeatTally{
+		{Seat: 1, Tally: Tally{Games: 1, Losses: 1}},
+		{Seat: 2, Tally: Tally{Games: 2, Wins: 1, Draws: 1}},
+	}, stats.BySeat)
+
+	// Alice eliminated a tile in the second game only
+	assert.InDelta(t, 1.0/3, stats.AverageTilesEliminated, 1e-9)
+	assert.InDelta(t, 12.0/3, stats.AverageMoves, 1e-9)
+
+	assert.Equal(t, []Opening{
+		{Size: "4x1", Row: 0, Col: 0, Count: 1},
+		{Size: "4x1", Row: 0, Col: 2, Count: 1},
+	}, stats.FavoriteOpenings)
+}
+
+func TestComputeNoGames(t *testing.T) {
+	stats := Compute("alice", nil)
+	assert.Equal(t, 0, stats.Games)
+	assert.Empty(t, stats.BySize)
+	assert.Zero(t, stats.AverageMoves)
+}
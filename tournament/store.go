@@ -0,0 +1,186 @@
+package tournament
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/Denloob/cadere/util"
+)
+
+const idByteLength = 9
+
+var ErrorNotFound = errors.New("tournament not found")
+
+// Store keeps the tournaments in a JSON file. An empty path keeps them in
+// memory only.
+type Store struct {
+	mutex       sync.Mutex
+	path        string
+	tournaments map[string]*Tournament
+}
+
+func NewStore(path string) (*Store, error) {
+	store := &Store{
+		path:        path,
+		tournaments: make(map[string]*Tournament),
+	}
+
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &store.tournaments); err != nil {
+		return nil, fmt.Errorf("corrupted tournaments file %s: %w", path, err)
+	}
+	if store.tournaments == nil {
+		store.tournaments = make(map[string]*Tournament)
+	}
+
+	return store, nil
+}
+
+func newId() (string, error) {
+	idBytes := make([]byte, idByteLength)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(idBytes), nil
+}
+
+// Create adds the tournament, giving it a new ID, and returns it
+func (s *Store) Create(t Tournament) (Tournament, error) {
+	id, err := newId()
+	if err != nil {
+		return Tournament{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	t.Id = id
+	t.Status = StatusRegistration
+	created := t.Clone()
+	s.tournaments[id] = &created
+
+	if err := s.save(); err != nil {
+		delete(s.tournaments, id)
+		return Tournament{}, err
+	}
+
+	return t, nil
+}
+
+// Get returns a copy of the tournament
+func (s *Store) Get(id string) (Tournament, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	t, ok := s.tournaments[id]
+	if !ok {
+		return Tournament{}, ErrorNotFound
+	}
+
+	return t.Clone(), nil
+}
+
+// List returns copies of all the tournaments, newest first
+func (s *Store) List() []Tournament {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tournaments := make([]Tournament, 0, len(s.tournaments))
+	for _, t := range s.tournaments {
+		tournaments = append(tournaments, t.Clone())
+	}
+
+	sort.Slice(tournaments, func(i, j int) bool {
+		return tournaments[i].Created.After(tournaments[j].Created)
+	})
+
+	return tournaments
+}
+
+// Running returns the IDs of the running tournaments
+func (s *Store) Running() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ids := []string{}
+	for id, t := range s.tournaments {
+		if t.Status == StatusRunning {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Update changes the tournament with the function, while holding the lock of
+// the store. Nothing is changed if the function fails. The file is written
+// only if the tournament changed.
+func (s *Store) Update(id string, update func(t *Tournament) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	t, ok := s.tournaments[id]
+	if !ok {
+		return ErrorNotFound
+	}
+
+	before, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	updated := t.Clone()
+	if err := update(&updated); err != nil {
+		return err
+	}
+
+	after, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(before, after) {
+		return nil
+	}
+
+	s.tournaments[id] = &updated
+	if err := s.save(); err != nil {
+		s.tournaments[id] = t
+		return err
+	}
+
+	return nil
+}
+
+// save writes the tournaments to the file. The caller must hold the mutex.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.tournaments)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(s.path, data)
+}
@@ -0,0 +1,667 @@
+// Package tournament pairs the players of round-robin, Swiss and knockout
+// tournaments and keeps their standings. Every game is between two players.
+package tournament
+
+import (
+	"errors"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Denloob/cadere/clock"
+)
+
+type Format string
+
+const (
+	FormatRoundRobin Format = "roundRobin"
+	FormatSwiss      Format = "swiss"
+	FormatKnockout   Format = "knockout"
+)
+
+// Formats are all the formats, in the order they are offered
+var Formats = []Format{FormatRoundRobin, FormatSwiss, FormatKnockout}
+
+func (f Format) Name() string {
+	switch f {
+	case FormatRoundRobin:
+		return "Round-robin"
+	case FormatSwiss:
+		return "Swiss"
+	case FormatKnockout:
+		return "Knockout"
+	}
+
+	return "Unknown"
+}
+
+func ParseFormat(s string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == s {
+			return format, nil
+		}
+	}
+
+	return "", errors.New("Unknown tournament format")
+}
+
+type Status string
+
+const (
+	StatusRegistration Status = "registration"
+	StatusRunning      Status = "running"
+	StatusFinished     Status = "finished"
+)
+
+type Outcome string
+
+const (
+	OutcomePending Outcome = ""
+	OutcomeWin     Outcome = "win"
+	OutcomeDraw    Outcome = "draw"
+	// OutcomeForfeit is a win because the opponent didn't show up
+	OutcomeForfeit Outcome = "forfeit"
+	// OutcomeDoubleForfeit is a loss for both players, as neither showed up
+	OutcomeDoubleForfeit Outcome = "doubleForfeit"
+	OutcomeBye           Outcome = "bye"
+)
+
+const MinPlayers = 2
+
+var (
+	ErrorNotRegistration  = errors.New("Registration is closed")
+	ErrorAlreadyJoined    = errors.New("You already joined this tournament")
+	ErrorNotJoined        = errors.New("You are not playing in this tournament")
+	ErrorTooFewPlayers    = errors.New("A tournament needs at least two players")
+	ErrorNotRunning       = errors.New("The tournament is not running")
+	ErrorAlreadyRecorded  = errors.New("The result of this game is already recorded")
+	ErrorNoSuchPairing    = errors.New("no such pairing")
+	ErrorRoundNotComplete = errors.New("The round is not complete")
+)
+
+// Pairing is a game of a round. Byes have a single player.
+type Pairing struct {
+	// Players are the usernames in seat order
+	Players []string
+	Outcome Outcome
+	// Winner is set for wins and forfeits
+	Winner string `json:",omitempty"`
+	// GameId is the ID of the game the pairing is played in
+	GameId string `json:",omitempty"`
+	// Started is when the game was created, which starts the wait for the
+	// players to show up
+	Started time.Time
+	// Present are the players who showed up for the game
+	Present map[string]bool `json:",omitempty"`
+}
+
+func (p Pairing) Done() bool {
+	return p.Outcome != OutcomePending
+}
+
+func (p Pairing) IsBye() bool {
+	return len(p.Players) < 2
+}
+
+// Has returns whether the player plays in the pairing
+func (p Pairing) Has(username string) bool {
+	for _, player := range p.Players {
+		if sameUser(player, username) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Points returns the points the player scored in the pairing
+func (p Pairing) Points(username string) float64 {
+	switch p.Outcome {
+	case OutcomeWin, OutcomeForfeit:
+		if sameUser(p.Winner, username) {
+			return 1
+		}
+	case OutcomeDraw:
+		return 0.5
+	case OutcomeBye:
+		return 1
+	}
+
+	return 0
+}
+
+type Round struct {
+	Number   int
+	Started  time.Time
+	Pairings []Pairing
+}
+
+// Complete returns whether every game of the round has a result
+func (r Round) Complete() bool {
+	for _, pairing := range r.Pairings {
+		if !pairing.Done() {
+			return false
+		}
+	}
+
+	return true
+}
+
+type Tournament struct {
+	Id          string
+	Name        string
+	Organizer   string
+	Format      Format
+	BoardSize   int
+	TimeControl clock.TimeControl
+	Rated       bool
+	// SwissRounds is the number of rounds of a Swiss tournament
+	SwissRounds int `json:",omitempty"`
+
+	Status Status
+	// Players are the usernames of the players, in seed order once the
+	// tournament started
+	Players []string
+	Rounds  []Round
+	Created time.Time
+}
+
+func sameUser(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// Clone returns a deep copy of the tournament
+func (t Tournament) Clone() Tournament {
+	clone := t
+	clone.Players = append([]string{}, t.Players...)
+	clone.Rounds = make([]Round, len(t.Rounds))
+	for i, round := range t.Rounds {
+		clone.Rounds[i] = round
+		clone.Rounds[i].Pairings = make([]Pairing, len(round.Pairings))
+		for j, pairing := range round.Pairings {
+			pairing.Players = append([]string{}, pairing.Players...)
+			if pairing.Present != nil {
+				present := make(map[string]bool, len(pairing.Present))
+				for username, ok := range pairing.Present {
+					present[username] = ok
+				}
+				pairing.Present = present
+			}
+			clone.Rounds[i].Pairings[j] = pairing
+		}
+	}
+
+	return clone
+}
+
+// HasPlayer returns whether the player joined the tournament
+func (t Tournament) HasPlayer(username string) bool {
+	for _, player := range t.Players {
+		if sameUser(player, username) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Tournament) Join(username string) error {
+	if t.Status != StatusRegistration {
+		return ErrorNotRegistration
+	}
+	if t.HasPlayer(username) {
+		return ErrorAlreadyJoined
+	}
+
+	t.Players = append(t.Players, username)
+	return nil
+}
+
+func (t *Tournament) Leave(username string) error {
+	if t.Status != StatusRegistration {
+		return ErrorNotRegistration
+	}
+
+	for i, player := range t.Players {
+		if sameUser(player, username) {
+			t.Players = append(t.Players[:i], t.Players[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrorNotJoined
+}
+
+// TotalRounds returns how many rounds the tournament has
+func (t Tournament) TotalRounds() int {
+	n := len(t.Players)
+	switch t.Format {
+	case FormatRoundRobin:
+		if n%2 == 1 {
+			return n
+		}
+		return n - 1
+	case FormatSwiss:
+		return t.SwissRounds
+	case FormatKnockout:
+		if n < 2 {
+			return 0
+		}
+		return bits.Len(uint(n - 1))
+	}
+
+	return 0
+}
+
+// DefaultSwissRounds is enough rounds for a Swiss tournament to find a single
+// winner
+func DefaultSwissRounds(players int) int {
+	return max(1, bits.Len(uint(max(1, players-1))))
+}
+
+// Start closes the registration and pairs the first round. The players must
+// already be in seed order.
+func (t *Tournament) Start(now time.Time) error {
+	if t.Status != StatusRegistration {
+		return ErrorNotRegistration
+	}
+	if len(t.Players) < MinPlayers {
+		return ErrorTooFewPlayers
+	}
+	if t.Format == FormatSwiss && t.SwissRounds <= 0 {
+		t.SwissRounds = DefaultSwissRounds(len(t.Players))
+	}
+
+	t.Status = StatusRunning
+	t.startRound(now)
+	return nil
+}
+
+// CurrentRound returns the round being played, nil before the tournament
+// started
+func (t *Tournament) CurrentRound() *Round {
+	if len(t.Rounds) == 0 {
+		return nil
+	}
+
+	return &t.Rounds[len(t.Rounds)-1]
+}
+
+// Record sets the result of a game of the current round
+func (t *Tournament) Record(pairing int, outcome Outcome, winner string) error {
+	round := t.CurrentRound()
+	if t.Status != StatusRunning || round == nil {
+		return ErrorNotRunning
+	}
+	if pairing < 0 || pairing >= len(round.Pairings) {
+		return ErrorNoSuchPairing
+	}
+	if round.Pairings[pairing].Done() {
+		return ErrorAlreadyRecorded
+	}
+
+	round.Pairings[pairing].Outcome = outcome
+	round.Pairings[pairing].Winner = winner
+	return nil
+}
+
+// Advance pairs the next round once the current one is complete, or finishes
+// the tournament after the last round. Returns whether a new round started.
+func (t *Tournament) Advance(now time.Time) (bool, error) {
+	round := t.CurrentRound()
+	if t.Status != StatusRunning || round == nil {
+		return false, ErrorNotRunning
+	}
+	if !round.Complete() {
+		return false, ErrorRoundNotComplete
+	}
+
+	if len(t.Rounds) >= t.TotalRounds() {
+		t.Status = StatusFinished
+		return false, nil
+	}
+
+	t.startRound(now)
+	return true, nil
+}
+
+func (t *Tournament) startRound(now time.Time) {
+	var pairs [][]string
+	switch t.Format {
+	case FormatRoundRobin:
+		pairs = roundRobinPairs(t.Players, len(t.Rounds))
+	case FormatSwiss:
+		pairs = t.swissPairs()
+	case FormatKnockout:
+		pairs = t.knockoutPairs()
+	}
+
+	round := Round{Number: len(t.Rounds) + 1, Started: now}
+	for _, players := range pairs {
+		pairing := Pairing{Players: players}
+		if pairing.IsBye() {
+			pairing.Outcome = OutcomeBye
+			if len(players) == 1 {
+				pairing.Winner = players[0]
+			}
+		}
+		round.Pairings = append(round.Pairings, pairing)
+	}
+
+	t.Rounds = append(t.Rounds, round)
+}
+
+// roundRobinPairs pairs the round with the circle method: the first player
+// stays in place while the others rotate. With an odd number of players,
+// whoever is paired with the empty seat has a bye.
+func roundRobinPairs(players []string, round int) [][]string {
+	circle := append([]string{}, players...)
+	if len(circle)%2 == 1 {
+		circle = append(circle, "")
+	}
+
+	n := len(circle)
+	rotated := []string{circle[0]}
+	for i := 0; i < n-1; i++ {
+		rotated = append(rotated, circle[1+(i+round)%(n-1)])
+	}
+
+	pairs := [][]string{}
+	for i := 0; i < n/2; i++ {
+		first, second := rotated[i], rotated[n-1-i]
+		// Alternate who moves first between the rounds
+		if round%2 == 1 {
+			first, second = second, first
+		}
+		pairs = append(pairs, withoutEmpty(first, second))
+	}
+
+	return pairs
+}
+
+func withoutEmpty(players ...string) []string {
+	present := []string{}
+	for _, player := range players {
+		if player != "" {
+			present = append(present, player)
+		}
+	}
+
+	return present
+}
+
+// met returns whether the two players already played each other
+func (t Tournament) met(a, b string) bool {
+	for _, round := range t.Rounds {
+		for _, pairing := range round.Pairings {
+			if pairing.Has(a) && pairing.Has(b) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (t Tournament) hadBye(username string) bool {
+	for _, round := range t.Rounds {
+		for _, pairing := range round.Pairings {
+			if pairing.IsBye() && pairing.Has(username) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// swissPairs pairs the players with similar scores who haven't met yet. The
+// lowest ranked player who hasn't had a bye sits out an odd round.
+func (t Tournament) swissPairs() [][]string {
+	ranked := []string{}
+	for _, standing := range t.Standings() {
+		ranked = append(ranked, standing.Username)
+	}
+
+	pairs := [][]string{}
+	if len(ranked)%2 == 1 {
+		bye := len(ranked) - 1
+		for i := len(ranked) - 1; i >= 0; i-- {
+			if !t.hadBye(ranked[i]) {
+				bye = i
+				break
+			}
+		}
+
+		pairs = append(pairs, []string{ranked[bye]})
+		ranked = append(ranked[:bye:bye], ranked[bye+1:]...)
+	}
+
+	matched, ok := t.pairUnmet(ranked)
+	if !ok {
+		// Everyone met already, so allow rematches
+		matched = nil
+		for i := 0; i+1 < len(ranked); i += 2 {
+			matched = append(matched, []string{ranked[i], ranked[i+1]})
+		}
+	}
+
+	for i, pair := range matched {
+		if (len(t.Rounds)+i)%2 == 1 {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+	}
+
+	return append(matched, pairs...)
+}
+
+// pairUnmet pairs the ranked players from the top, each with the highest
+// ranked player they haven't met, backtracking when the rest can't be paired
+func (t Tournament) pairUnmet(ranked []string) ([][]string, bool) {
+	if len(ranked) == 0 {
+		return [][]string{}, true
+	}
+
+	first := ranked[0]
+	for i := 1; i < len(ranked); i++ {
+		if t.met(first, ranked[i]) {
+			continue
+		}
+
+		rest := append(append([]string{}, ranked[1:i]...), ranked[i+1:]...)
+		if pairs, ok := t.pairUnmet(rest); ok {
+			return append([][]string{{first, ranked[i]}}, pairs...), true
+		}
+	}
+
+	return nil, false
+}
+
+// bracketOrder returns the seeds of the first round of a knockout bracket of
+// the given size, so that the top seeds can only meet in the late rounds
+func bracketOrder(size int) []int {
+	order := []int{0}
+	for len(order) < size {
+		next := []int{}
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)-1-seed)
+		}
+		order = next
+	}
+
+	return order
+}
+
+// knockoutPairs pairs the first round by seed, with byes for the top seeds
+// to fill the bracket, and later rounds by the advancing players
+func (t Tournament) knockoutPairs() [][]string {
+	var advancing []string
+	if len(t.Rounds) == 0 {
+		size := 1 << t.TotalRounds()
+		for _, seed := range bracketOrder(size) {
+			if seed < len(t.Players) {
+				advancing = append(advancing, t.Players[seed])
+			} else {
+				advancing = append(advancing, "")
+			}
+		}
+	} else {
+		for _, pairing := range t.CurrentRound().Pairings {
+			advancing = append(advancing, t.advancing(pairing))
+		}
+	}
+
+	pairs := [][]string{}
+	for i := 0; i+1 < len(advancing); i += 2 {
+		pairs = append(pairs, withoutEmpty(advancing[i], advancing[i+1]))
+	}
+
+	return pairs
+}
+
+// seed returns the position of the player in the seed order
+func (t Tournament) seed(username string) int {
+	for i, player := range t.Players {
+		if sameUser(player, username) {
+			return i
+		}
+	}
+
+	return len(t.Players)
+}
+
+// advancing returns the player who goes on to the next knockout round, ""
+// when neither does. The higher seed advances on a draw.
+func (t Tournament) advancing(pairing Pairing) string {
+	switch pairing.Outcome {
+	case OutcomeWin, OutcomeForfeit, OutcomeBye:
+		return pairing.Winner
+	case OutcomeDraw:
+		first := pairing.Players[0]
+		for _, player := range pairing.Players[1:] {
+			if t.seed(player) < t.seed(first) {
+				first = player
+			}
+		}
+		return first
+	}
+
+	return ""
+}
+
+// Standing is the place of a player in the tournament
+type Standing struct {
+	Rank     int
+	Username string
+	Points   float64
+	Wins     int
+	Draws    int
+	Losses   int
+	Byes     int
+	// Buchholz is the sum of the points of the opponents, which breaks ties
+	Buchholz float64
+	// Eliminated players are out of a knockout tournament
+	Eliminated bool
+	// Reached is the last round the player played in a knockout tournament
+	Reached int
+}
+
+// Standings ranks the players by points, breaking ties by Buchholz and then
+// by seed. Knockout tournaments rank by the round reached first.
+func (t Tournament) Standings() []Standing {
+	standings := make([]Standing, len(t.Players))
+	index := map[string]int{}
+	for i, player := range t.Players {
+		standings[i].Username = player
+		index[strings.ToLower(player)] = i
+	}
+	at := func(username string) *Standing {
+		return &standings[index[strings.ToLower(username)]]
+	}
+
+	opponents := map[string][]string{}
+	for _, round := range t.Rounds {
+		for _, pairing := range round.Pairings {
+			for _, player := range pairing.Players {
+				standing := at(player)
+				standing.Reached = round.Number
+				if !pairing.Done() {
+					continue
+				}
+
+				standing.Points += pairing.Points(player)
+				switch {
+				case pairing.Outcome == OutcomeBye:
+					standing.Byes++
+				case pairing.Outcome == OutcomeDraw:
+					standing.Draws++
+				case pairing.Points(player) == 1:
+					standing.Wins++
+				default:
+					standing.Losses++
+				}
+
+				if t.Format == FormatKnockout && !sameUser(t.advancing(pairing), player) {
+					standing.Eliminated = true
+				}
+
+				for _, opponent := range pairing.Players {
+					if !sameUser(opponent, player) {
+						key := strings.ToLower(player)
+						opponents[key] = append(opponents[key], opponent)
+					}
+				}
+			}
+		}
+	}
+
+	for i := range standings {
+		for _, opponent := range opponents[strings.ToLower(standings[i].Username)] {
+			standings[i].Buchholz += at(opponent).Points
+		}
+	}
+
+	seeds := map[string]int{}
+	for i, player := range t.Players {
+		seeds[player] = i
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if t.Format == FormatKnockout {
+			if a.Reached != b.Reached {
+				return a.Reached > b.Reached
+			}
+			if a.Eliminated != b.Eliminated {
+				return !a.Eliminated
+			}
+		}
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Buchholz != b.Buchholz {
+			return a.Buchholz > b.Buchholz
+		}
+		return seeds[a.Username] < seeds[b.Username]
+	})
+
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+
+	return standings
+}
+
+// Winner returns the winner of a finished tournament
+func (t Tournament) Winner() (string, bool) {
+	if t.Status != StatusFinished || len(t.Players) == 0 {
+		return "", false
+	}
+
+	standings := t.Standings()
+	if t.Format == FormatKnockout && standings[0].Eliminated {
+		return "", false
+	}
+
+	return standings[0].Username, true
+}
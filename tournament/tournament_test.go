@@ -0,0 +1,280 @@
+package tournament
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTournament(format Format, players int) Tournament {
+	t := Tournament{Name: "Weekly", Format: format, Status: StatusRegistration}
+	for i := 1; i <= players; i++ {
+		t.Join(fmt.Sprintf("p%d", i))
+	}
+
+	return t
+}
+
+// playRound records a win for the lower seed of every game, or the result
+// chosen by decide when it isn't nil
+func playRound(t *testing.T, tournament *Tournament, decide func(Pairing) (Outcome, string)) {
+	for i, pairing := range tournament.CurrentRound().Pairings {
+		if pairing.Done() {
+			continue
+		}
+
+		outcome, winner := OutcomeWin, pairing.Players[0]
+		for _, player := range pairing.Players {
+			if tournament.seed(player) < tournament.seed(winner) {
+				winner = player
+			}
+		}
+		if decide != nil {
+			outcome, winner = decide(pairing)
+		}
+
+		assert.NoError(t, tournament.Record(i, outcome, winner))
+	}
+}
+
+func pairKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "-" + b
+}
+
+func TestRegistration(t *testing.T) {
+	tournament := newTournament(FormatRoundRobin, 1)
+	assert.ErrorIs(t, tournament.Join("P1"), ErrorAlreadyJoined)
+	assert.ErrorIs(t, tournament.Start(time.Now()), ErrorTooFewPlayers)
+
+	assert.NoError(t, tournament.Join("p2"))
+	assert.NoError(t, tournament.Join("p3"))
+	assert.NoError(t, tournament.Leave("P3"))
+	assert.ErrorIs(t, tournament.Leave("p3"), ErrorNotJoined)
+
+	assert.NoError(t, tournament.Start(time.Now()))
+	assert.ErrorIs(t, tournament.Join("p4"), ErrorNotRegistration)
+	assert.Equal(t, StatusRunning, tournament.Status)
+}
+
+func TestRoundRobin(t *testing.T) {
+	for _, players := range []int{4, 5} {
+		tournament := newTournament(FormatRoundRobin, players)
+		assert.NoError(t, tournament.Start(time.Now()))
+
+		games := map[string]int{}
+		byes := map[string]int{}
+		for {
+			for _, pairing := range tournament.CurrentRound().Pairings {
+				if pairing.IsBye() {
+					byes[pairing.Players[0]]++
+				} else {
+					games[pairKey(pairing.Players[0], pairing.Players[1])]++
+				}
+			}
+
+			playRound(t, &tournament, nil)
+			next, err := tournament.Advance(time.Now())
+			assert.NoError(t, err)
+			if !next {
+				break
+			}
+		}
+
+		assert.Equal(t, StatusFinished, tournament.Status)
+		assert.Len(t, tournament.Rounds, tournament.TotalRounds())
+		assert.Len(t, games, players*(players-1)/2)
+		for _, count := range games {
+			assert.Equal(t, 1, count)
+		}
+		if players%2 == 1 {
+			assert.Len(t, byes, players)
+		}
+
+		winner, ok := tournament.Winner()
+		assert.True(t, ok)
+		assert.Equal(t, "p1", winner)
+	}
+}
+
+func TestSwissAvoidsRematches(t *testing.T) {
+	tournament := newTournament(FormatSwiss, 6)
+	tournament.SwissRounds = 4
+	assert.NoError(t, tournament.Start(time.Now()))
+
+	games := map[string]bool{}
+	for {
+		for _, pairing := range tournament.CurrentRound().Pairings {
+			key := pairKey(pairing.Players[0], pairing.Players[1])
+			assert.False(t, games[key], "rematch %s", key)
+			games[key] = true
+		}
+
+		playRound(t, &tournament, nil)
+		next, err := tournament.Advance(time.Now())
+		assert.NoError(t, err)
+		if !next {
+			break
+		}
+	}
+
+	assert.Len(t, tournament.Rounds, 4)
+	standings := tournament.Standings()
+	assert.Equal(t, "p1", standings[0].Username)
+	assert.Equal(t, 4.0, standings[0].Points)
+}
+
+func TestSwissByes(t *testing.T) {
+	tournament := newTournament(FormatSwiss, 5)
+	assert.NoError(t, tournament.Start(time.Now()))
+	assert.Equal(t, 3, tournament.SwissRounds)
+
+	byes := map[string]bool{}
+	for {
+		for _, pairing := range tournament.CurrentRound().Pairings {
+			if pairing.IsBye() {
+				assert.False(t, byes[pairing.Players[0]])
+				byes[pairing.Players[0]] = true
+			}
+		}
+
+		playRound(t, &tournament, nil)
+		next, _ := tournament.Advance(time.Now())
+		if !next {
+			break
+		}
+	}
+
+	assert.Len(t, byes, 3)
+	assert.True(t, byes["p5"])
+}
+
+func TestKnockout(t *testing.T) {
+	tournament := newTournament(FormatKnockout, 6)
+	assert.NoError(t, tournament.Start(time.Now()))
+	assert.Equal(t, 3, tournament.TotalRounds())
+
+	first := tournament.CurrentRound().Pairings
+	assert.Len(t, first, 4)
+	byes := 0
+	for _, pairing := range first {
+		if pairing.IsBye() {
+			byes++
+			assert.Contains(t, []string{"p1", "p2"}, pairing.Players[0])
+		}
+	}
+	assert.Equal(t, 2, byes)
+
+	_, err := tournament.Advance(time.Now())
+	assert.ErrorIs(t, err, ErrorRoundNotComplete)
+
+	// The higher seed advances on a draw
+	playRound(t, &tournament, func(pairing Pairing) (Outcome, string) {
+		return OutcomeDraw, ""
+	})
+	next, err := tournament.Advance(time.Now())
+	assert.NoError(t, err)
+	assert.True(t, next)
+	for _, pairing := range tournament.CurrentRound().Pairings {
+		for _, player := range pairing.Players {
+			assert.Contains(t, []string{"p1", "p2", "p3", "p4"}, player)
+		}
+	}
+
+	// The lower seed wins every game from now on
+	for next {
+		playRound(t, &tournament, func(pairing Pairing) (Outcome, string) {
+			return OutcomeWin, pairing.Players[len(pairing.Players)-1]
+		})
+		next, err = tournament.Advance(time.Now())
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, StatusFinished, tournament.Status)
+	assert.Len(t, tournament.CurrentRound().Pairings, 1)
+	winner, ok := tournament.Winner()
+	assert.True(t, ok)
+	assert.Equal(t, tournament.CurrentRound().Pairings[0].Winner, winner)
+	standings := tournament.Standings()
+	assert.False(t, standings[0].Eliminated)
+	assert.True(t, standings[1].Eliminated)
+	assert.Equal(t, 3, standings[1].Reached)
+}
+
+func TestKnockoutDoubleForfeit(t *testing.T) {
+	tournament := newTournament(FormatKnockout, 4)
+	assert.NoError(t, tournament.Start(time.Now()))
+
+	playRound(t, &tournament, func(pairing Pairing) (Outcome, string) {
+		if pairing.Has("p1") {
+			return OutcomeDoubleForfeit, ""
+		}
+		return OutcomeForfeit, pairing.Players[0]
+	})
+	next, err := tournament.Advance(time.Now())
+	assert.NoError(t, err)
+	assert.True(t, next)
+
+	final := tournament.CurrentRound().Pairings
+	assert.Len(t, final, 1)
+	assert.True(t, final[0].IsBye())
+	assert.Equal(t, OutcomeBye, final[0].Outcome)
+
+	next, err = tournament.Advance(time.Now())
+	assert.NoError(t, err)
+	assert.False(t, next)
+	winner, ok := tournament.Winner()
+	assert.True(t, ok)
+	assert.Equal(t, final[0].Winner, winner)
+}
+
+func TestRecord(t *testing.T) {
+	tournament := newTournament(FormatRoundRobin, 2)
+	assert.ErrorIs(t, tournament.Record(0, OutcomeWin, "p1"), ErrorNotRunning)
+
+	assert.NoError(t, tournament.Start(time.Now()))
+	assert.ErrorIs(t, tournament.Record(1, OutcomeWin, "p1"), ErrorNoSuchPairing)
+	assert.NoError(t, tournament.Record(0, OutcomeDraw, ""))
+	assert.ErrorIs(t, tournament.Record(0, OutcomeWin, "p1"), ErrorAlreadyRecorded)
+
+	standings := tournament.Standings()
+	assert.Equal(t, 0.5, standings[0].Points)
+	assert.Equal(t, 1, standings[0].Draws)
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tournaments.json")
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+
+	created, err := store.Create(Tournament{Name: "Weekly", Format: FormatSwiss})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.Id)
+
+	err = store.Update(created.Id, func(t *Tournament) error {
+		return t.Join("alice")
+	})
+	assert.NoError(t, err)
+
+	err = store.Update(created.Id, func(t *Tournament) error {
+		t.Join("bob")
+		return ErrorTooFewPlayers
+	})
+	assert.ErrorIs(t, err, ErrorTooFewPlayers)
+
+	store, err = NewStore(path)
+	assert.NoError(t, err)
+	loaded, err := store.Get(created.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Weekly", loaded.Name)
+	assert.Equal(t, []string{"alice"}, loaded.Players)
+	assert.Empty(t, store.Running())
+
+	_, err = store.Get("missing")
+	assert.ErrorIs(t, err, ErrorNotFound)
+}
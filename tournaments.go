@@ -0,0 +1,499 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Denloob/cadere/auth"
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/tournament"
+)
+
+const (
+	// TOURNAMENT_NO_SHOW_TIMEOUT is how long the players of a tournament game
+	// have to open it before they forfeit
+	TOURNAMENT_NO_SHOW_TIMEOUT  = 5 * time.Minute
+	TOURNAMENT_NAME_MAX_LENGTH  = 50
+	TOURNAMENT_SWISS_ROUNDS_MAX = 20
+)
+
+var tournamentStore *tournament.Store
+
+// TournamentsView is the data the tournament list is rendered with
+type TournamentsView struct {
+	Username    string
+	Tournaments []tournament.Tournament
+	Formats     []tournament.Format
+	Error       string
+}
+
+// TournamentView is the data the page of a tournament is rendered with
+type TournamentView struct {
+	Tournament tournament.Tournament
+	Standings  []tournament.Standing
+	// Username is the logged in viewer, "" for anonymous viewers
+	Username string
+	Error    string
+}
+
+func newTournamentView(t tournament.Tournament, username string) TournamentView {
+	return TournamentView{
+		Tournament: t,
+		Standings:  t.Standings(),
+		Username:   username,
+	}
+}
+
+func (v TournamentView) IsOrganizer() bool {
+	return v.Username != "" && strings.EqualFold(v.Username, v.Tournament.Organizer)
+}
+
+func (v TournamentView) Joined() bool {
+	return v.Username != "" && v.Tournament.HasPlayer(v.Username)
+}
+
+func (v TournamentView) Registration() bool {
+	return v.Tournament.Status == tournament.StatusRegistration
+}
+
+func (v TournamentView) Finished() bool {
+	return v.Tournament.Status == tournament.StatusFinished
+}
+
+func (v TournamentView) Knockout() bool {
+	return v.Tournament.Format == tournament.FormatKnockout
+}
+
+// Champion is the winner of the finished tournament, or ""
+func (v TournamentView) Champion() string {
+	winner, _ := v.Tournament.Winner()
+	return winner
+}
+
+// MyGame returns the unfinished game of the viewer in the current round, or
+// nil if they have none
+func (v TournamentView) MyGame() *tournament.Pairing {
+	round := v.Tournament.CurrentRound()
+	if v.Username == "" || round == nil || v.Tournament.Status != tournament.StatusRunning {
+		return nil
+	}
+
+	for _, pairing := range round.Pairings {
+		if pairing.Has(v.Username) && !pairing.Done() && !pairing.IsBye() && pairing.GameId != "" {
+			return &pairing
+		}
+	}
+
+	return nil
+}
+
+// Result describes the result of a game of the tournament
+func (v TournamentView) Result(pairing tournament.Pairing) string {
+	switch pairing.Outcome {
+	case tournament.OutcomePending:
+		if pairing.GameId == "" {
+			return "Waiting"
+		}
+		return "Playing"
+	case tournament.OutcomeWin:
+		return pairing.Winner + " won"
+	case tournament.OutcomeDraw:
+		return "Draw"
+	case tournament.OutcomeForfeit:
+		return pairing.Winner + " won by forfeit"
+	case tournament.OutcomeDoubleForfeit:
+		return "Neither player showed up"
+	case tournament.OutcomeBye:
+		return "Bye"
+	}
+
+	return ""
+}
+
+// ReplayUrl returns the link to the replay of a game which was played to
+// the end, or ""
+func (v TournamentView) ReplayUrl(pairing tournament.Pairing) string {
+	switch pairing.Outcome {
+	case tournament.OutcomeWin, tournament.OutcomeDraw, tournament.OutcomeForfeit:
+		if pairing.GameId != "" {
+			return "/replay/" + gameUrlId(pairing.GameId)
+		}
+	}
+
+	return ""
+}
+
+// parseTournament parses the form which creates a tournament
+func parseTournament(formValue func(string) string) (tournament.Tournament, error) {
+	var t tournament.Tournament
+
+	t.Name = strings.TrimSpace(formValue("name"))
+	if t.Name == "" || len(t.Name) > TOURNAMENT_NAME_MAX_LENGTH {
+		return t, GameErrorf("The name must be between 1 and %d characters long", TOURNAMENT_NAME_MAX_LENGTH)
+	}
+
+	format, err := tournament.ParseFormat(formValue("format"))
+	if err != nil {
+		return t, GameError{err}
+	}
+	t.Format = format
+
+	size, err := strconv.Atoi(formValue("size"))
+	if err != nil || size < GAME_SIZE_MIN || size > GAME_SIZE_MAX {
+		return t, GameErrorf("Board cannot be smaller than %d or larger than %d", GAME_SIZE_MIN, GAME_SIZE_MAX)
+	}
+	if engine.NewBoard(size, size).MaxPlayerCount(engine.MinTilesPerPlayer) < 2 {
+		return t, GameErrorf("The board is too small for 2 players")
+	}
+	t.BoardSize = size
+
+	if format == tournament.FormatSwiss && formValue("swissRounds") != "" {
+		rounds, err := strconv.Atoi(formValue("swissRounds"))
+		if err != nil || rounds < 1 || rounds > TOURNAMENT_SWISS_ROUNDS_MAX {
+			return t, GameErrorf("A Swiss tournament has between 1 and %d rounds", TOURNAMENT_SWISS_ROUNDS_MAX)
+		}
+		t.SwissRounds = rounds
+	}
+
+	t.TimeControl, err = parseTimeControl(formValue)
+	if err != nil {
+		return t, err
+	}
+	t.Rated = formValue("rated") != ""
+
+	return t, nil
+}
+
+// seedByRating orders the players from the highest rated to the lowest,
+// keeping the order they joined in for equal ratings
+func seedByRating(players []string) {
+	ratings := make(map[string]float64, len(players))
+	for _, player := range players {
+		ratings[player] = ratingStore.Get(player).Rating
+	}
+
+	sort.SliceStable(players, func(i, j int) bool {
+		return ratings[players[i]] > ratings[players[j]]
+	})
+}
+
+// newTournamentGame creates the game of the pairing and starts it right
+// away, as the players are already known
+func newTournamentGame(t tournament.Tournament, pairing tournament.Pairing, now time.Time) (*WebGameSession, error) {
+	nonce, err := auth.GenerateNonce(NonceBitLength)
+	if err != nil {
+		return nil, err
+	}
+
+	game := engine.NewGame(engine.NewBoard(t.BoardSize, t.BoardSize))
+	for seat := range pairing.Players {
+		game.AddPlayers(engine.Player(seat + 1))
+	}
+
+	session := auth.NewGameSession(&game, nonce)
+	for seat, username := range pairing.Players {
+		player := engine.Player(seat + 1)
+		session.SetProfile(player, withDefaultProfile(auth.PlayerProfile{Name: username}, player))
+		session.SetAccount(player, username)
+	}
+
+	webSession := games.AddSession(session, t.TimeControl)
+
+	webSession.SessionMutex.Lock()
+	defer webSession.SessionMutex.Unlock()
+
+	webSession.Rated = t.Rated
+	webSession.StartingOrder = game.Players()
+	webSession.StartedAt = now
+	game.ProgressStage()
+	webSession.updateClock(now)
+
+	return webSession, nil
+}
+
+// syncPairing creates the game of the pairing, and records its result once
+// the game is over or a player didn't show up in time
+func syncPairing(t *tournament.Tournament, index int, now time.Time) {
+	pairing := &t.CurrentRound().Pairings[index]
+	if pairing.Done() {
+		return
+	}
+
+	webSession, ok := games.Get(pairing.GameId)
+	if !ok {
+		// The game was never created, or was lost when the server restarted
+		webSession, err := newTournamentGame(*t, *pairing, now)
+		if err != nil {
+			return
+		}
+
+		pairing.GameId = webSession.Session.Nonce()
+		pairing.Started = now
+		pairing.Present = nil
+		return
+	}
+
+	webSession.SessionMutex.Lock()
+	defer webSession.SessionMutex.Unlock()
+
+	session := webSession.Session
+	game := session.Game
+	if game.Stage() == engine.StageOver {
+		if winner, err := game.Winner(); err == nil {
+			t.Record(index, tournament.OutcomeWin, session.Account(winner))
+		} else {
+			t.Record(index, tournament.OutcomeDraw, "")
+		}
+		return
+	}
+
+	absent := []engine.Player{}
+	for seat, username := range pairing.Players {
+		player := engine.Player(seat + 1)
+		if webSession.hasSocket(player) {
+			if pairing.Present == nil {
+				pairing.Present = make(map[string]bool)
+			}
+			pairing.Present[username] = true
+		}
+		if !pairing.Present[username] {
+			absent = append(absent, player)
+		}
+	}
+
+	if len(absent) == 0 || now.Sub(pairing.Started) < TOURNAMENT_NO_SHOW_TIMEOUT {
+		return
+	}
+
+	if len(absent) == len(pairing.Players) {
+		t.Record(index, tournament.OutcomeDoubleForfeit, "")
+		return
+	}
+
+	// Forfeits don't change the ratings
+	webSession.Rated = false
+	for _, player := range absent {
+		if err := game.EliminatePlayer(player); err != nil {
+			return
+		}
+	}
+	webSession.recordFinishedGame()
+
+	if winner, err := game.Winner(); err == nil {
+		t.Record(index, tournament.OutcomeForfeit, session.Account(winner))
+	}
+
+	response, err := templates.RenderToBytes("gameScreen", NewGameView(webSession))
+	if err != nil {
+		return
+	}
+	if response, err = webSession.withClocks(response, now); err == nil {
+		webSession.Broadcast(response)
+	}
+}
+
+// syncTournament brings the tournament up to date with its games, and starts
+// the next rounds once the current one is complete
+func syncTournament(id string, now time.Time) error {
+	return tournamentStore.Update(id, func(t *tournament.Tournament) error {
+		for t.Status == tournament.StatusRunning {
+			round := t.CurrentRound()
+			for i := range round.Pairings {
+				syncPairing(t, i, now)
+			}
+
+			if !round.Complete() {
+				return nil
+			}
+			if _, err := t.Advance(now); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func advanceTournamentsEvery(interval time.Duration) {
+	for {
+		for _, id := range tournamentStore.Running() {
+			syncTournament(id, time.Now())
+		}
+		time.Sleep(interval)
+	}
+}
+
+// tournamentError converts an error of the tournament into a message which
+// can be shown to the player
+func tournamentError(err error) string {
+	if errors.As(err, &GameError{}) {
+		return err.Error()
+	}
+
+	switch err {
+	case tournament.ErrorNotRegistration, tournament.ErrorAlreadyJoined, tournament.ErrorNotJoined,
+		tournament.ErrorTooFewPlayers, tournament.ErrorNotRunning:
+		return err.Error()
+	}
+
+	return "Something went wrong"
+}
+
+func registerTournamentRoutes(e *echo.Echo) {
+	e.GET("/tournaments", func(c echo.Context) error {
+		return c.Render(http.StatusOK, "tournaments", TournamentsView{
+			Username:    currentAccount(c),
+			Tournaments: tournamentStore.List(),
+			Formats:     tournament.Formats,
+		})
+	})
+
+	e.POST("/tournaments", func(c echo.Context) error {
+		username := currentAccount(c)
+		if username == "" {
+			return c.NoContent(http.StatusUnauthorized)
+		}
+
+		renderForm := func(message string) error {
+			return c.Render(http.StatusUnprocessableEntity, "tournamentForm", TournamentsView{
+				Username: username,
+				Formats:  tournament.Formats,
+				Error:    message,
+			})
+		}
+
+		t, err := parseTournament(c.FormValue)
+		if err != nil {
+			return renderForm(err.Error())
+		}
+		t.Organizer = username
+		t.Created = time.Now()
+
+		created, err := tournamentStore.Create(t)
+		if err != nil {
+			return renderForm("Something went wrong")
+		}
+
+		c.Response().Header().Set("HX-Redirect", "/tournament/"+created.Id)
+		return c.NoContent(http.StatusOK)
+	})
+
+	e.GET("/tournament/:id", func(c echo.Context) error {
+		t, err := tournamentStore.Get(c.Param("id"))
+		if err != nil {
+			return c.Render(http.StatusNotFound, "errorPage", "Error: Tournament not found")
+		}
+
+		return c.Render(http.StatusOK, "tournament", newTournamentView(t, currentAccount(c)))
+	})
+
+	e.GET("/tournament/:id/state", func(c echo.Context) error {
+		t, err := tournamentStore.Get(c.Param("id"))
+		if err != nil {
+			return c.NoContent(http.StatusNotFound)
+		}
+
+		return c.Render(http.StatusOK, "tournamentState", newTournamentView(t, currentAccount(c)))
+	})
+
+	// tournamentAction changes the tournament on behalf of the logged in
+	// player and renders its new state
+	tournamentAction := func(path string, action func(t *tournament.Tournament, username string) error) {
+		e.POST("/tournament/:id/"+path, func(c echo.Context) error {
+			username := currentAccount(c)
+			if username == "" {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+
+			id := c.Param("id")
+			err := tournamentStore.Update(id, func(t *tournament.Tournament) error {
+				return action(t, username)
+			})
+			if err == tournament.ErrorNotFound {
+				return c.NoContent(http.StatusNotFound)
+			}
+
+			t, getErr := tournamentStore.Get(id)
+			if getErr != nil {
+				return c.NoContent(http.StatusNotFound)
+			}
+
+			view := newTournamentView(t, username)
+			if err != nil {
+				view.Error = tournamentError(err)
+			}
+			return c.Render(http.StatusOK, "tournamentState", view)
+		})
+	}
+
+	tournamentAction("join", func(t *tournament.Tournament, username string) error {
+		return t.Join(username)
+	})
+
+	tournamentAction("leave", func(t *tournament.Tournament, username string) error {
+		return t.Leave(username)
+	})
+
+	tournamentAction("start", func(t *tournament.Tournament, username string) error {
+		if !strings.EqualFold(t.Organizer, username) {
+			return GameErrorf("Only the organizer can start the tournament")
+		}
+
+		seedByRating(t.Players)
+		if err := t.Start(time.Now()); err != nil {
+			return err
+		}
+
+		// The games are created right away rather than on the next tick
+		now := time.Now()
+		for i := range t.CurrentRound().Pairings {
+			syncPairing(t, i, now)
+		}
+		return nil
+	})
+
+	e.GET("/tournament/:id/play", func(c echo.Context) error {
+		username := currentAccount(c)
+		if username == "" {
+			return c.Redirect(http.StatusFound, "/login")
+		}
+
+		t, err := tournamentStore.Get(c.Param("id"))
+		if err != nil {
+			return c.Render(http.StatusNotFound, "errorPage", "Error: Tournament not found")
+		}
+
+		pairing := newTournamentView(t, username).MyGame()
+		if pairing == nil {
+			return c.Redirect(http.StatusFound, "/tournament/"+t.Id)
+		}
+
+		webSession, ok := games.Get(pairing.GameId)
+		if !ok {
+			return c.Redirect(http.StatusFound, "/tournament/"+t.Id)
+		}
+
+		player := engine.Player(0)
+		for seat, other := range pairing.Players {
+			if strings.EqualFold(other, username) {
+				player = engine.Player(seat + 1)
+			}
+		}
+
+		webSession.SessionMutex.RLock()
+		token, err := webSession.NewTokenForPlayer(player)
+		webSession.SessionMutex.RUnlock()
+		if err != nil {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		setGameCookie(c, token)
+		return c.Redirect(http.StatusFound, "/")
+	})
+}
@@ -0,0 +1,216 @@
+package tui
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+
+	"github.com/Denloob/cadere/api"
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/render"
+)
+
+// Cursor is a position on the board or on one of its edge arrows. Rows -1
+// and height are the arrows above and below the board, columns -1 and width
+// are the arrows on its sides.
+type Cursor struct {
+	Row int
+	Col int
+}
+
+func (c Cursor) isCorner(width, height int) bool {
+	return (c.Row == -1 || c.Row == height) && (c.Col == -1 || c.Col == width)
+}
+
+// Move moves the cursor one cell in the direction. It doesn't leave the
+// edge arrows and skips over the corners, which have none.
+func (c Cursor) Move(direction engine.Direction, width, height int) Cursor {
+	moved := c
+	switch direction {
+	case engine.DirectionUp:
+		moved.Row = max(-1, c.Row-1)
+	case engine.DirectionDown:
+		moved.Row = min(height, c.Row+1)
+	case engine.DirectionLeft:
+		moved.Col = max(-1, c.Col-1)
+	case engine.DirectionRight:
+		moved.Col = min(width, c.Col+1)
+	}
+
+	if moved.isCorner(width, height) {
+		return c
+	}
+	return moved
+}
+
+// Action returns the move selected by the cursor: putting a tile when it is
+// on the board, or shifting the line of the arrow it is on towards the board
+func (c Cursor) Action(width, height int) (engine.Move, bool) {
+	switch {
+	case c.isCorner(width, height):
+		return engine.Move{}, false
+	case c.Row == -1:
+		return engine.ShiftMove(engine.DirectionDown, c.Col), true
+	case c.Row == height:
+		return engine.ShiftMove(engine.DirectionUp, c.Col), true
+	case c.Col == -1:
+		return engine.ShiftMove(engine.DirectionRight, c.Row), true
+	case c.Col == width:
+		return engine.ShiftMove(engine.DirectionLeft, c.Row), true
+	}
+
+	return engine.PutMove(c.Row, c.Col), true
+}
+
+func background(c color.RGBA) string {
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", c.R, c.G, c.B)
+}
+
+func foreground(c color.RGBA) string {
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", c.R, c.G, c.B)
+}
+
+// Colors returns the colors of the players of the game
+func Colors(state api.GameState) render.Colors {
+	colors := render.Colors{}
+	for _, player := range state.Players {
+		if c, err := render.ParseHexColor(player.Color); err == nil {
+			colors[player.Player] = c
+		}
+	}
+
+	return colors
+}
+
+func arrowAt(cursor Cursor, width, height int) string {
+	switch {
+	case cursor.isCorner(width, height):
+		return "  "
+	case cursor.Row == -1:
+		return "▼ "
+	case cursor.Row == height:
+		return "▲ "
+	case cursor.Col == -1:
+		return "▶ "
+	}
+
+	return "◀ "
+}
+
+// DrawBoard draws the board with its edge arrows, highlighting the cursor
+func DrawBoard(w io.Writer, board engine.Board, colors render.Colors, cursor Cursor) {
+	if len(board) == 0 {
+		return
+	}
+	height, width := len(board), len(board[0])
+
+	var sb strings.Builder
+	for row := -1; row <= height; row++ {
+		for col := -1; col <= width; col++ {
+			cell := Cursor{Row: row, Col: col}
+			if cell == cursor {
+				sb.WriteString(reverse)
+			}
+
+			if row == -1 || row == height || col == -1 || col == width {
+				sb.WriteString(foreground(render.ArrowColor))
+				sb.WriteString(arrowAt(cell, width, height))
+			} else {
+				tileColor := render.EmptyTileColor
+				if player, err := board[row][col].ToPlayer(); err == nil {
+					if c, ok := colors[player]; ok {
+						tileColor = c
+					}
+				}
+
+				sb.WriteString(background(tileColor))
+				if cell == cursor {
+					sb.WriteString("[]")
+				} else {
+					sb.WriteString("  ")
+				}
+			}
+
+			sb.WriteString(reset)
+		}
+		sb.WriteString(Newline)
+	}
+
+	io.WriteString(w, sb.String())
+}
+
+// DrawGame draws the whole screen of the game: the players, the board and
+// the status line
+func DrawGame(w io.Writer, state api.GameState, cursor Cursor, status string) {
+	colors := Colors(state)
+
+	var sb strings.Builder
+	sb.WriteString(ClearScreen)
+
+	for _, player := range state.Players {
+		marker := "  "
+		if player.Player == state.CurrentPlayer {
+			marker = "> "
+		}
+
+		sb.WriteString(marker)
+		if c, ok := colors[player.Player]; ok {
+			sb.WriteString(background(c))
+		}
+		sb.WriteString("  " + reset + " " + player.Name)
+		if player.Player == state.You {
+			sb.WriteString(" (you)")
+		}
+		if player.Bot {
+			sb.WriteString(" (bot)")
+		}
+		sb.WriteString(Newline)
+	}
+	sb.WriteString(Newline)
+	io.WriteString(w, sb.String())
+
+	DrawBoard(w, state.Board, colors, cursor)
+
+	io.WriteString(w, Newline+bold+Headline(state)+reset+Newline)
+	if status != "" {
+		io.WriteString(w, status+Newline)
+	}
+}
+
+// Headline describes what the game is waiting for
+func Headline(state api.GameState) string {
+	name := func(player engine.Player) string {
+		if p, ok := state.Player(player); ok {
+			return p.Name
+		}
+		return fmt.Sprintf("Player %d", player)
+	}
+
+	switch state.Stage {
+	case api.StageLobby:
+		if state.Host {
+			return "Waiting for players. Press s to start the game."
+		}
+		return "Waiting for the host to start the game."
+	case api.StageOver:
+		if state.Draw {
+			return "The game is a draw."
+		}
+		if state.Winner != 0 {
+			return name(state.Winner) + " wins!"
+		}
+		return "The game is over."
+	}
+
+	if _, ok := state.Player(state.You); !ok {
+		return "You were eliminated."
+	}
+	if state.YourTurn() {
+		if state.Stage == api.StageInit {
+			return "Your turn: place a tile."
+		}
+		return "Your turn: shift a row or a column."
+	}
+	return "Waiting for " + name(state.CurrentPlayer) + "."
+}
@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"bufio"
+	"unicode/utf8"
+
+	"github.com/Denloob/cadere/engine"
+)
+
+type KeyCode int
+
+const (
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyEnter
+	KeyEscape
+	KeyInterrupt
+	KeyUnknown
+)
+
+// Key is a key pressed in a raw mode terminal. Rune is set for KeyRune.
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// keyDirections maps the keys which move the cursor to their directions
+var keyDirections = map[Key]engine.Direction{
+	{Code: KeyUp}:              engine.DirectionUp,
+	{Code: KeyDown}:            engine.DirectionDown,
+	{Code: KeyLeft}:            engine.DirectionLeft,
+	{Code: KeyRight}:           engine.DirectionRight,
+	{Code: KeyRune, Rune: 'k'}: engine.DirectionUp,
+	{Code: KeyRune, Rune: 'j'}: engine.DirectionDown,
+	{Code: KeyRune, Rune: 'h'}: engine.DirectionLeft,
+	{Code: KeyRune, Rune: 'l'}: engine.DirectionRight,
+}
+
+// Direction returns the direction the key moves the cursor in: the arrows,
+// or hjkl like in vi
+func (k Key) Direction() (engine.Direction, bool) {
+	direction, ok := keyDirections[k]
+	return direction, ok
+}
+
+// ReadKey reads the next key. Escape sequences of keys like the arrows are
+// sent by the terminal all at once, so an escape with nothing buffered after
+// it is the escape key itself.
+func ReadKey(r *bufio.Reader) (Key, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch b {
+	case '\r', '\n':
+		return Key{Code: KeyEnter}, nil
+	case 0x03:
+		return Key{Code: KeyInterrupt}, nil
+	case 0x1b:
+		if r.Buffered() == 0 {
+			return Key{Code: KeyEscape}, nil
+		}
+		return readEscapeSequence(r)
+	}
+
+	if b < utf8.RuneSelf {
+		return Key{Code: KeyRune, Rune: rune(b)}, nil
+	}
+
+	if err := r.UnreadByte(); err != nil {
+		return Key{}, err
+	}
+	char, _, err := r.ReadRune()
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{Code: KeyRune, Rune: char}, nil
+}
+
+func readEscapeSequence(r *bufio.Reader) (Key, error) {
+	introducer, err := r.ReadByte()
+	if err != nil {
+		return Key{}, err
+	}
+	if introducer != '[' && introducer != 'O' {
+		return Key{Code: KeyUnknown}, nil
+	}
+
+	// Skip the parameters, like the modifiers in "\x1b[1;5A"
+	final, err := r.ReadByte()
+	for err == nil && (final == ';' || (final >= '0' && final <= '9')) {
+		final, err = r.ReadByte()
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch final {
+	case 'A':
+		return Key{Code: KeyUp}, nil
+	case 'B':
+		return Key{Code: KeyDown}, nil
+	case 'C':
+		return Key{Code: KeyRight}, nil
+	case 'D':
+		return Key{Code: KeyLeft}, nil
+	}
+
+	return Key{Code: KeyUnknown}, nil
+}
@@ -0,0 +1,21 @@
+// Package tui draws games in a terminal and reads keys from it.
+package tui
+
+import "errors"
+
+var ErrorUnsupported = errors.New("raw terminal mode is not supported on this platform")
+
+// Escape sequences understood by ANSI terminals
+const (
+	ClearScreen = "\x1b[H\x1b[2J"
+	HideCursor  = "\x1b[?25l"
+	ShowCursor  = "\x1b[?25h"
+
+	reset   = "\x1b[0m"
+	reverse = "\x1b[7m"
+	bold    = "\x1b[1m"
+)
+
+// Newline ends a line in raw mode, where the terminal no longer returns the
+// cursor to the start of the line by itself
+const Newline = "\r\n"
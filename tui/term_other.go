@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package tui
+
+// State is the mode of a terminal before it was put in raw mode
+type State struct{}
+
+// MakeRaw is not supported on this platform
+func MakeRaw(fd int) (*State, error) {
+	return nil, ErrorUnsupported
+}
+
+// Restore is not supported on this platform
+func Restore(fd int, state *State) error {
+	return ErrorUnsupported
+}
@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Denloob/cadere/api"
+	"github.com/Denloob/cadere/engine"
+	"github.com/Denloob/cadere/render"
+)
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a\r\x1b[A\x1b[1;5D\x03é"))
+
+	expected := []Key{
+		{Code: KeyRune, Rune: 'a'},
+		{Code: KeyEnter},
+		{Code: KeyUp},
+		{Code: KeyLeft},
+		{Code: KeyInterrupt},
+		{Code: KeyRune, Rune: 'é'},
+	}
+	for _, key := range expected {
+		actual, err := ReadKey(r)
+		assert.NoError(t, err)
+		assert.Equal(t, key, actual)
+	}
+
+	_, err := ReadKey(r)
+	assert.Error(t, err)
+}
+
+func TestReadEscapeKey(t *testing.T) {
+	key, err := ReadKey(bufio.NewReader(strings.NewReader("\x1b")))
+	assert.NoError(t, err)
+	assert.Equal(t, Key{Code: KeyEscape}, key)
+}
+
+func TestCursorMove(t *testing.T) {
+	const width, height = 3, 2
+
+	cursor := Cursor{Row: 0, Col: 0}
+	cursor = cursor.Move(engine.DirectionUp, width, height)
+	assert.Equal(t, Cursor{Row: -1, Col: 0}, cursor)
+
+	// The edge is the furthest the cursor can go
+	cursor = cursor.Move(engine.DirectionUp, width, height)
+	assert.Equal(t, Cursor{Row: -1, Col: 0}, cursor)
+
+	// The corners are skipped
+	cursor = cursor.Move(engine.DirectionLeft, width, height)
+	assert.Equal(t, Cursor{Row: -1, Col: 0}, cursor)
+
+	cursor = Cursor{Row: 1, Col: 2}.Move(engine.DirectionRight, width, height)
+	assert.Equal(t, Cursor{Row: 1, Col: 3}, cursor)
+}
+
+func TestCursorAction(t *testing.T) {
+	const width, height = 3, 2
+
+	tests := []struct {
+		cursor Cursor
+		move   engine.Move
+	}{
+		{Cursor{Row: 1, Col: 2}, engine.PutMove(1, 2)},
+		{Cursor{Row: -1, Col: 2}, engine.ShiftMove(engine.DirectionDown, 2)},
+		{Cursor{Row: 2, Col: 0}, engine.ShiftMove(engine.DirectionUp, 0)},
+		{Cursor{Row: 1, Col: -1}, engine.ShiftMove(engine.DirectionRight, 1)},
+		{Cursor{Row: 0, Col: 3}, engine.ShiftMove(engine.DirectionLeft, 0)},
+	}
+	for _, test := range tests {
+		move, ok := test.cursor.Action(width, height)
+		assert.True(t, ok)
+		assert.Equal(t, test.move, move)
+	}
+
+	_, ok := Cursor{Row: -1, Col: 3}.Action(width, height)
+	assert.False(t, ok)
+}
+
+func TestDrawGame(t *testing.T) {
+	state := api.GameState{
+		Stage: api.StagePlaying,
+		Board: engine.Board{{1, 0}, {0, 2}},
+		Players: []api.Player{
+			{Player: 1, Name: "Alice", Color: "#f26419"},
+			{Player: 2, Name: "Bob", Color: "#33658a", Bot: true},
+		},
+		CurrentPlayer: 2,
+		You:           1,
+	}
+
+	var buf bytes.Buffer
+	DrawGame(&buf, state, Cursor{Row: -1, Col: 0}, "")
+
+	screen := buf.String()
+	assert.Contains(t, screen, "\x1b[48;2;242;100;25m")
+	assert.Contains(t, screen, "Alice (you)")
+	assert.Contains(t, screen, "Bob (bot)")
+	assert.Contains(t, screen, "Waiting for Bob.")
+	assert.Contains(t, screen, reverse+foreground(render.ArrowColor)+"▼ ")
+}
+
+func TestKeyDirection(t *testing.T) {
+	direction, ok := Key{Code: KeyRune, Rune: 'h'}.Direction()
+	assert.True(t, ok)
+	assert.Equal(t, engine.DirectionLeft, direction)
+
+	_, ok = Key{Code: KeyRune, Rune: 'x'}.Direction()
+	assert.False(t, ok)
+}
@@ -1,8 +1,39 @@
 package util
 
+import (
+	"os"
+	"path/filepath"
+)
+
 func Must[T any](value T, err error) T {
 	if err != nil {
 		panic(err)
 	}
 	return value
 }
+
+// WriteFileAtomic writes the data to a temporary file and renames it over the
+// file at path, so a crash never leaves a half written file behind. Missing
+// directories are created.
+func WriteFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}